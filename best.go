@@ -0,0 +1,195 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math"
+	"math/rand"
+	"runtime"
+	"sync"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+)
+
+// FactorsBest performs restarts factorisations of V with k components, each
+// starting from an independent uniform random initialisation, and returns the
+// factors with the smallest Frobenius reconstruction error and that error.
+// If src is not nil, the initialisations are drawn sequentially from src, or
+// from c.Rand if src is nil. Otherwise the initialisation of restart i is drawn
+// from its own source seeded with s+i, where s is the seed described for
+// Config.Seed. The result is deterministic for a given source or non-zero seed,
+// although the factorisations are performed concurrently by up to
+// runtime.GOMAXPROCS goroutines; ties are broken in favour of the earliest
+// restart. FactorsBest panics if restarts is less than one.
+func FactorsBest(V *mat.Dense, k, restarts int, c Config, src rand.Source) (W, H *mat.Dense, err float64) {
+	if restarts < 1 {
+		panic("nmf: restarts must be positive")
+	}
+
+	rows, cols := V.Dims()
+	type result struct {
+		W, H *mat.Dense
+		err  float64
+	}
+	results := make([]result, restarts)
+	if src == nil {
+		src = c.Rand
+	}
+	seed := c.seed()
+	if src != nil {
+		for i := range results {
+			results[i].W, results[i].H = InitRandom(rows, cols, k, Uniform, src)
+		}
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	for i := range results {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, r *result) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if r.W == nil {
+				r.W, r.H = InitRandom(rows, cols, k, Uniform, rand.NewSource(seed+int64(i)))
+			}
+			r.W, r.H, _ = Factors(V, r.W, r.H, c)
+			r.err = ReconstructionError(V, r.W, r.H, 2)
+		}(i, &results[i])
+	}
+	wg.Wait()
+
+	err = math.Inf(1)
+	for _, r := range results {
+		if r.err < err {
+			W, H, err = r.W, r.H, r.err
+		}
+	}
+	return W, H, err
+}
+
+// selectRankRestarts is the number of restarts used by SelectRank
+// for each candidate rank.
+const selectRankRestarts = 3
+
+// SelectRank factorises V for each rank from kmin to kmax inclusive and returns
+// the rank at the elbow of the curve of reconstruction error against rank, along
+// with the errors, where errs[i] is the error for rank kmin+i. Each rank is
+// factorised by FactorsBest with a small number of restarts using src. The
+// elbow is the point on the curve furthest from the chord joining its end points
+// after scaling both axes to the unit interval. If fewer than three ranks are
+// considered, the rank with the smallest error is returned.
+//
+// The elbow is a heuristic. It identifies the rank beyond which additional
+// components give diminishing returns, but it is not guaranteed to correspond
+// to the true rank of the data. SelectRank panics if kmin is less than one or
+// kmax is less than kmin.
+func SelectRank(V *mat.Dense, kmin, kmax int, c Config, src rand.Source) (best int, errs []float64) {
+	if kmin < 1 || kmax < kmin {
+		panic("nmf: invalid rank range")
+	}
+
+	errs = make([]float64, kmax-kmin+1)
+	for i := range errs {
+		_, _, errs[i] = FactorsBest(V, kmin+i, selectRankRestarts, c, src)
+	}
+
+	return kmin + elbow(errs), errs
+}
+
+// CrossValScore estimates the generalisation error of rank k factorisations of V
+// by cross-validation over the entries of V. The entries are randomly partitioned
+// into folds of equal size and, for each fold, V is factorised with the entries of
+// the fold masked as described for Config.MaskNaN, starting from a uniform random
+// initialisation. The error of each fold is the root mean squared error of the
+// reconstruction of its held-out entries, and the mean and standard deviation of
+// the errors over the folds are returned. NaN entries of V are always masked and
+// are not held out. The partition and initialisations are drawn from src or, if
+// src is nil, from the random source described for Config.Seed.
+// CrossValScore panics if k is not positive or folds is less than two or greater
+// than the number of finite entries of V.
+func CrossValScore(V *mat.Dense, k, folds int, c Config, src rand.Source) (meanErr, stdErr float64) {
+	if k < 1 {
+		panic(errZeroRank)
+	}
+	r, n := V.Dims()
+	var entries [][2]int
+	for i := 0; i < r; i++ {
+		for j := 0; j < n; j++ {
+			if !math.IsNaN(V.At(i, j)) {
+				entries = append(entries, [2]int{i, j})
+			}
+		}
+	}
+	if folds < 2 || len(entries) < folds {
+		panic("nmf: invalid number of folds")
+	}
+	if src == nil {
+		src = c.source()
+	}
+	rnd := rand.New(src)
+	rnd.Shuffle(len(entries), func(i, j int) { entries[i], entries[j] = entries[j], entries[i] })
+
+	c.MaskNaN = true
+	errs := make([]float64, folds)
+	for f := range errs {
+		held := entries[f*len(entries)/folds : (f+1)*len(entries)/folds]
+		Vm := mat.DenseCopyOf(V)
+		for _, e := range held {
+			Vm.Set(e[0], e[1], math.NaN())
+		}
+		Wo, Ho := InitRandom(r, n, k, Uniform, rnd)
+		W, H, _ := Factors(Vm, Wo, Ho, c)
+
+		var sum float64
+		for _, e := range held {
+			d := V.At(e[0], e[1]) - Predict(W, H, e[0], e[1])
+			sum += d * d
+		}
+		errs[f] = math.Sqrt(sum / float64(len(held)))
+	}
+	return stat.MeanStdDev(errs, nil)
+}
+
+// elbow returns the index of the point in y furthest from the chord joining
+// the first and last points, with the indices and values scaled to the unit
+// interval. If y has fewer than three elements, the index of the smallest
+// element is returned.
+func elbow(y []float64) int {
+	n := len(y)
+	if n < 3 {
+		return floats.MinIdx(y)
+	}
+
+	min, max := floats.Min(y), floats.Max(y)
+	if max == min {
+		return 0
+	}
+	scale := func(i int) (x, v float64) {
+		return float64(i) / float64(n-1), (y[i] - min) / (max - min)
+	}
+
+	// The distance from each point to the chord is proportional
+	// to the magnitude of the cross product with the chord.
+	x0, y0 := scale(0)
+	x1, y1 := scale(n - 1)
+	dx, dy := x1-x0, y1-y0
+	var (
+		best int
+		dist float64
+	)
+	for i := 1; i < n-1; i++ {
+		x, v := scale(i)
+		d := math.Abs(dx*(v-y0) - dy*(x-x0))
+		if d > dist {
+			best, dist = i, d
+		}
+	}
+	return best
+}
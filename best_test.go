@@ -0,0 +1,185 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math"
+	"math/rand"
+	"runtime"
+	"testing"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestFactorsBest(t *testing.T) {
+	V, _, _ := lowRank(20, 10, 3, rand.NewSource(1))
+	c := Config{
+		Tolerance:   1e-4,
+		MaxIter:     50,
+		MaxOuterSub: 1000,
+		MaxInnerSub: 20,
+		Limit:       time.Minute,
+	}
+
+	const restarts = 4
+	W, H, err := FactorsBest(V, 3, restarts, c, rand.NewSource(2))
+	if got := ReconstructionError(V, W, H, 2); got != err {
+		t.Errorf("reported error does not match factors: got:%v want:%v", err, got)
+	}
+
+	// Each restart must be no better than the best.
+	src := rand.NewSource(2)
+	rows, cols := V.Dims()
+	for i := 0; i < restarts; i++ {
+		Wo, Ho := InitRandom(rows, cols, 3, Uniform, src)
+		W, H, _ := Factors(V, Wo, Ho, c)
+		if d := ReconstructionError(V, W, H, 2); d < err {
+			t.Errorf("restart %d better than best: %v < %v", i, d, err)
+		}
+	}
+
+	_, _, again := FactorsBest(V, 3, restarts, c, rand.NewSource(2))
+	if again != err {
+		t.Errorf("result not deterministic: got:%v want:%v", again, err)
+	}
+}
+
+func TestFactorsBestSeed(t *testing.T) {
+	V, _, _ := lowRank(20, 10, 3, rand.NewSource(1))
+	c := Config{
+		Tolerance:   1e-4,
+		MaxIter:     50,
+		MaxOuterSub: 1000,
+		MaxInnerSub: 20,
+		Limit:       time.Minute,
+		Seed:        10,
+	}
+
+	const restarts = 6
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+	var W, H *mat.Dense
+	var err float64
+	for _, procs := range []int{1, 2, 4, 8} {
+		runtime.GOMAXPROCS(procs)
+		Wp, Hp, errp := FactorsBest(V, 3, restarts, c, nil)
+		if W == nil {
+			W, H, err = Wp, Hp, errp
+			continue
+		}
+		if errp != err || !mat.Equal(Wp, W) || !mat.Equal(Hp, H) {
+			t.Errorf("result not reproducible with GOMAXPROCS=%d: got:%v want:%v", procs, errp, err)
+		}
+	}
+
+	// The best result is the best of the
+	// independently seeded restarts.
+	rows, cols := V.Dims()
+	best := math.Inf(1)
+	for i := 0; i < restarts; i++ {
+		Wo, Ho := InitRandom(rows, cols, 3, Uniform, rand.NewSource(c.Seed+int64(i)))
+		W, H, _ := Factors(V, Wo, Ho, c)
+		best = math.Min(best, ReconstructionError(V, W, H, 2))
+	}
+	if err != best {
+		t.Errorf("unexpected best error: got:%v want:%v", err, best)
+	}
+
+	// A Rand in c is used in place of a nil source.
+	c.Rand = rand.NewSource(3)
+	Wr, _, _ := FactorsBest(V, 3, restarts, c, nil)
+	Ws, _, _ := FactorsBest(V, 3, restarts, c, rand.NewSource(3))
+	if !mat.Equal(Wr, Ws) {
+		t.Error("c.Rand not used for a nil source")
+	}
+
+	// A zero Seed uses a seed taken from the current
+	// time, as for Fit.
+	c.Rand = nil
+	c.Seed = 0
+	W1, _, _ := FactorsBest(V, 3, restarts, c, nil)
+	W2, _, _ := FactorsBest(V, 3, restarts, c, nil)
+	if mat.Equal(W1, W2) {
+		t.Error("zero seed gave identical results")
+	}
+}
+
+func TestSelectRank(t *testing.T) {
+	V, _, _ := lowRank(30, 20, 3, rand.NewSource(1))
+	c := Config{
+		Tolerance:   1e-4,
+		MaxIter:     100,
+		MaxOuterSub: 1000,
+		MaxInnerSub: 20,
+		Limit:       time.Minute,
+	}
+	best, errs := SelectRank(V, 1, 6, c, rand.NewSource(2))
+	if best != 3 {
+		t.Errorf("unexpected rank: got:%d want:3 errors:%v", best, errs)
+	}
+	if len(errs) != 6 {
+		t.Errorf("unexpected number of errors: got:%d want:6", len(errs))
+	}
+}
+
+func TestElbow(t *testing.T) {
+	for _, test := range []struct {
+		y    []float64
+		want int
+	}{
+		{y: []float64{3}, want: 0},
+		{y: []float64{3, 1}, want: 1},
+		{y: []float64{10, 5, 1, 0.9, 0.8, 0.7}, want: 2},
+		{y: []float64{10, 1, 0.9, 0.8}, want: 1},
+		{y: []float64{1, 1, 1}, want: 0},
+	} {
+		if got := elbow(test.y); got != test.want {
+			t.Errorf("unexpected elbow for %v: got:%d want:%d", test.y, got, test.want)
+		}
+	}
+}
+
+func TestCrossValScore(t *testing.T) {
+	V, _, _ := lowRank(30, 20, 3, rand.NewSource(1))
+	rnd := rand.New(rand.NewSource(3))
+	V.Apply(func(_, _ int, v float64) float64 { return v + 0.01*rnd.Float64() }, V)
+	c := Config{
+		Tolerance:   1e-4,
+		MaxIter:     200,
+		MaxOuterSub: 1000,
+		MaxInnerSub: 20,
+		Limit:       time.Minute,
+	}
+
+	errs := make(map[int]float64)
+	for _, k := range []int{1, 3} {
+		mean, std := CrossValScore(V, k, 5, c, rand.NewSource(2))
+		if mean <= 0 || std < 0 || math.IsNaN(mean) || math.IsNaN(std) {
+			t.Errorf("unexpected score for rank %d: mean=%v std=%v", k, mean, std)
+		}
+		errs[k] = mean
+	}
+	if errs[3] >= errs[1] {
+		t.Errorf("held-out error not reduced at the true rank: %v", errs)
+	}
+
+	c.Seed = 4
+	m1, s1 := CrossValScore(V, 3, 5, c, nil)
+	m2, s2 := CrossValScore(V, 3, 5, c, nil)
+	if m1 != m2 || s1 != s2 {
+		t.Errorf("cross-validation not deterministic: got:%v±%v and %v±%v", m1, s1, m2, s2)
+	}
+
+	for _, test := range []struct{ k, folds int }{{0, 5}, {3, 1}, {3, 601}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for k=%d folds=%d", test.k, test.folds)
+				}
+			}()
+			CrossValScore(V, test.k, test.folds, c, nil)
+		}()
+	}
+}
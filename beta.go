@@ -0,0 +1,160 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"context"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// betaDivergence is the multiplicative update rule for the
+// β-divergence objective. The updates are the majorisation-
+// minimisation updates described in Févotte and Idier (2011)
+// 'Algorithms for Nonnegative Matrix Factorization with the
+// β-Divergence.' Neural Computation 23:2421.
+type betaDivergence struct {
+	V, W, H *mat.Dense
+
+	// beta is the β parameter of the divergence
+	// and gamma is the exponent applied to the
+	// multiplicative update ratio.
+	beta, gamma float64
+
+	// p is (WH)^(β-2)⊙V and q is (WH)^(β-1).
+	wh, p, q mat.Dense
+	num, den mat.Dense
+}
+
+func newBetaDivergence(V, Wo, Ho *mat.Dense, beta float64) *betaDivergence {
+	b := &betaDivergence{V: V, W: new(mat.Dense), H: new(mat.Dense), beta: beta}
+	b.W.CloneFrom(Wo)
+	b.H.CloneFrom(Ho)
+	switch {
+	case beta < 1:
+		b.gamma = 1 / (2 - beta)
+	case beta > 2:
+		b.gamma = 1 / (beta - 1)
+	default:
+		b.gamma = 1
+	}
+	return b
+}
+
+// powers sets b.p to (WH)^(β-2)⊙V and b.q to (WH)^(β-1) for the
+// current factors. Entries of WH are floored at epsilon.
+func (b *betaDivergence) powers() {
+	b.wh.Reset()
+	b.wh.Mul(b.W, b.H)
+	applyInPlace(func(_, _ int, v float64) float64 { return math.Max(v, epsilon) }, &b.wh)
+	b.p.Reset()
+	b.p.Apply(func(r, c int, v float64) float64 {
+		if v == 0 {
+			return 0
+		}
+		return math.Pow(b.wh.At(r, c), b.beta-2) * v
+	}, b.V)
+	b.q.Reset()
+	b.q.Apply(func(_, _ int, v float64) float64 {
+		return math.Pow(v, b.beta-1)
+	}, &b.wh)
+}
+
+// gradients returns the gradients of the divergence with respect
+// to W and H.
+func (b *betaDivergence) gradients() (gW, gH *mat.Dense) {
+	b.powers()
+	var d mat.Dense
+	d.Sub(&b.q, &b.p)
+
+	// ∇W = ((WH)^(β-1) - (WH)^(β-2)⊙V)Hᵀ
+	gW = new(mat.Dense)
+	gW.Mul(&d, b.H.T())
+
+	// ∇H = Wᵀ((WH)^(β-1) - (WH)^(β-2)⊙V)
+	gH = new(mat.Dense)
+	gH.Mul(b.W.T(), &d)
+
+	return gW, gH
+}
+
+func (b *betaDivergence) projNorm() float64 {
+	gW, gH := b.gradients()
+	return projNorm(gW, b.W, gH, b.H)
+}
+
+func (b *betaDivergence) projGradients() (gW, gH *mat.Dense) {
+	gW, gH = b.gradients()
+	projectGradient(gW, b.W, 0)
+	projectGradient(gH, b.H, 0)
+	return gW, gH
+}
+
+func (b *betaDivergence) update(_ context.Context) (ok bool, err error) {
+	// H *= ((Wᵀ((WH)^(β-2)⊙V)) / (Wᵀ(WH)^(β-1)))^γ
+	b.powers()
+	b.num.Reset()
+	b.num.Mul(b.W.T(), &b.p)
+	b.den.Reset()
+	b.den.Mul(b.W.T(), &b.q)
+	applyInPlace(b.ratio(), b.H)
+
+	// W *= ((((WH)^(β-2)⊙V)Hᵀ) / ((WH)^(β-1)Hᵀ))^γ
+	b.powers()
+	b.num.Reset()
+	b.num.Mul(&b.p, b.H.T())
+	b.den.Reset()
+	b.den.Mul(&b.q, b.H.T())
+	applyInPlace(b.ratio(), b.W)
+
+	return true, nil
+}
+
+// ratio returns a function that scales its input by the ratio of the
+// corresponding elements of b.num and b.den raised to the power γ.
+func (b *betaDivergence) ratio() func(r, c int, v float64) float64 {
+	if b.gamma == 1 {
+		return ratio(&b.num, &b.den)
+	}
+	return func(r, c int, v float64) float64 {
+		return v * math.Pow(b.num.At(r, c)/(b.den.At(r, c)+epsilon), b.gamma)
+	}
+}
+
+func (b *betaDivergence) objective() float64 { return betaDiv(b.V, b.W, b.H, b.beta) }
+
+func (b *betaDivergence) factors() (W, H *mat.Dense) { return b.W, b.H }
+
+// betaDiv returns the β-divergence D_β(V||WH). Entries of WH are floored
+// at epsilon and, for β <= 0, so are the entries of V.
+func betaDiv(V, W, H *mat.Dense, beta float64) float64 {
+	var wh mat.Dense
+	wh.Mul(W, H)
+	r, c := V.Dims()
+	var d float64
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			v := V.At(i, j)
+			x := math.Max(wh.At(i, j), epsilon)
+			switch beta {
+			case 0:
+				v = math.Max(v, epsilon)
+				d += v/x - math.Log(v/x) - 1
+			case 1:
+				if v != 0 {
+					d += v * math.Log(v/x)
+				}
+				d += x - v
+			default:
+				if beta < 0 {
+					v = math.Max(v, epsilon)
+				}
+				d += (math.Pow(v, beta) + (beta-1)*math.Pow(x, beta) - beta*v*math.Pow(x, beta-1)) / (beta * (beta - 1))
+			}
+		}
+	}
+	return d
+}
@@ -0,0 +1,151 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestBetaDivergence(t *testing.T) {
+	V, Wo, Ho := testFactors()
+
+	// β = 2 and β = 1 reproduce the Frobenius and
+	// Kullback-Leibler multiplicative updates.
+	for _, test := range []struct {
+		beta float64
+		want Config
+	}{
+		{beta: 2, want: Config{Method: MultiplicativeUpdate, Objective: Frobenius}},
+		{beta: 1, want: Config{Objective: KullbackLeibler}},
+	} {
+		c := testConfig
+		c.Tolerance = 0
+		c.Limit = 0
+		c.Objective = BetaDivergence
+		c.Beta = test.beta
+		W, H, _ := Factors(V, Wo, Ho, c)
+
+		want := testConfig
+		want.Tolerance = 0
+		want.Limit = 0
+		want.Method = test.want.Method
+		want.Objective = test.want.Objective
+		wantW, wantH, _ := Factors(V, Wo, Ho, want)
+		if !mat.EqualApprox(W, wantW, 1e-8) || !mat.EqualApprox(H, wantH, 1e-8) {
+			t.Errorf("unexpected factors for β=%v", test.beta)
+		}
+	}
+
+	for _, beta := range []float64{-0.5, 0, 0.5, 1.5, 3} {
+		c := testConfig
+		c.Tolerance = 0
+		c.Objective = BetaDivergence
+		c.Beta = beta
+		prev := betaDiv(V, Wo, Ho, beta)
+		for _, iter := range []int{1, 10, 100} {
+			c.MaxIter = iter
+			_, _, res := FactorsResult(V, Wo, Ho, c)
+			if math.IsNaN(res.FinalObjective) || math.IsInf(res.FinalObjective, 0) {
+				t.Fatalf("non-finite divergence for β=%v: %v", beta, res.FinalObjective)
+			}
+			if res.FinalObjective > prev {
+				t.Errorf("divergence increased for β=%v after %d iterations: %v > %v", beta, iter, res.FinalObjective, prev)
+			}
+			prev = res.FinalObjective
+		}
+	}
+}
+
+func TestBetaDiv(t *testing.T) {
+	V, W, H := testFactors()
+	if got, want := betaDiv(V, W, H, 2), frobenius(V, W, H); math.Abs(got-want) > 1e-10*want {
+		t.Errorf("unexpected β=2 divergence: got:%v want:%v", got, want)
+	}
+	if got, want := betaDiv(V, W, H, 1), divergence(V, W, H); math.Abs(got-want) > 1e-10*want {
+		t.Errorf("unexpected β=1 divergence: got:%v want:%v", got, want)
+	}
+	// The general form approaches the special cases
+	// as β approaches them. V is made strictly positive
+	// so that the flooring of zeros for β <= 0 does not
+	// introduce a discontinuity.
+	applyInPlace(func(_, _ int, v float64) float64 { return v + 1 }, V)
+	for _, beta := range []float64{0, 1, 2} {
+		want := betaDiv(V, W, H, beta)
+		got := betaDiv(V, W, H, beta+1e-7)
+		if math.Abs(got-want) > 1e-4*want {
+			t.Errorf("discontinuous divergence at β=%v: got:%v want:%v", beta, got, want)
+		}
+	}
+}
+
+func TestItakuraSaito(t *testing.T) {
+	// Construct a rank 2 spectrogram from two spectral
+	// templates with time-varying activations.
+	const freqs, frames = 16, 40
+	W0 := mat.NewDense(freqs, 2, nil)
+	for i := 0; i < freqs; i++ {
+		f := float64(i)
+		W0.Set(i, 0, math.Exp(-(f-3)*(f-3)/4)+0.01)
+		W0.Set(i, 1, math.Exp(-(f-11)*(f-11)/8)+0.01)
+	}
+	// Each component is active alone in some frames
+	// so that the factorisation is unique up to scale.
+	H0 := mat.NewDense(2, frames, nil)
+	for j := 0; j < frames; j++ {
+		t := float64(j)
+		a0, a1 := 1+math.Sin(t/3), 2*math.Exp(-t/10)
+		switch {
+		case j%4 == 0:
+			a1 = 0
+		case j%4 == 1:
+			a0 = 0
+		}
+		H0.Set(0, j, a0+1e-3)
+		H0.Set(1, j, a1+1e-3)
+	}
+	var V mat.Dense
+	V.Mul(W0, H0)
+
+	Wo := mat.NewDense(freqs, 2, nil)
+	Ho := mat.NewDense(2, frames, nil)
+	for i := 0; i < freqs; i++ {
+		Wo.Set(i, 0, 1+0.5*math.Sin(float64(i)))
+		Wo.Set(i, 1, 1+0.5*math.Cos(float64(i)))
+	}
+	for j := 0; j < frames; j++ {
+		Ho.Set(0, j, 1)
+		Ho.Set(1, j, 1)
+	}
+
+	c := testConfig
+	c.Objective = ItakuraSaito
+	c.Tolerance = 1e-12
+	c.MaxIter = 20000
+	W, _, _ := Factors(&V, Wo, Ho, c)
+
+	// The components are recovered up to scale and order.
+	normalise := func(m *mat.Dense) {
+		r, c := m.Dims()
+		for j := 0; j < c; j++ {
+			s := colSum(m, j)
+			for i := 0; i < r; i++ {
+				m.Set(i, j, m.At(i, j)/s)
+			}
+		}
+	}
+	normalise(W0)
+	normalise(W)
+	if W.At(3, 0) < W.At(3, 1) {
+		var swapped mat.Dense
+		swapped.Augment(W.Slice(0, freqs, 1, 2), W.Slice(0, freqs, 0, 1))
+		W = &swapped
+	}
+	if !mat.EqualApprox(W, W0, 1e-2) {
+		t.Errorf("failed to recover spectral templates:\ngot: %.3f\nwant:%.3f", mat.Formatted(W.T()), mat.Formatted(W0.T()))
+	}
+}
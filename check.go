@@ -0,0 +1,115 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// checkDims returns an error if the dimensions of V, Wo and Ho are not
+// consistent with the factorisation V = Wo * Ho.
+func checkDims(V, Wo, Ho mat.Matrix) error {
+	vr, vc := V.Dims()
+	wr, wc := Wo.Dims()
+	hr, hc := Ho.Dims()
+	switch {
+	case (vr != 0 || vc != 0) && (wc == 0 || hr == 0):
+		return errZeroRank
+	case wc != hr:
+		return fmt.Errorf("nmf: Wo columns (%d) must equal Ho rows (%d)", wc, hr)
+	case vr != wr:
+		return fmt.Errorf("nmf: V rows (%d) must equal Wo rows (%d)", vr, wr)
+	case vc != hc:
+		return fmt.Errorf("nmf: V columns (%d) must equal Ho columns (%d)", vc, hc)
+	}
+	return nil
+}
+
+// errZeroRank is returned when a non-empty matrix is factorised
+// with zero-rank factors.
+var errZeroRank = errors.New("nmf: factor rank must be positive")
+
+// errNonFinite is returned when a factorisation diverges,
+// leaving NaN or infinite entries in the factors.
+var errNonFinite = errors.New("nmf: non-finite factor entries")
+
+// isFinite returns whether all the entries of m are finite.
+func isFinite(m *mat.Dense) bool {
+	raw := m.RawMatrix()
+	for i := 0; i < raw.Rows; i++ {
+		for _, v := range raw.Data[i*raw.Stride : i*raw.Stride+raw.Cols] {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// checkInputs returns an error if V, Wo and Ho are not valid inputs
+// to a factorisation. If maskNaN is true, NaN entries are allowed in V.
+func checkInputs(V, Wo, Ho *mat.Dense, maskNaN bool) error {
+	err := checkDims(V, Wo, Ho)
+	if err != nil {
+		return err
+	}
+	for _, m := range []struct {
+		name     string
+		m        *mat.Dense
+		allowNaN bool
+	}{
+		{name: "V", m: V, allowNaN: maskNaN},
+		{name: "Wo", m: Wo},
+		{name: "Ho", m: Ho},
+	} {
+		i, j, v, ok := firstInvalid(m.m, m.allowNaN)
+		if !ok {
+			return fmt.Errorf("nmf: %s has %s at (%d, %d)", m.name, invalid(v), i, j)
+		}
+	}
+	return nil
+}
+
+// CheckNonNegative returns an error describing the position of the first
+// entry of m that is negative, NaN or infinite. If all entries of m are
+// non-negative and finite, CheckNonNegative returns nil.
+func CheckNonNegative(m *mat.Dense) error {
+	i, j, v, ok := firstInvalid(m, false)
+	if ok {
+		return nil
+	}
+	return fmt.Errorf("nmf: %s at (%d, %d)", invalid(v), i, j)
+}
+
+// firstInvalid returns the row, column and value of the first negative
+// or non-finite entry of m. NaN entries are considered valid if allowNaN
+// is true. If all the entries are valid, ok is true.
+func firstInvalid(m *mat.Dense, allowNaN bool) (i, j int, v float64, ok bool) {
+	raw := m.RawMatrix()
+	for i = 0; i < raw.Rows; i++ {
+		for j, v = range raw.Data[i*raw.Stride : i*raw.Stride+raw.Cols] {
+			if v < 0 || (math.IsNaN(v) && !allowNaN) || math.IsInf(v, 0) {
+				return i, j, v, false
+			}
+		}
+	}
+	return 0, 0, 0, true
+}
+
+// invalid returns a description of the invalid value v.
+func invalid(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN entry"
+	case math.IsInf(v, 0):
+		return "infinite entry"
+	default:
+		return fmt.Sprintf("negative entry %v", v)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// AddComponent returns the factors W and H of V enlarged by a single component,
+// a new column of W and row of H, fitted to the residual V-WH. The new rank-one
+// factor w*hᵀ minimises ||V - WH - w*hᵀ||_F for w, h >= 0, and is found by
+// alternating non-negative least squares updates of w and h, starting from the
+// residual column with the largest positive part and the h fitted to it. The
+// updates stop when the relative decrease in the objective falls below
+// c.Tolerance, returning ok true, or after c.MaxIter iterations or the time
+// limit c.Limit, returning ok false. The starting component is returned if no
+// updates are made. The components of W and H are not altered.
+//
+// If W and H are empty, the first component of V is fitted, so that a
+// factorisation may be grown greedily from nothing, stopping when the reduction
+// in reconstruction error given by a new component is negligible.
+//
+// AddComponent panics if the dimensions of V, W and H are not compatible.
+func AddComponent(V, W, H *mat.Dense, c Config) (Wnew, Hnew *mat.Dense, ok bool) {
+	to := c.now()
+
+	r, n := V.Dims()
+	k := 0
+	R := mat.DenseCopyOf(V)
+	if !W.IsEmpty() || !H.IsEmpty() {
+		mustFactorise(V, W, H)
+		_, k = W.Dims()
+		var P mat.Dense
+		P.Mul(W, H)
+		R.Sub(R, &P)
+	}
+
+	w := mat.NewVecDense(r, nil)
+	h := mat.NewVecDense(n, nil)
+
+	// Start from the residual column with the
+	// largest positive part.
+	var best float64
+	for j := 0; j < n; j++ {
+		var s float64
+		for i := 0; i < r; i++ {
+			v := math.Max(0, R.At(i, j))
+			s += v * v
+		}
+		if s > best {
+			best = s
+			for i := 0; i < r; i++ {
+				w.SetVec(i, math.Max(0, R.At(i, j)))
+			}
+		}
+	}
+
+	if best == 0 {
+		// No non-negative component reduces
+		// the residual, so the optimal new
+		// component is zero.
+		ok = true
+	} else {
+		var (
+			rr   = math.Pow(mat.Norm(R, 2), 2)
+			prev = rr
+			tmp  = mat.NewVecDense(r, nil)
+		)
+		// Fit h to the starting w so that the new
+		// component reduces the residual even if
+		// no iterations are performed.
+		h.MulVec(R.T(), w)
+		nonNegScale(h, mat.Dot(w, w))
+		for i := 0; i < c.MaxIter && !c.timedOut(to); i++ {
+			// h = max(0, Rᵀw) / wᵀw
+			h.MulVec(R.T(), w)
+			nonNegScale(h, mat.Dot(w, w))
+
+			hh := mat.Dot(h, h)
+			if hh == 0 {
+				w.Zero()
+				ok = true
+				break
+			}
+			// w = max(0, Rh) / hᵀh
+			w.MulVec(R, h)
+			nonNegScale(w, hh)
+
+			// ||R - whᵀ||² = ||R||² - 2wᵀRh + ||w||²||h||²
+			tmp.MulVec(R, h)
+			obj := rr - 2*mat.Dot(w, tmp) + mat.Dot(w, w)*hh
+			if prev-obj < c.Tolerance*prev {
+				ok = true
+				break
+			}
+			prev = obj
+		}
+	}
+
+	Wnew = mat.NewDense(r, k+1, nil)
+	Hnew = mat.NewDense(k+1, n, nil)
+	if k != 0 {
+		Wnew.Slice(0, r, 0, k).(*mat.Dense).Copy(W)
+		Hnew.Slice(0, k, 0, n).(*mat.Dense).Copy(H)
+	}
+	Wnew.SetCol(k, w.RawVector().Data)
+	Hnew.SetRow(k, h.RawVector().Data)
+	return Wnew, Hnew, ok
+}
+
+// nonNegScale sets the elements of v to max(0, v_i)/d.
+func nonNegScale(v *mat.VecDense, d float64) {
+	data := v.RawVector().Data
+	for i, e := range data {
+		data[i] = math.Max(0, e) / d
+	}
+}
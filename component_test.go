@@ -0,0 +1,76 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestAddComponent(t *testing.T) {
+	const k = 3
+	V, _, _ := lowRank(20, 15, k, rand.NewSource(1))
+	c := testConfig
+	c.Tolerance = 1e-10
+	c.MaxIter = 1000
+
+	W, H := new(mat.Dense), new(mat.Dense)
+	prev := mat.Norm(V, 2)
+	for i := 0; i < k+2; i++ {
+		Wnew, Hnew, ok := AddComponent(V, W, H, c)
+		if !ok {
+			t.Errorf("component %d did not converge", i)
+		}
+		if _, wc := Wnew.Dims(); wc != i+1 {
+			t.Fatalf("unexpected rank after adding component %d: %d", i, wc)
+		}
+		if i != 0 {
+			_, k := W.Dims()
+			r, n := V.Dims()
+			if !mat.Equal(Wnew.Slice(0, r, 0, k), W) || !mat.Equal(Hnew.Slice(0, k, 0, n), H) {
+				t.Errorf("existing components altered adding component %d", i)
+			}
+		}
+		for _, m := range []*mat.Dense{Wnew, Hnew} {
+			if err := CheckNonNegative(m); err != nil {
+				t.Errorf("invalid factor adding component %d: %v", i, err)
+			}
+		}
+		e := ReconstructionError(V, Wnew, Hnew, 2)
+		if e > prev*(1+1e-12) {
+			t.Errorf("error increased adding component %d: %v > %v", i, e, prev)
+		}
+		prev = e
+		W, H = Wnew, Hnew
+	}
+	if rel := prev / mat.Norm(V, 2); rel > 0.1 {
+		t.Errorf("unexpected relative error after greedy growth: %v", rel)
+	}
+
+	// Without iterations, the starting component
+	// is still fitted but ok is false.
+	c.MaxIter = 0
+	Wnew, Hnew, ok := AddComponent(V, new(mat.Dense), new(mat.Dense), c)
+	if ok {
+		t.Error("unexpected success without iterations")
+	}
+	if mat.Max(Hnew) == 0 {
+		t.Error("zero component added without iterations")
+	}
+	if e, norm := ReconstructionError(V, Wnew, Hnew, 2), mat.Norm(V, 2); e >= norm {
+		t.Errorf("component added without iterations does not reduce the error: %v >= %v", e, norm)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic for mismatched dimensions")
+			}
+		}()
+		AddComponent(V, H, W, c)
+	}()
+}
@@ -0,0 +1,63 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import "time"
+
+// Default values used by NewConfig for options that are not supplied.
+const (
+	DefaultTolerance   = 1e-4
+	DefaultMaxIter     = 1000
+	DefaultLimit       = time.Minute
+	DefaultMaxOuterSub = 1000
+	DefaultMaxInnerSub = 20
+)
+
+// Option is a functional option for NewConfig.
+type Option func(*Config)
+
+// NewConfig returns a Config with the given options applied. The Tolerance,
+// MaxIter, Limit, MaxOuterSub and MaxInnerSub fields take the corresponding
+// default values unless set by an option. Other fields take their zero values.
+func NewConfig(opts ...Option) Config {
+	c := Config{
+		Tolerance:   DefaultTolerance,
+		MaxIter:     DefaultMaxIter,
+		Limit:       DefaultLimit,
+		MaxOuterSub: DefaultMaxOuterSub,
+		MaxInnerSub: DefaultMaxInnerSub,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// WithMethod returns an Option that sets the factorisation method.
+func WithMethod(m Method) Option {
+	return func(c *Config) { c.Method = m }
+}
+
+// WithTolerance returns an Option that sets the stopping tolerance.
+func WithTolerance(tol float64) Option {
+	return func(c *Config) { c.Tolerance = tol }
+}
+
+// WithMaxIter returns an Option that sets the maximum number of iterations
+// of the main factorisation loop.
+func WithMaxIter(n int) Option {
+	return func(c *Config) { c.MaxIter = n }
+}
+
+// WithLimit returns an Option that sets the time limit of the factorisation.
+func WithLimit(d time.Duration) Option {
+	return func(c *Config) { c.Limit = d }
+}
+
+// WithSubIters returns an Option that sets the maximum number of outer and
+// inner iterations of the sub-problems.
+func WithSubIters(outer, inner int) Option {
+	return func(c *Config) { c.MaxOuterSub, c.MaxInnerSub = outer, inner }
+}
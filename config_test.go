@@ -0,0 +1,56 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewConfig(t *testing.T) {
+	for _, test := range []struct {
+		opts []Option
+		want Config
+	}{
+		{
+			want: Config{
+				Tolerance:   DefaultTolerance,
+				MaxIter:     DefaultMaxIter,
+				Limit:       DefaultLimit,
+				MaxOuterSub: DefaultMaxOuterSub,
+				MaxInnerSub: DefaultMaxInnerSub,
+			},
+		},
+		{
+			opts: []Option{
+				WithMethod(HALS),
+				WithTolerance(1e-6),
+				WithMaxIter(10),
+				WithLimit(time.Second),
+				WithSubIters(100, 5),
+			},
+			want: Config{
+				Method:      HALS,
+				Tolerance:   1e-6,
+				MaxIter:     10,
+				Limit:       time.Second,
+				MaxOuterSub: 100,
+				MaxInnerSub: 5,
+			},
+		},
+	} {
+		got := NewConfig(test.opts...)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("unexpected config:\ngot: %+v\nwant:%+v", got, test.want)
+		}
+	}
+
+	V, Wo, Ho := testFactors()
+	_, _, res := FactorsResult(V, Wo, Ho, NewConfig())
+	if !res.OK {
+		t.Errorf("factorisation with default config did not converge: %+v", res)
+	}
+}
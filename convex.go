@@ -0,0 +1,175 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// FactorsConvex returns non-negative matrices G and H such that VGH approximates V
+// within the specified tolerance and computation limits given initial non-negative
+// solutions Go and Ho. The basis W = VG is constrained to be formed from non-negative
+// combinations of the columns of V, so the components may be interpreted as data
+// prototypes. V may have entries of either sign. The factorisation is the Convex-NMF
+// described in Ding, Li and Jordan (2010) 'Convex and Semi-Nonnegative Matrix
+// Factorizations.' IEEE Transactions on Pattern Analysis and Machine Intelligence
+// 32:45.
+//
+// For an r×c matrix V, Go must be a c×k matrix and Ho a k×c matrix. FactorsConvex
+// uses multiplicative updates; the Method, Objective and regularisation fields of
+// c are ignored. If c.Validate is true, FactorsConvex panics if the dimensions of
+// the inputs are not consistent, if V has non-finite entries or if Go or Ho have
+// negative or non-finite entries.
+func FactorsConvex(V, Go, Ho *mat.Dense, c Config) (G, H *mat.Dense, ok bool) {
+	if c.Validate {
+		err := checkConvexInputs(V, Go, Ho)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	to := c.now()
+	u := newConvex(V, Go, Ho)
+	grad := gradNorm(u.gradients())
+	c.Objective = Frobenius
+	G, H, res, _ := iterate(context.Background(), u, grad, to, c)
+	return G, H, res.OK
+}
+
+// checkConvexInputs returns an error if V, Go and Ho are not valid inputs
+// to a Convex-NMF factorisation.
+func checkConvexInputs(V, Go, Ho *mat.Dense) error {
+	vr, vc := V.Dims()
+	gr, gc := Go.Dims()
+	hr, hc := Ho.Dims()
+	switch {
+	case gc != hr:
+		return fmt.Errorf("nmf: Go columns (%d) must equal Ho rows (%d)", gc, hr)
+	case vc != gr:
+		return fmt.Errorf("nmf: V columns (%d) must equal Go rows (%d)", vc, gr)
+	case vc != hc:
+		return fmt.Errorf("nmf: V columns (%d) must equal Ho columns (%d)", vc, hc)
+	}
+	for i := 0; i < vr; i++ {
+		for j := 0; j < vc; j++ {
+			if v := V.At(i, j); math.IsNaN(v) || math.IsInf(v, 0) {
+				return fmt.Errorf("nmf: V has %s at (%d, %d)", invalid(v), i, j)
+			}
+		}
+	}
+	for _, m := range []struct {
+		name string
+		m    *mat.Dense
+	}{
+		{name: "Go", m: Go},
+		{name: "Ho", m: Ho},
+	} {
+		i, j, v, ok := firstInvalid(m.m, false)
+		if !ok {
+			return fmt.Errorf("nmf: %s has %s at (%d, %d)", m.name, invalid(v), i, j)
+		}
+	}
+	return nil
+}
+
+// convex is the Convex-NMF update rule for the Frobenius
+// norm objective with W = VG.
+type convex struct {
+	V, G, H *mat.Dense
+
+	// vTv is VᵀV and pos and neg hold
+	// its positive and negative parts.
+	vTv, pos, neg mat.Dense
+
+	w, tmp, num, den mat.Dense
+}
+
+func newConvex(V, Go, Ho *mat.Dense) *convex {
+	u := &convex{V: V, G: new(mat.Dense), H: new(mat.Dense)}
+	u.G.CloneFrom(Go)
+	u.H.CloneFrom(Ho)
+	u.vTv.Mul(V.T(), V)
+	copyInto(&u.pos, &u.vTv)
+	applyInPlace(posPart, &u.pos)
+	copyInto(&u.neg, &u.vTv)
+	applyInPlace(negPart, &u.neg)
+	return u
+}
+
+// gradients returns the gradients of the Frobenius norm objective
+// with respect to G and H.
+func (u *convex) gradients() (gG, gH *mat.Dense) {
+	// ∇G = VᵀV(GHHᵀ - Hᵀ)
+	var hhT, t mat.Dense
+	hhT.Mul(u.H, u.H.T())
+	t.Mul(u.G, &hhT)
+	t.Sub(&t, u.H.T())
+	gG = new(mat.Dense)
+	gG.Mul(&u.vTv, &t)
+
+	// ∇H = GᵀVᵀV(GH - I)
+	var gtA mat.Dense
+	gtA.Mul(u.G.T(), &u.vTv)
+	t.Reset()
+	t.Mul(&gtA, u.G)
+	gH = new(mat.Dense)
+	gH.Mul(&t, u.H)
+	gH.Sub(gH, &gtA)
+
+	return gG, gH
+}
+
+func (u *convex) projNorm() float64 {
+	gG, gH := u.gradients()
+	return projNorm(gG, u.G, gH, u.H)
+}
+
+func (u *convex) update(_ context.Context) (ok bool, err error) {
+	// H *= sqrt((Gᵀ[VᵀV]⁺ + Gᵀ[VᵀV]⁻GH) / (Gᵀ[VᵀV]⁻ + Gᵀ[VᵀV]⁺GH))
+	u.w.Reset()
+	u.w.Mul(u.G, u.H)
+	u.num.Reset()
+	u.tmp.Reset()
+	u.tmp.Mul(&u.neg, &u.w)
+	u.tmp.Add(&u.tmp, &u.pos)
+	u.num.Mul(u.G.T(), &u.tmp)
+	u.den.Reset()
+	u.tmp.Reset()
+	u.tmp.Mul(&u.pos, &u.w)
+	u.tmp.Add(&u.tmp, &u.neg)
+	u.den.Mul(u.G.T(), &u.tmp)
+	applyInPlace(sqrtRatio(&u.num, &u.den), u.H)
+
+	// G *= sqrt(([VᵀV]⁺Hᵀ + [VᵀV]⁻GHHᵀ) / ([VᵀV]⁻Hᵀ + [VᵀV]⁺GHHᵀ))
+	u.tmp.Reset()
+	u.tmp.Mul(u.H, u.H.T())
+	u.w.Reset()
+	u.w.Mul(u.G, &u.tmp)
+	u.num.Reset()
+	u.num.Mul(&u.pos, u.H.T())
+	u.den.Reset()
+	u.den.Mul(&u.neg, u.H.T())
+	u.tmp.Reset()
+	u.tmp.Mul(&u.neg, &u.w)
+	u.num.Add(&u.num, &u.tmp)
+	u.tmp.Reset()
+	u.tmp.Mul(&u.pos, &u.w)
+	u.den.Add(&u.den, &u.tmp)
+	applyInPlace(sqrtRatio(&u.num, &u.den), u.G)
+
+	return true, nil
+}
+
+func (u *convex) objective() float64 {
+	u.w.Reset()
+	u.w.Mul(u.V, u.G)
+	return frobenius(u.V, &u.w, u.H)
+}
+
+func (u *convex) factors() (G, H *mat.Dense) { return u.G, u.H }
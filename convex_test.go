@@ -0,0 +1,61 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestFactorsConvex(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	const rows, cols, k = 10, 30, 3
+
+	// Construct a mixed-sign V whose columns are
+	// noisy copies of k prototypes.
+	P := mat.NewDense(rows, k, nil)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < k; j++ {
+			P.Set(i, j, rnd.NormFloat64())
+		}
+	}
+	V := mat.NewDense(rows, cols, nil)
+	for j := 0; j < cols; j++ {
+		p := j % k
+		for i := 0; i < rows; i++ {
+			V.Set(i, j, P.At(i, p)+0.01*rnd.NormFloat64())
+		}
+	}
+
+	Go, _ := InitRandom(cols, cols, k, Uniform, rnd)
+	_, Ho := InitRandom(cols, cols, k, Uniform, rnd)
+	c := Config{
+		Tolerance: 1e-6,
+		MaxIter:   2000,
+		Limit:     time.Minute,
+		Validate:  true,
+	}
+	G, H, ok := FactorsConvex(V, Go, Ho, c)
+	if !ok {
+		t.Error("unexpected failed update")
+	}
+	for _, m := range []struct {
+		name string
+		m    *mat.Dense
+	}{{"G", G}, {"H", H}} {
+		if err := CheckNonNegative(m.m); err != nil {
+			t.Errorf("invalid %s: %v", m.name, err)
+		}
+	}
+
+	var W mat.Dense
+	W.Mul(V, G)
+	if d := ReconstructionError(V, &W, H, 2); d > 0.02*mat.Norm(V, 2) {
+		t.Errorf("unexpected reconstruction error: %v", d)
+	}
+}
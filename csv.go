@@ -0,0 +1,80 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// WriteCSV writes m to w as comma-separated values without a header, one
+// row of m per record. Values are written in the shortest form that reads
+// back to the same float64, so a matrix written by WriteCSV is recovered
+// exactly by ReadCSV.
+func WriteCSV(w io.Writer, m *mat.Dense) error {
+	cw := csv.NewWriter(w)
+	if !m.IsEmpty() {
+		r, c := m.Dims()
+		rec := make([]string, c)
+		for i := 0; i < r; i++ {
+			for j := range rec {
+				rec[j] = strconv.FormatFloat(m.At(i, j), 'g', -1, 64)
+			}
+			err := cw.Write(rec)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ReadCSV reads a header-less matrix of comma-separated values from r, one
+// row of the matrix per record. All records must have the same number of
+// fields. An input with no records returns an empty matrix.
+func ReadCSV(r io.Reader) (*mat.Dense, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+
+	var (
+		data []float64
+		rows int
+		cols int
+	)
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if rows == 0 {
+			cols = len(rec)
+		} else if len(rec) != cols {
+			return nil, fmt.Errorf("nmf: row %d has %d columns, want %d", rows, len(rec), cols)
+		}
+		for j, f := range rec {
+			v, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+			if err != nil {
+				return nil, fmt.Errorf("nmf: invalid entry at (%d, %d): %v", rows, j, err)
+			}
+			data = append(data, v)
+		}
+		rows++
+	}
+	if rows == 0 {
+		return new(mat.Dense), nil
+	}
+	return mat.NewDense(rows, cols, data), nil
+}
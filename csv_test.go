@@ -0,0 +1,67 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestCSV(t *testing.T) {
+	_, W, _ := testFactors()
+	m := mat.DenseCopyOf(W)
+	m.Set(0, 0, math.Pi)
+	m.Set(1, 0, 1e-300)
+
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, m)
+	if err != nil {
+		t.Fatalf("unexpected error writing CSV: %v", err)
+	}
+	got, err := ReadCSV(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error reading CSV: %v", err)
+	}
+	if !mat.Equal(got, m) {
+		t.Errorf("round trip mismatch:\ngot: %v\nwant:%v", mat.Formatted(got), mat.Formatted(m))
+	}
+
+	got, err = ReadCSV(strings.NewReader("1, 2\n3,4\n"))
+	if err != nil {
+		t.Fatalf("unexpected error reading CSV: %v", err)
+	}
+	if want := mat.NewDense(2, 2, []float64{1, 2, 3, 4}); !mat.Equal(got, want) {
+		t.Errorf("unexpected matrix:\ngot: %v\nwant:%v", mat.Formatted(got), mat.Formatted(want))
+	}
+
+	buf.Reset()
+	err = WriteCSV(&buf, new(mat.Dense))
+	if err != nil {
+		t.Fatalf("unexpected error writing empty CSV: %v", err)
+	}
+	got, err = ReadCSV(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error reading empty CSV: %v", err)
+	}
+	if !got.IsEmpty() {
+		t.Errorf("expected empty matrix: got:%v", mat.Formatted(got))
+	}
+
+	for _, in := range []string{
+		"1,2\n3\n",
+		"1,2\n3,4,5\n",
+		"1,x\n",
+		"1,\"2\n",
+	} {
+		_, err := ReadCSV(strings.NewReader(in))
+		if err == nil {
+			t.Errorf("expected error for %q", in)
+		}
+	}
+}
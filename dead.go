@@ -0,0 +1,213 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// components wraps an updater, tracking the components of the
+// factorisation that have collapsed to zero and optionally
+// reviving them to fit the unexplained residual.
+type components struct {
+	updater
+
+	V mat.Matrix
+
+	// revive specifies that dead components
+	// are reinitialised after each update.
+	revive bool
+
+	// maxW and maxH are the upper bounds
+	// of the entries of W and H.
+	maxW, maxH float64
+
+	// dead is the number of dead components
+	// at the current factors and revived is
+	// the number of components revived.
+	dead, revived int
+}
+
+func newComponents(u updater, V mat.Matrix, c Config) *components {
+	W, H := u.factors()
+	return &components{
+		updater: u,
+		V:       V,
+		revive:  c.ReviveDeadComponents,
+		maxW:    c.MaxW, maxH: c.MaxH,
+		dead: len(deadComponents(W, H)),
+	}
+}
+
+func (d *components) update(ctx context.Context) (ok bool, err error) {
+	ok, err = d.updater.update(ctx)
+	if err != nil {
+		return ok, err
+	}
+	W, H := d.updater.factors()
+	dead := deadComponents(W, H)
+	if d.revive && len(dead) != 0 {
+		n := reviveComponents(d.V, W, H, dead, d.maxW, d.maxH)
+		if n != 0 {
+			d.revived += n
+			if r, ok := d.updater.(refresher); ok {
+				r.refresh()
+			}
+			dead = deadComponents(W, H)
+		}
+	}
+	d.dead = len(dead)
+	return ok, nil
+}
+
+func (d *components) projGradients() (gW, gH *mat.Dense) {
+	if g, ok := d.updater.(projGradienter); ok {
+		return g.projGradients()
+	}
+	return nil, nil
+}
+
+func (d *components) subproblems() (wIter, hIter int, wOK, hOK bool) {
+	if s, ok := d.updater.(subproblemReporter); ok {
+		return s.subproblems()
+	}
+	return 0, 0, false, false
+}
+
+func (d *components) subproblemTimes() (w, h time.Duration) {
+	if s, ok := d.updater.(subproblemReporter); ok {
+		return s.subproblemTimes()
+	}
+	return 0, 0
+}
+
+// refresher is implemented by updaters that hold state derived
+// from the current factors, which must be refreshed when the
+// factors are altered outside of update.
+type refresher interface {
+	refresh()
+}
+
+// deadComponents returns the indices of the components of the
+// factorisation WH that are zero; those with either a zero
+// column of W or a zero row of H.
+func deadComponents(W, H *mat.Dense) []int {
+	r, k := W.Dims()
+	_, c := H.Dims()
+	var dead []int
+	for j := 0; j < k; j++ {
+		zero := true
+		for i := 0; i < r && zero; i++ {
+			zero = W.At(i, j) == 0
+		}
+		if !zero {
+			zero = true
+			for l := 0; l < c && zero; l++ {
+				zero = H.At(j, l) == 0
+			}
+		}
+		if zero {
+			dead = append(dead, j)
+		}
+	}
+	return dead
+}
+
+// reviveComponents reinitialises the dead components of W and H in
+// place to fit the largest positive part of the residual V-WH, and
+// returns the number of components revived. Each revived column of W
+// is the residual column with the largest positive part and the
+// corresponding row of H is its non-negative least squares fit. NaN
+// entries of V are ignored. Components are left dead if the residual
+// has no positive part.
+func reviveComponents(V mat.Matrix, W, H *mat.Dense, dead []int, maxW, maxH float64) int {
+	r, c := V.Dims()
+	var R mat.Dense
+	R.Mul(W, H)
+	R.Sub(V, &R)
+
+	projW := boxFilt(maxW)
+	projH := boxFilt(maxH)
+	var n int
+	for _, j := range dead {
+		best, col := 0.0, -1
+		for l := 0; l < c; l++ {
+			var s float64
+			for i := 0; i < r; i++ {
+				if v := R.At(i, l); v > 0 {
+					s += v * v
+				}
+			}
+			if s > best {
+				best, col = s, l
+			}
+		}
+		if col < 0 {
+			break
+		}
+
+		// The dead component contributes nothing
+		// to the residual, so it may be replaced.
+		var ww float64
+		for i := 0; i < r; i++ {
+			var w float64
+			if v := R.At(i, col); v > 0 {
+				w = projW(i, j, v)
+			}
+			W.Set(i, j, w)
+			ww += w * w
+		}
+		for l := 0; l < c; l++ {
+			var s float64
+			for i := 0; i < r; i++ {
+				if v := R.At(i, l); !math.IsNaN(v) {
+					s += W.At(i, j) * v
+				}
+			}
+			H.Set(j, l, projH(j, l, s/ww))
+		}
+
+		for i := 0; i < r; i++ {
+			w := W.At(i, j)
+			if w == 0 {
+				continue
+			}
+			for l := 0; l < c; l++ {
+				R.Set(i, l, R.At(i, l)-w*H.At(j, l))
+			}
+		}
+		n++
+	}
+	return n
+}
+
+// pruneComponents returns copies of W and H without the components
+// indexed by the sorted indices in dead. If all the components are
+// dead, empty matrices are returned.
+func pruneComponents(W, H *mat.Dense, dead []int) (Wp, Hp *mat.Dense) {
+	r, k := W.Dims()
+	_, c := H.Dims()
+	n := k - len(dead)
+	if n == 0 {
+		return new(mat.Dense), new(mat.Dense)
+	}
+	Wp = mat.NewDense(r, n, nil)
+	Hp = mat.NewDense(n, c, nil)
+	var i int
+	for j := 0; j < k; j++ {
+		if len(dead) != 0 && dead[0] == j {
+			dead = dead[1:]
+			continue
+		}
+		Wp.Slice(0, r, i, i+1).(*mat.Dense).Copy(W.Slice(0, r, j, j+1))
+		Hp.Slice(i, i+1, 0, c).(*mat.Dense).Copy(H.Slice(j, j+1, 0, c))
+		i++
+	}
+	return Wp, Hp
+}
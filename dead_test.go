@@ -0,0 +1,106 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestReviveDeadComponents(t *testing.T) {
+	const k = 5
+	V, _, _ := lowRank(20, 15, 3, rand.NewSource(1))
+	Wo, Ho := InitRandom(20, 15, k, Uniform, rand.NewSource(2))
+	// Kill two components of the initial solution.
+	// Multiplicative updates cannot recover them.
+	for i := 0; i < 20; i++ {
+		Wo.Set(i, 3, 0)
+		Wo.Set(i, 4, 0)
+	}
+
+	c := testConfig
+	c.Method = MultiplicativeUpdate
+	c.MaxIter = 20
+	W, H, res := FactorsResult(V, Wo, Ho, c)
+	if res.DeadComponents != 2 || res.RevivedComponents != 0 {
+		t.Errorf("unexpected component counts without revival: dead=%d revived=%d", res.DeadComponents, res.RevivedComponents)
+	}
+	e := ReconstructionError(V, W, H, 2)
+
+	c.ReviveDeadComponents = true
+	for _, method := range []Method{ProjectedGradient, MultiplicativeUpdate, HALS} {
+		c.Method = method
+		W, H, res := FactorsResult(V, Wo, Ho, c)
+		if res.DeadComponents != 0 {
+			t.Errorf("unexpected dead components for method %d: %d", method, res.DeadComponents)
+		}
+		if method == MultiplicativeUpdate {
+			if res.RevivedComponents < 2 {
+				t.Errorf("unexpected number of revived components: %d", res.RevivedComponents)
+			}
+			if got := ReconstructionError(V, W, H, 2); got >= e {
+				t.Errorf("revival did not reduce error: got:%v without:%v", got, e)
+			}
+		}
+		if err := CheckNonNegative(W); err != nil {
+			t.Errorf("invalid W for method %d: %v", method, err)
+		}
+		if err := CheckNonNegative(H); err != nil {
+			t.Errorf("invalid H for method %d: %v", method, err)
+		}
+	}
+}
+
+func TestPruneZeroComponents(t *testing.T) {
+	const k = 5
+	V, _, _ := lowRank(20, 15, 3, rand.NewSource(1))
+	Wo, Ho := InitRandom(20, 15, k, Uniform, rand.NewSource(2))
+	for i := 0; i < 20; i++ {
+		Wo.Set(i, 1, 0)
+		Wo.Set(i, 3, 0)
+	}
+
+	c := testConfig
+	c.Method = MultiplicativeUpdate
+	c.MaxIter = 20
+	c.Limit = 0
+	W, H, want := FactorsResult(V, Wo, Ho, c)
+	if want.Rank != k {
+		t.Errorf("unexpected rank without pruning: got:%d want:%d", want.Rank, k)
+	}
+	var WH mat.Dense
+	WH.Mul(W, H)
+
+	c.PruneZeroComponents = true
+	Wp, Hp, res := FactorsResult(V, Wo, Ho, c)
+	if res.Rank != k-2 || res.DeadComponents != 2 {
+		t.Errorf("unexpected pruning: rank=%d dead=%d", res.Rank, res.DeadComponents)
+	}
+	if _, wc := Wp.Dims(); wc != res.Rank {
+		t.Errorf("unexpected W columns: got:%d want:%d", wc, res.Rank)
+	}
+	if hr, _ := Hp.Dims(); hr != res.Rank {
+		t.Errorf("unexpected H rows: got:%d want:%d", hr, res.Rank)
+	}
+	var WHp mat.Dense
+	WHp.Mul(Wp, Hp)
+	if !mat.EqualApprox(&WH, &WHp, 1e-14) {
+		t.Error("pruning altered the reconstruction")
+	}
+
+	// FactorsInto retains the shapes of its arguments.
+	Wi, Hi := mat.DenseCopyOf(Wo), mat.DenseCopyOf(Ho)
+	FactorsInto(Wi, Hi, V, c)
+	if !mat.Equal(Wi, W) || !mat.Equal(Hi, H) {
+		t.Error("unexpected factors from FactorsInto with pruning")
+	}
+
+	W, H = pruneComponents(W, H, []int{0, 1, 2, 3, 4})
+	if !W.IsEmpty() || !H.IsEmpty() {
+		t.Error("expected empty factors when all components are pruned")
+	}
+}
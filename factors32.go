@@ -0,0 +1,241 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/blas"
+	"gonum.org/v1/gonum/blas/blas32"
+)
+
+// Factors32 is a single precision implementation of Factors using the projected
+// gradient method. It returns matrices W and H that are non-negative factors of V
+// within the specified tolerance and computation limits given initial non-negative
+// solutions Wo and Ho, halving memory use at the cost of precision.
+//
+// Only the Tolerance, MaxIter, Limit, MaxOuterSub and MaxInnerSub fields of c are
+// used. Single precision limits the attainable accuracy of the projected gradient,
+// so c.Tolerance should not be less than about 1e-4 to avoid factorisations that
+// run to MaxIter or Limit without converging.
+//
+// Factors32 panics if the dimensions of V, Wo and Ho are not compatible.
+func Factors32(V, Wo, Ho blas32.General, c Config) (W, H blas32.General, ok bool) {
+	to := c.now()
+
+	if Wo.Cols != Ho.Rows || V.Rows != Wo.Rows || V.Cols != Ho.Cols {
+		panic("nmf: dimension mismatch")
+	}
+
+	W = clone32(Wo)
+	H = clone32(Ho)
+
+	// ∇W = WHHᵀ - VHᵀ
+	gW := newGeneral32(W.Rows, W.Cols)
+	hhT := newGeneral32(H.Rows, H.Rows)
+	blas32.Gemm(blas.NoTrans, blas.Trans, 1, H, H, 0, hhT)
+	blas32.Gemm(blas.NoTrans, blas.Trans, -1, V, H, 0, gW)
+	blas32.Gemm(blas.NoTrans, blas.NoTrans, 1, W, hhT, 1, gW)
+
+	// ∇H = WᵀWH - WᵀV
+	gH := newGeneral32(H.Rows, H.Cols)
+	wTw := newGeneral32(W.Cols, W.Cols)
+	blas32.Gemm(blas.Trans, blas.NoTrans, 1, W, W, 0, wTw)
+	blas32.Gemm(blas.Trans, blas.NoTrans, -1, W, V, 0, gH)
+	blas32.Gemm(blas.NoTrans, blas.NoTrans, 1, wTw, H, 1, gH)
+
+	grad := norm32(gW, gH)
+	tolW := math.Max(0.001, c.Tolerance) * grad
+	tolH := tolW
+
+	var (
+		_ok  bool
+		iter int
+	)
+	for i := 0; ; i++ {
+		decFilt32(gW, W)
+		decFilt32(gH, H)
+		proj := norm32(gW, gH)
+		if proj < c.Tolerance*grad {
+			break
+		}
+		if i >= c.MaxIter || c.timedOut(to) {
+			break
+		}
+
+		// Solve for Wᵀ in HᵀWᵀ = Vᵀ.
+		blas32.Gemm(blas.NoTrans, blas.Trans, 1, H, H, 0, hhT)
+		hvT := newGeneral32(H.Rows, V.Rows)
+		blas32.Gemm(blas.NoTrans, blas.Trans, 1, H, V, 0, hvT)
+		var wT, gWT blas32.General
+		wT, gWT, iter, ok = nnlsSubproblem32(hhT, hvT, transpose32(W), tolW, c.MaxOuterSub, c.MaxInnerSub)
+		if iter == 0 {
+			tolW *= 0.1
+		}
+		W = transpose32(wT)
+		gW = transpose32(gWT)
+
+		blas32.Gemm(blas.Trans, blas.NoTrans, 1, W, W, 0, wTw)
+		wTv := newGeneral32(W.Cols, V.Cols)
+		blas32.Gemm(blas.Trans, blas.NoTrans, 1, W, V, 0, wTv)
+		H, gH, iter, _ok = nnlsSubproblem32(wTw, wTv, H, tolH, c.MaxOuterSub, c.MaxInnerSub)
+		ok = ok && _ok
+		if iter == 0 {
+			tolH *= 0.1
+		}
+	}
+
+	// A diverged factorisation is not valid
+	// whatever the updates reported.
+	ok = ok && isFinite32(W) && isFinite32(H)
+	return W, H, ok
+}
+
+// isFinite32 returns whether all the entries of m are finite.
+func isFinite32(m blas32.General) bool {
+	for i := 0; i < m.Rows; i++ {
+		for _, v := range m.Data[i*m.Stride : i*m.Stride+m.Cols] {
+			if v != v || v > math.MaxFloat32 || v < -math.MaxFloat32 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// nnlsSubproblem32 is the single precision equivalent of nnlsSubproblem.
+// It solves min ||AX - B|| for X >= 0 given the Gram matrix AᵀA and AᵀB.
+func nnlsSubproblem32(AtA, AtB, Xo blas32.General, tol float64, outer, inner int) (X, G blas32.General, i int, ok bool) {
+	X = clone32(Xo)
+
+	alpha, beta := float32(1), float32(0.1)
+
+	G = newGeneral32(X.Rows, X.Cols)
+	d := newGeneral32(X.Rows, X.Cols)
+	dQ := newGeneral32(X.Rows, X.Cols)
+	for i = 0; i < outer; i++ {
+		copy(G.Data, AtB.Data)
+		blas32.Gemm(blas.NoTrans, blas.NoTrans, 1, AtA, X, -1, G)
+		decFilt32(G, X)
+
+		if norm32(G) < tol {
+			break
+		}
+
+		var (
+			reduce bool
+			Xp     blas32.General
+		)
+		for j := 0; j < inner; j++ {
+			Xn := newGeneral32(X.Rows, X.Cols)
+			for k, v := range X.Data {
+				v -= alpha * G.Data[k]
+				if v < 0 {
+					v = 0
+				}
+				Xn.Data[k] = v
+				d.Data[k] = v - X.Data[k]
+			}
+			blas32.Gemm(blas.NoTrans, blas.NoTrans, 1, AtA, d, 0, dQ)
+
+			// Accumulate in double precision so that the
+			// test is not swamped by rounding for large
+			// problems.
+			var gd, dQd float64
+			for k, v := range d.Data {
+				gd += float64(G.Data[k]) * float64(v)
+				dQd += float64(dQ.Data[k]) * float64(v)
+			}
+			sufficient := 0.99*gd+0.5*dQd < 0
+
+			if j == 0 {
+				reduce = !sufficient
+				Xp = X
+			}
+			if reduce {
+				if sufficient {
+					X = Xn
+					ok = true
+					break
+				} else {
+					alpha *= beta
+				}
+			} else {
+				if !sufficient || equal32(Xp, Xn) {
+					X = Xp
+					break
+				} else {
+					alpha /= beta
+					Xp = Xn
+				}
+			}
+		}
+	}
+
+	return X, G, i, ok
+}
+
+// newGeneral32 returns a zeroed r×c matrix.
+func newGeneral32(r, c int) blas32.General {
+	return blas32.General{Rows: r, Cols: c, Stride: c, Data: make([]float32, r*c)}
+}
+
+// clone32 returns a copy of m with a stride equal to its number
+// of columns.
+func clone32(m blas32.General) blas32.General {
+	n := newGeneral32(m.Rows, m.Cols)
+	for i := 0; i < m.Rows; i++ {
+		copy(n.Data[i*n.Stride:(i+1)*n.Stride], m.Data[i*m.Stride:i*m.Stride+m.Cols])
+	}
+	return n
+}
+
+// transpose32 returns the transpose of m.
+func transpose32(m blas32.General) blas32.General {
+	t := newGeneral32(m.Cols, m.Rows)
+	for i := 0; i < m.Rows; i++ {
+		for j, v := range m.Data[i*m.Stride : i*m.Stride+m.Cols] {
+			t.Data[j*t.Stride+i] = v
+		}
+	}
+	return t
+}
+
+// decFilt32 is the single precision equivalent of decFilt, projecting
+// the gradient g of m in place. Both g and m must have a stride equal
+// to their number of columns.
+func decFilt32(g, m blas32.General) {
+	for i, v := range g.Data {
+		if v >= 0 && m.Data[i] <= 0 {
+			g.Data[i] = 0
+		}
+	}
+}
+
+// norm32 returns the Frobenius norm of the matrix formed by stacking
+// the matrices in m. The matrices must have a stride equal to their
+// number of columns. The sum of squares is accumulated in double
+// precision.
+func norm32(m ...blas32.General) float64 {
+	var n float64
+	for _, g := range m {
+		for _, v := range g.Data {
+			n += float64(v) * float64(v)
+		}
+	}
+	return math.Sqrt(n)
+}
+
+// equal32 returns whether a and b have identical elements. Both a
+// and b must have the same dimensions and have a stride equal to their
+// number of columns.
+func equal32(a, b blas32.General) bool {
+	for i, v := range a.Data {
+		if b.Data[i] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// graph is the graph regularisation penalty
+//
+//	λ/2 * tr(H L Hᵀ)
+//
+// on the columns of H, given a graph Laplacian L.
+type graph struct {
+	lambda float64
+
+	// L is the Laplacian, and pos and neg are its
+	// positive and negative parts, L = pos - neg.
+	L, pos, neg *mat.Dense
+
+	tmp mat.Dense
+}
+
+// newGraph returns the graph penalty for c, or nil if c does not
+// specify graph regularisation. newGraph panics if the Laplacian is
+// not an n×n matrix for the n columns of V.
+func newGraph(V mat.Matrix, c Config) *graph {
+	if c.GraphLaplacian == nil || c.GraphLambda == 0 {
+		return nil
+	}
+	_, n := V.Dims()
+	lr, lc := c.GraphLaplacian.Dims()
+	if lr != n || lc != n {
+		panic(fmt.Sprintf("nmf: graph Laplacian dimensions (%d×%d) must match V columns (%d)", lr, lc, n))
+	}
+	g := &graph{lambda: c.GraphLambda, L: c.GraphLaplacian}
+	g.pos = new(mat.Dense)
+	g.pos.Apply(posPart, g.L)
+	g.neg = new(mat.Dense)
+	g.neg.Apply(negPart, g.L)
+	return g
+}
+
+// addGradient adds the gradient of the penalty at H, λ H L, to gH.
+func (g *graph) addGradient(gH, H *mat.Dense) {
+	if g == nil {
+		return
+	}
+	g.addTerm(gH, H, g.L)
+}
+
+// addTerm adds λ H P to dst.
+func (g *graph) addTerm(dst, H, P *mat.Dense) {
+	g.tmp.Reset()
+	g.tmp.Mul(H, P)
+	g.tmp.Scale(g.lambda, &g.tmp)
+	dst.Add(dst, &g.tmp)
+}
+
+// value returns the value of the penalty at H.
+func (g *graph) value(H *mat.Dense) float64 {
+	if g == nil {
+		return 0
+	}
+	g.tmp.Reset()
+	g.tmp.Mul(H, g.L)
+	g.tmp.MulElem(&g.tmp, H)
+	return 0.5 * g.lambda * mat.Sum(&g.tmp)
+}
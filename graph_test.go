@@ -0,0 +1,124 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestGraphLaplacian(t *testing.T) {
+	const (
+		rows = 8
+		cols = 20
+		k    = 2
+	)
+	rnd := rand.New(rand.NewSource(1))
+	cluster := func(j int) int { return j * 2 / cols }
+
+	// Two clusters of noisy columns.
+	V := mat.NewDense(rows, cols, nil)
+	for j := 0; j < cols; j++ {
+		for i := 0; i < rows; i++ {
+			v := rnd.Float64()
+			if i%2 == cluster(j) {
+				v += 2
+			}
+			V.Set(i, j, v)
+		}
+	}
+
+	// The Laplacian of the graph connecting
+	// the columns within each cluster.
+	L := mat.NewDense(cols, cols, nil)
+	for i := 0; i < cols; i++ {
+		for j := 0; j < cols; j++ {
+			if i != j && cluster(i) == cluster(j) {
+				L.Set(i, j, -1)
+				L.Set(i, i, L.At(i, i)+1)
+			}
+		}
+	}
+
+	Wo, Ho := InitRandom(rows, cols, k, Uniform, rand.NewSource(2))
+	c := testConfig
+	c.MaxIter = 500
+	spread := func(lambda float64) float64 {
+		c.GraphLaplacian = L
+		c.GraphLambda = lambda
+		_, H, _ := Factors(V, Wo, Ho, c)
+		return clusterSpread(H, cluster)
+	}
+	plain := spread(0)
+	regularised := spread(10)
+	if regularised >= plain {
+		t.Errorf("graph regularisation did not tighten encodings: got:%v without:%v", regularised, plain)
+	}
+}
+
+// clusterSpread returns the mean squared distance of the columns of
+// H, normalised to unit sum, from the mean of their cluster.
+func clusterSpread(H *mat.Dense, cluster func(int) int) float64 {
+	k, n := H.Dims()
+	norm := mat.NewDense(k, n, nil)
+	for j := 0; j < n; j++ {
+		col := H.Slice(0, k, j, j+1).(*mat.Dense)
+		norm.Slice(0, k, j, j+1).(*mat.Dense).Scale(1/math.Max(mat.Sum(col), 1e-12), col)
+	}
+	means := make(map[int][]float64)
+	counts := make(map[int]int)
+	for j := 0; j < n; j++ {
+		m := means[cluster(j)]
+		if m == nil {
+			m = make([]float64, k)
+			means[cluster(j)] = m
+		}
+		for i := range m {
+			m[i] += norm.At(i, j)
+		}
+		counts[cluster(j)]++
+	}
+	var s float64
+	for j := 0; j < n; j++ {
+		m := means[cluster(j)]
+		for i := range m {
+			d := norm.At(i, j) - m[i]/float64(counts[cluster(j)])
+			s += d * d
+		}
+	}
+	return s / float64(n)
+}
+
+func TestGraphGradient(t *testing.T) {
+	L := mat.NewDense(3, 3, []float64{
+		1, -1, 0,
+		-1, 2, -1,
+		0, -1, 1,
+	})
+	V := mat.NewDense(2, 3, nil)
+	H := mat.NewDense(2, 3, []float64{1, 2, 3, 0.5, 0.1, 2})
+	g := newGraph(V, Config{GraphLaplacian: L, GraphLambda: 0.7})
+
+	// Compare the gradient with a central difference.
+	gH := mat.NewDense(2, 3, nil)
+	g.addGradient(gH, H)
+	const h = 1e-6
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 3; j++ {
+			v := H.At(i, j)
+			H.Set(i, j, v+h)
+			f1 := g.value(H)
+			H.Set(i, j, v-h)
+			f0 := g.value(H)
+			H.Set(i, j, v)
+			if want := (f1 - f0) / (2 * h); math.Abs(gH.At(i, j)-want) > 1e-6 {
+				t.Errorf("unexpected gradient at (%d, %d): got:%v want:%v", i, j, gH.At(i, j), want)
+			}
+		}
+	}
+}
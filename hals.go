@@ -0,0 +1,99 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"context"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// hierarchicalALS is the hierarchical alternating least squares
+// update rule for the Frobenius norm objective.
+type hierarchicalALS struct {
+	V, W, H *mat.Dense
+	pW, pH  penalty
+
+	// maxW and maxH are the upper bounds
+	// of the entries of W and H.
+	maxW, maxH float64
+
+	gram, prod mat.Dense
+}
+
+func newHierarchicalALS(V, Wo, Ho *mat.Dense, pW, pH penalty) *hierarchicalALS {
+	h := &hierarchicalALS{V: V, W: new(mat.Dense), H: new(mat.Dense), pW: pW, pH: pH}
+	h.W.CloneFrom(Wo)
+	h.H.CloneFrom(Ho)
+	return h
+}
+
+func (h *hierarchicalALS) projNorm() float64 {
+	gW, gH := gradients(h.V, h.W, h.H, h.pW, h.pH)
+	return boxProjNorm(gW, h.W, h.maxW, gH, h.H, h.maxH)
+}
+
+func (h *hierarchicalALS) projGradients() (gW, gH *mat.Dense) {
+	gW, gH = gradients(h.V, h.W, h.H, h.pW, h.pH)
+	projectGradient(gW, h.W, h.maxW)
+	projectGradient(gH, h.H, h.maxH)
+	return gW, gH
+}
+
+func (h *hierarchicalALS) update(_ context.Context) (ok bool, err error) {
+	_, n := h.W.Dims()
+
+	// h_k = max(0, h_k + ((WᵀV)_k - (WᵀW)_k H) / (WᵀW)_kk)
+	project := boxFilt(h.maxH)
+	h.gram.Reset()
+	h.gram.Mul(h.W.T(), h.W)
+	h.pH.addGram(&h.gram)
+	h.prod.Reset()
+	h.prod.Mul(h.W.T(), h.V)
+	_, c := h.H.Dims()
+	for k := 0; k < n; k++ {
+		d := h.gram.At(k, k)
+		if d == 0 {
+			continue
+		}
+		for j := 0; j < c; j++ {
+			v := h.prod.At(k, j) - h.pH.l1
+			for l := 0; l < n; l++ {
+				v -= h.gram.At(k, l) * h.H.At(l, j)
+			}
+			h.H.Set(k, j, project(k, j, h.H.At(k, j)+v/d))
+		}
+	}
+
+	// w_k = max(0, w_k + ((VHᵀ)_k - W(HHᵀ)_k) / (HHᵀ)_kk)
+	project = boxFilt(h.maxW)
+	h.gram.Reset()
+	h.gram.Mul(h.H, h.H.T())
+	h.pW.addGram(&h.gram)
+	h.prod.Reset()
+	h.prod.Mul(h.V, h.H.T())
+	r, _ := h.W.Dims()
+	for k := 0; k < n; k++ {
+		d := h.gram.At(k, k)
+		if d == 0 {
+			continue
+		}
+		for i := 0; i < r; i++ {
+			v := h.prod.At(i, k) - h.pW.l1
+			for l := 0; l < n; l++ {
+				v -= h.W.At(i, l) * h.gram.At(l, k)
+			}
+			h.W.Set(i, k, project(i, k, h.W.At(i, k)+v/d))
+		}
+	}
+
+	return true, nil
+}
+
+func (h *hierarchicalALS) objective() float64 {
+	return frobenius(h.V, h.W, h.H) + h.pW.value(h.W) + h.pH.value(h.H)
+}
+
+func (h *hierarchicalALS) factors() (W, H *mat.Dense) { return h.W, h.H }
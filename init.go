@@ -0,0 +1,225 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math"
+	"math/rand"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Distribution specifies the distribution of random initial factor values.
+type Distribution int
+
+const (
+	// Uniform specifies values drawn uniformly from [0, 1).
+	Uniform Distribution = iota
+
+	// HalfNormal specifies values drawn from the absolute value
+	// of a standard normal distribution.
+	HalfNormal
+)
+
+// InitRandom returns initial non-negative factors Wo and Ho with k components
+// for a rows×cols matrix. The entries of the factors are drawn from the given
+// distribution using src, filling Wo and then Ho in row-major order.
+func InitRandom(rows, cols, k int, dist Distribution, src rand.Source) (Wo, Ho *mat.Dense) {
+	rnd := rand.New(src)
+	var fn func(_, _ int, _ float64) float64
+	switch dist {
+	case Uniform:
+		fn = func(_, _ int, _ float64) float64 { return rnd.Float64() }
+	case HalfNormal:
+		fn = func(_, _ int, _ float64) float64 { return math.Abs(rnd.NormFloat64()) }
+	default:
+		panic("nmf: unknown distribution")
+	}
+
+	Wo = mat.NewDense(rows, k, nil)
+	Wo.Apply(fn, Wo)
+	Ho = mat.NewDense(k, cols, nil)
+	Ho.Apply(fn, Ho)
+
+	return Wo, Ho
+}
+
+// NNDSVDFill specifies how zero entries of an NNDSVD initialisation are filled.
+type NNDSVDFill int
+
+const (
+	// NNDSVD leaves zero entries unaltered.
+	NNDSVD NNDSVDFill = iota
+
+	// NNDSVDa fills zero entries with the mean of the entries of V.
+	NNDSVDa
+
+	// NNDSVDe fills zero entries with small random values drawn
+	// uniformly from [0, m/100), where m is the mean of the
+	// entries of V.
+	NNDSVDe
+)
+
+// InitNNDSVD returns initial non-negative factors Wo and Ho of V with k
+// components using the non-negative double singular value decomposition
+// described in:
+//
+// C. Boutsidis and E. Gallopoulos (2008) 'SVD based initialization: A head
+// start for nonnegative matrix factorization.' Pattern Recognition 41:1350.
+//
+// Zero entries of the returned factors are filled according to fill. The
+// random values of the NNDSVDe fill are drawn from src in row-major order of
+// Wo and then Ho; src is not used by the other fills and may be nil.
+// InitNNDSVD panics if k is less than one or greater than the smaller
+// dimension of V, if the singular value decomposition of V fails, or if
+// fill is NNDSVDe and src is nil.
+func InitNNDSVD(V *mat.Dense, k int, fill NNDSVDFill, src rand.Source) (Wo, Ho *mat.Dense) {
+	r, c := V.Dims()
+	if k < 1 || k > r || k > c {
+		panic("nmf: invalid rank for NNDSVD initialisation")
+	}
+
+	var svd mat.SVD
+	if !svd.Factorize(V, mat.SVDThin) {
+		panic("nmf: singular value decomposition failed")
+	}
+	var u, v mat.Dense
+	svd.UTo(&u)
+	svd.VTo(&v)
+	s := svd.Values(nil)
+
+	Wo = mat.NewDense(r, k, nil)
+	Ho = mat.NewDense(k, c, nil)
+
+	xp := make([]float64, r)
+	yp := make([]float64, c)
+	xn := make([]float64, r)
+	yn := make([]float64, c)
+	for j := 0; j < k; j++ {
+		mat.Col(xp, j, &u)
+		mat.Col(yp, j, &v)
+
+		if j == 0 {
+			// The leading singular vectors of a non-negative
+			// matrix can be chosen to be non-negative.
+			lambda := math.Sqrt(s[0])
+			for i, e := range xp {
+				Wo.Set(i, 0, lambda*math.Abs(e))
+			}
+			for i, e := range yp {
+				Ho.Set(0, i, lambda*math.Abs(e))
+			}
+			continue
+		}
+
+		xpNorm, xnNorm := split(xp, xn)
+		ypNorm, ynNorm := split(yp, yn)
+
+		// Use the pair of positive or negative sections
+		// with the larger product of norms.
+		x, y := xp, yp
+		xNorm, yNorm := xpNorm, ypNorm
+		if xnNorm*ynNorm >= xpNorm*ypNorm {
+			x, y = xn, yn
+			xNorm, yNorm = xnNorm, ynNorm
+		}
+		sigma := xNorm * yNorm
+		if sigma == 0 {
+			continue
+		}
+
+		lambda := math.Sqrt(s[j] * sigma)
+		for i, e := range x {
+			Wo.Set(i, j, lambda*e/xNorm)
+		}
+		for i, e := range y {
+			Ho.Set(j, i, lambda*e/yNorm)
+		}
+	}
+
+	var fillZero func(_, _ int, v float64) float64
+	switch fill {
+	case NNDSVD:
+		return Wo, Ho
+	case NNDSVDa:
+		f := mat.Sum(V) / float64(r*c)
+		fillZero = func(_, _ int, v float64) float64 {
+			if v == 0 {
+				return f
+			}
+			return v
+		}
+	case NNDSVDe:
+		if src == nil {
+			panic("nmf: nil source for NNDSVDe fill")
+		}
+		f := mat.Sum(V) / float64(r*c) / 100
+		rnd := rand.New(src)
+		fillZero = func(_, _ int, v float64) float64 {
+			if v == 0 {
+				return f * rnd.Float64()
+			}
+			return v
+		}
+	default:
+		panic("nmf: unknown NNDSVD fill")
+	}
+	Wo.Apply(fillZero, Wo)
+	Ho.Apply(fillZero, Ho)
+
+	return Wo, Ho
+}
+
+// InitNNDSVDar returns initial non-negative factors Wo and Ho of V with k
+// components using the non-negative double singular value decomposition as
+// for InitNNDSVD with the NNDSVDe fill, drawing the fill values from src.
+// This is the NNDSVDar variant described by Boutsidis and Gallopoulos. The
+// small random fill retains the structure of the deterministic
+// initialisation while moving the factors off the saddle points at which
+// zero entries can leave components dead. InitNNDSVDar panics under the
+// same conditions as InitNNDSVD.
+func InitNNDSVDar(V *mat.Dense, k int, src rand.Source) (Wo, Ho *mat.Dense) {
+	return InitNNDSVD(V, k, NNDSVDe, src)
+}
+
+// split splits x into its positive and negative parts, leaving the
+// positive part in x and storing the magnitude of the negative part
+// in neg. The Euclidean norms of the two parts are returned.
+func split(x, neg []float64) (posNorm, negNorm float64) {
+	for i, v := range x {
+		if v < 0 {
+			x[i] = 0
+			neg[i] = -v
+			negNorm += v * v
+		} else {
+			neg[i] = 0
+			posNorm += v * v
+		}
+	}
+	return math.Sqrt(posNorm), math.Sqrt(negNorm)
+}
+
+// Fit returns matrices W and H that are rank k non-negative factors of V within
+// the specified tolerance and computation limits. The initial solutions are drawn
+// uniformly from [0, 1) using the random source described for Config.Seed, and
+// are then refined by Factors.
+// Fit panics if k is not positive.
+func Fit(V *mat.Dense, k int, c Config) (W, H *mat.Dense, ok bool) {
+	if k <= 0 {
+		panic(errZeroRank)
+	}
+	if V.IsEmpty() {
+		return Factors(V, new(mat.Dense), new(mat.Dense), c)
+	}
+	Wo, Ho := fitInit(V, k, c)
+	return Factors(V, Wo, Ho, c)
+}
+
+// fitInit returns the initial solutions used by Fit for a rank k
+// factorisation of V.
+func fitInit(V *mat.Dense, k int, c Config) (Wo, Ho *mat.Dense) {
+	r, n := V.Dims()
+	return InitRandom(r, n, k, Uniform, c.source())
+}
@@ -0,0 +1,230 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestInitNNDSVD(t *testing.T) {
+	// A rank one non-negative matrix is exactly
+	// reconstructed by a rank one initialisation.
+	var V mat.Dense
+	V.Mul(
+		mat.NewDense(3, 1, []float64{1, 2, 3}),
+		mat.NewDense(1, 4, []float64{4, 0, 5, 6}),
+	)
+	Wo, Ho := InitNNDSVD(&V, 1, NNDSVD, nil)
+	if d := ReconstructionError(&V, Wo, Ho, 2); d > 1e-12 {
+		t.Errorf("unexpected rank one reconstruction error: %v", d)
+	}
+
+	V0, _, _ := testFactors()
+	for _, fill := range []NNDSVDFill{NNDSVD, NNDSVDa, NNDSVDe} {
+		Wo, Ho := InitNNDSVD(V0, 3, fill, rand.NewSource(1))
+		if r, c := Wo.Dims(); r != 3 || c != 3 {
+			t.Errorf("unexpected Wo dimensions for fill %d: %d×%d", fill, r, c)
+		}
+		if r, c := Ho.Dims(); r != 3 || c != 4 {
+			t.Errorf("unexpected Ho dimensions for fill %d: %d×%d", fill, r, c)
+		}
+		for _, m := range []*mat.Dense{Wo, Ho} {
+			if err := CheckNonNegative(m); err != nil {
+				t.Errorf("invalid initialisation for fill %d: %v", fill, err)
+			}
+			if fill != NNDSVD && mat.Min(m) == 0 {
+				t.Errorf("unexpected zero entry for fill %d", fill)
+			}
+		}
+
+		W, H, _ := Factors(V0, Wo, Ho, testConfig)
+		if d := ReconstructionError(V0, W, H, 2); d > 0.05 {
+			t.Errorf("unexpected reconstruction error for fill %d: %v", fill, d)
+		}
+
+		Wr, Hr := InitNNDSVD(V0, 3, fill, rand.NewSource(1))
+		if !mat.Equal(Wo, Wr) || !mat.Equal(Ho, Hr) {
+			t.Errorf("initialisation not deterministic for fill %d", fill)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for NNDSVDe fill with nil source")
+		}
+	}()
+	InitNNDSVD(V0, 3, NNDSVDe, nil)
+}
+
+func TestInitNNDSVDar(t *testing.T) {
+	V, _, _ := testFactors()
+	r, c := V.Dims()
+	bound := mat.Sum(V) / float64(r*c) / 100
+	Wz, Hz := InitNNDSVD(V, 3, NNDSVD, nil)
+	Wo, Ho := InitNNDSVDar(V, 3, rand.NewSource(1))
+	for _, m := range []struct {
+		name       string
+		zero, fill *mat.Dense
+	}{
+		{name: "Wo", zero: Wz, fill: Wo},
+		{name: "Ho", zero: Hz, fill: Ho},
+	} {
+		if err := CheckNonNegative(m.fill); err != nil {
+			t.Errorf("invalid initialisation of %s: %v", m.name, err)
+		}
+		r, c := m.zero.Dims()
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				z, f := m.zero.At(i, j), m.fill.At(i, j)
+				switch {
+				case z != 0 && f != z:
+					t.Errorf("non-zero entry of %s altered at (%d, %d): got:%v want:%v", m.name, i, j, f, z)
+				case z == 0 && (f <= 0 || f >= bound):
+					t.Errorf("unexpected fill of %s at (%d, %d): got:%v want in (0, %v)", m.name, i, j, f, bound)
+				}
+			}
+		}
+	}
+
+	Wr, Hr := InitNNDSVDar(V, 3, rand.NewSource(1))
+	if !mat.Equal(Wo, Wr) || !mat.Equal(Ho, Hr) {
+		t.Error("initialisation not deterministic for the same source")
+	}
+
+	W, H, _ := Factors(V, Wo, Ho, testConfig)
+	if d := ReconstructionError(V, W, H, 2); d > 0.05 {
+		t.Errorf("unexpected reconstruction error: %v", d)
+	}
+}
+
+func BenchmarkInit(b *testing.B) {
+	V := mat.NewDense(3, 4, []float64{20, 0, 30, 0, 0, 16, 1, 9, 0, 10, 6, 11})
+	c := Config{
+		Tolerance:   1e-5,
+		MaxIter:     100,
+		MaxOuterSub: 1000,
+		MaxInnerSub: 20,
+		Limit:       time.Second,
+	}
+	for _, test := range []struct {
+		name string
+		init func(i int) (Wo, Ho *mat.Dense)
+	}{
+		{
+			name: "random",
+			init: func(i int) (Wo, Ho *mat.Dense) {
+				return InitRandom(3, 4, 3, Uniform, rand.NewSource(int64(i)))
+			},
+		},
+		{
+			name: "nndsvd",
+			init: func(int) (Wo, Ho *mat.Dense) { return InitNNDSVD(V, 3, NNDSVD, nil) },
+		},
+		{
+			name: "nndsvdar",
+			init: func(i int) (Wo, Ho *mat.Dense) { return InitNNDSVDar(V, 3, rand.NewSource(int64(i))) },
+		},
+	} {
+		b.Run(test.name, func(b *testing.B) {
+			var iter, dead int
+			for i := 0; i < b.N; i++ {
+				Wo, Ho := test.init(i)
+				_, _, res := FactorsResult(V, Wo, Ho, c)
+				iter += res.Iterations
+				dead += res.DeadComponents
+			}
+			b.ReportMetric(float64(iter)/float64(b.N), "iterations/op")
+			b.ReportMetric(float64(dead)/float64(b.N), "dead/op")
+		})
+	}
+}
+
+func TestInitRandom(t *testing.T) {
+	for _, dist := range []Distribution{Uniform, HalfNormal} {
+		Wo, Ho := InitRandom(3, 4, 2, dist, rand.NewSource(1))
+		if r, c := Wo.Dims(); r != 3 || c != 2 {
+			t.Errorf("unexpected Wo dimensions for distribution %d: %d×%d", dist, r, c)
+		}
+		if r, c := Ho.Dims(); r != 2 || c != 4 {
+			t.Errorf("unexpected Ho dimensions for distribution %d: %d×%d", dist, r, c)
+		}
+		for _, m := range []*mat.Dense{Wo, Ho} {
+			if err := CheckNonNegative(m); err != nil {
+				t.Errorf("invalid initialisation for distribution %d: %v", dist, err)
+			}
+			if dist == Uniform && mat.Max(m) >= 1 {
+				t.Errorf("unexpected uniform value: %v", mat.Max(m))
+			}
+		}
+
+		Wr, Hr := InitRandom(3, 4, 2, dist, rand.NewSource(1))
+		if !mat.Equal(Wo, Wr) || !mat.Equal(Ho, Hr) {
+			t.Errorf("initialisation not reproducible for distribution %d", dist)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	V, _, _ := lowRank(10, 8, 3, rand.NewSource(1))
+	c := testConfig
+	c.Limit = 0
+	c.Rand = rand.NewSource(2)
+	W, H, ok := Fit(V, 3, c)
+	if !ok {
+		t.Error("fit did not converge")
+	}
+	if _, k := W.Dims(); k != 3 {
+		t.Errorf("unexpected rank: %d", k)
+	}
+	if rel := ReconstructionError(V, W, H, 2) / mat.Norm(V, 2); rel > 1e-3 {
+		t.Errorf("unexpected relative reconstruction error: %v", rel)
+	}
+
+	Wo, Ho := InitRandom(10, 8, 3, Uniform, rand.NewSource(2))
+	Wf, Hf, _ := Factors(V, Wo, Ho, c)
+	if !mat.Equal(W, Wf) || !mat.Equal(H, Hf) {
+		t.Error("fit does not match factorisation from the same source")
+	}
+
+	c.Rand = nil
+	W, H, _ = Fit(V, 3, c)
+	for _, m := range []*mat.Dense{W, H} {
+		if err := CheckNonNegative(m); err != nil {
+			t.Errorf("invalid factor with time-seeded source: %v", err)
+		}
+	}
+	if W, H, ok = Fit(new(mat.Dense), 3, c); !ok || !W.IsEmpty() || !H.IsEmpty() {
+		t.Error("unexpected result fitting empty matrix")
+	}
+}
+
+func TestFitSeed(t *testing.T) {
+	V, _, _ := lowRank(10, 8, 3, rand.NewSource(1))
+	c := testConfig
+	c.Limit = 0
+	c.Seed = 2
+	W1, H1, _ := Fit(V, 3, c)
+	W2, H2, _ := Fit(V, 3, c)
+	if !mat.Equal(W1, W2) || !mat.Equal(H1, H2) {
+		t.Error("identical seeds gave different factors")
+	}
+
+	c.Rand = rand.NewSource(2)
+	W, H, _ := Fit(V, 3, c)
+	if !mat.Equal(W, W1) || !mat.Equal(H, H1) {
+		t.Error("seed does not match source with the same seed")
+	}
+
+	c.Rand = nil
+	c.Seed = 3
+	W, H, _ = Fit(V, 3, c)
+	if mat.Equal(W, W1) && mat.Equal(H, H1) {
+		t.Error("different seeds gave identical factors")
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// FactorsJoint returns a matrix W and matrices Hs that are non-negative factors
+// of each of the matrices in Vs, such that W*Hs[j] approximates Vs[j], within the
+// specified tolerance and computation limits given initial non-negative solutions
+// Wo and Hos. The basis W is shared by all the matrices, so the gradient with
+// respect to W is aggregated across the views, while each Hs[j] is solved
+// against the shared basis. The factorisation is performed as described for
+// Factors on the matrix formed by placing the matrices in Vs side by side.
+//
+// Each matrix in Vs must have the same number of rows as Wo, and Hos[j] must
+// have the same number of columns as Vs[j] and the same number of rows as Wo has
+// columns. FactorsJoint panics if Vs is empty, if Vs and Hos differ in length or
+// if the dimensions of the inputs are not compatible.
+func FactorsJoint(Vs []*mat.Dense, Wo *mat.Dense, Hos []*mat.Dense, c Config) (W *mat.Dense, Hs []*mat.Dense, ok bool) {
+	if len(Vs) == 0 {
+		panic("nmf: no matrices to factorise")
+	}
+	if len(Vs) != len(Hos) {
+		panic("nmf: number of V and Ho matrices differ")
+	}
+	wr, k := Wo.Dims()
+	var cols int
+	for j, V := range Vs {
+		vr, vc := V.Dims()
+		hr, hc := Hos[j].Dims()
+		switch {
+		case vr != wr:
+			panic(fmt.Sprintf("nmf: dimension mismatch between Vs[%d] and Wo", j))
+		case hr != k || hc != vc:
+			panic(fmt.Sprintf("nmf: dimension mismatch between Vs[%d] and Hos[%d]", j, j))
+		}
+		cols += vc
+	}
+
+	V := mat.NewDense(wr, cols, nil)
+	Ho := mat.NewDense(k, cols, nil)
+	var j0 int
+	for j, v := range Vs {
+		_, vc := v.Dims()
+		V.Slice(0, wr, j0, j0+vc).(*mat.Dense).Copy(v)
+		Ho.Slice(0, k, j0, j0+vc).(*mat.Dense).Copy(Hos[j])
+		j0 += vc
+	}
+
+	W, H, ok := Factors(V, Wo, Ho, c)
+
+	Hs = make([]*mat.Dense, len(Vs))
+	j0 = 0
+	for j, v := range Vs {
+		_, vc := v.Dims()
+		Hs[j] = mat.DenseCopyOf(H.Slice(0, k, j0, j0+vc))
+		j0 += vc
+	}
+	return W, Hs, ok
+}
@@ -0,0 +1,77 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestFactorsJoint(t *testing.T) {
+	const (
+		rows = 30
+		k    = 3
+	)
+	src := rand.NewSource(1)
+	Wt, H1 := InitRandom(rows, 20, k, Uniform, src)
+	_, H2 := InitRandom(rows, 15, k, Uniform, src)
+	var V1, V2 mat.Dense
+	V1.Mul(Wt, H1)
+	V2.Mul(Wt, H2)
+
+	Wo, Ho1 := InitRandom(rows, 20, k, Uniform, src)
+	_, Ho2 := InitRandom(rows, 15, k, Uniform, src)
+
+	c := testConfig
+	c.Tolerance = 1e-9
+	c.MaxIter = 1000
+	W, Hs, _ := FactorsJoint([]*mat.Dense{&V1, &V2}, Wo, []*mat.Dense{Ho1, Ho2}, c)
+	if len(Hs) != 2 {
+		t.Fatalf("unexpected number of codings: got:%d want:2", len(Hs))
+	}
+	for j, V := range []*mat.Dense{&V1, &V2} {
+		_, vc := V.Dims()
+		if r, c := Hs[j].Dims(); r != k || c != vc {
+			t.Errorf("unexpected dimensions of Hs[%d]: got:%d×%d want:%d×%d", j, r, c, k, vc)
+		}
+		if e := RelativeResidual(V, W, Hs[j]); e > 1e-3 {
+			t.Errorf("unexpected relative residual for view %d: %v", j, e)
+		}
+	}
+	_, sims := MatchComponents(Wt, W)
+	for i, s := range sims {
+		if s < 0.99 {
+			t.Errorf("shared basis column %d not recovered: similarity=%v", i, s)
+		}
+	}
+}
+
+func TestFactorsJointPanics(t *testing.T) {
+	V := mat.NewDense(4, 3, nil)
+	Wo := mat.NewDense(4, 2, nil)
+	Ho := mat.NewDense(2, 3, nil)
+	for _, test := range []struct {
+		name string
+		Vs   []*mat.Dense
+		Wo   *mat.Dense
+		Hos  []*mat.Dense
+	}{
+		{name: "empty", Wo: Wo},
+		{name: "length", Vs: []*mat.Dense{V, V}, Wo: Wo, Hos: []*mat.Dense{Ho}},
+		{name: "rows", Vs: []*mat.Dense{V, mat.NewDense(5, 3, nil)}, Wo: Wo, Hos: []*mat.Dense{Ho, Ho}},
+		{name: "cols", Vs: []*mat.Dense{V, mat.NewDense(4, 2, nil)}, Wo: Wo, Hos: []*mat.Dense{Ho, Ho}},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for %s", test.name)
+				}
+			}()
+			FactorsJoint(test.Vs, test.Wo, test.Hos, testConfig)
+		}()
+	}
+}
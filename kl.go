@@ -0,0 +1,159 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"context"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// kullbackLeibler is the Lee and Seung multiplicative update
+// rule for the generalised Kullback-Leibler divergence objective.
+type kullbackLeibler struct {
+	V, W, H *mat.Dense
+
+	wh, q, num mat.Dense
+	wSum, hSum []float64
+}
+
+func newKullbackLeibler(V, Wo, Ho *mat.Dense) *kullbackLeibler {
+	k := &kullbackLeibler{V: V, W: new(mat.Dense), H: new(mat.Dense)}
+	k.W.CloneFrom(Wo)
+	k.H.CloneFrom(Ho)
+	return k
+}
+
+// quotient sets k.q to the element-wise quotient V/WH for the
+// current factors.
+func (k *kullbackLeibler) quotient() {
+	k.wh.Reset()
+	k.wh.Mul(k.W, k.H)
+	k.q.Reset()
+	k.q.Apply(func(r, c int, v float64) float64 {
+		if v == 0 {
+			return 0
+		}
+		return v / (k.wh.At(r, c) + epsilon)
+	}, k.V)
+}
+
+// gradients returns the gradients of the divergence with respect
+// to W and H.
+func (k *kullbackLeibler) gradients() (gW, gH *mat.Dense) {
+	k.quotient()
+	k.sums()
+
+	// ∇W = (1 - V/WH)Hᵀ
+	gW = new(mat.Dense)
+	gW.Mul(&k.q, k.H.T())
+	applyInPlace(func(_, c int, v float64) float64 {
+		return k.hSum[c] - v
+	}, gW)
+
+	// ∇H = Wᵀ(1 - V/WH)
+	gH = new(mat.Dense)
+	gH.Mul(k.W.T(), &k.q)
+	applyInPlace(func(r, _ int, v float64) float64 {
+		return k.wSum[r] - v
+	}, gH)
+
+	return gW, gH
+}
+
+// sums sets k.wSum and k.hSum to the column sums of W
+// and the row sums of H.
+func (k *kullbackLeibler) sums() {
+	_, n := k.W.Dims()
+	if cap(k.wSum) < n {
+		k.wSum = make([]float64, n)
+		k.hSum = make([]float64, n)
+	}
+	k.wSum = k.wSum[:n]
+	k.hSum = k.hSum[:n]
+	for i := 0; i < n; i++ {
+		k.wSum[i] = colSum(k.W, i)
+		k.hSum[i] = rowSum(k.H, i)
+	}
+}
+
+func (k *kullbackLeibler) projNorm() float64 {
+	gW, gH := k.gradients()
+	return projNorm(gW, k.W, gH, k.H)
+}
+
+func (k *kullbackLeibler) projGradients() (gW, gH *mat.Dense) {
+	gW, gH = k.gradients()
+	projectGradient(gW, k.W, 0)
+	projectGradient(gH, k.H, 0)
+	return gW, gH
+}
+
+func (k *kullbackLeibler) update(_ context.Context) (ok bool, err error) {
+	// H *= (Wᵀ(V/WH)) / (Wᵀ1)
+	k.quotient()
+	k.sums()
+	k.num.Reset()
+	k.num.Mul(k.W.T(), &k.q)
+	applyInPlace(func(r, c int, v float64) float64 {
+		return v * k.num.At(r, c) / (k.wSum[r] + epsilon)
+	}, k.H)
+
+	// W *= ((V/WH)Hᵀ) / (1Hᵀ)
+	k.quotient()
+	k.sums()
+	k.num.Reset()
+	k.num.Mul(&k.q, k.H.T())
+	applyInPlace(func(r, c int, v float64) float64 {
+		return v * k.num.At(r, c) / (k.hSum[c] + epsilon)
+	}, k.W)
+
+	return true, nil
+}
+
+func (k *kullbackLeibler) objective() float64 { return divergence(k.V, k.W, k.H) }
+
+func (k *kullbackLeibler) factors() (W, H *mat.Dense) { return k.W, k.H }
+
+// divergence returns the generalised Kullback-Leibler divergence
+// D(V||WH) using the convention that 0*log(0) = 0. Entries of WH
+// are floored at epsilon.
+func divergence(V, W, H *mat.Dense) float64 {
+	var wh mat.Dense
+	wh.Mul(W, H)
+	r, c := V.Dims()
+	var d float64
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			v := V.At(i, j)
+			p := math.Max(wh.At(i, j), epsilon)
+			if v != 0 {
+				d += v * math.Log(v/p)
+			}
+			d += p - v
+		}
+	}
+	return d
+}
+
+// rowSum returns the sum of the elements in row i of m.
+func rowSum(m *mat.Dense, i int) float64 {
+	var s float64
+	for _, v := range m.RawRowView(i) {
+		s += v
+	}
+	return s
+}
+
+// colSum returns the sum of the elements in column j of m.
+func colSum(m *mat.Dense, j int) float64 {
+	r, _ := m.Dims()
+	var s float64
+	for i := 0; i < r; i++ {
+		s += m.At(i, j)
+	}
+	return s
+}
@@ -0,0 +1,140 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"context"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// l21 is the multiplicative update rule for the L2,1 norm objective
+// described in Kong, Ding and Huang (2011) 'Robust Nonnegative Matrix
+// Factorization using L21-Norm.' Proceedings of the 20th ACM
+// International Conference on Information and Knowledge Management 673.
+// Each update is the Frobenius norm update with the columns of V and
+// WH weighted by the reciprocals of their residual norms, so columns
+// that are poorly fitted, such as outliers, have less influence.
+type l21 struct {
+	V, W, H *mat.Dense
+
+	// d holds the column weights, the
+	// reciprocals of the residual norms.
+	d []float64
+
+	// vd and whd are V and WH with their
+	// columns scaled by d.
+	wh, vd, whd mat.Dense
+	num, den    mat.Dense
+}
+
+func newL21(V, Wo, Ho *mat.Dense) *l21 {
+	u := &l21{V: V, W: new(mat.Dense), H: new(mat.Dense)}
+	u.W.CloneFrom(Wo)
+	u.H.CloneFrom(Ho)
+	_, c := V.Dims()
+	u.d = make([]float64, c)
+	return u
+}
+
+// weights sets u.d to the reciprocals of the residual norms of the
+// columns of V for the current factors, and u.vd and u.whd to V and
+// WH with their columns scaled by u.d. Residual norms are floored
+// at epsilon.
+func (u *l21) weights() {
+	u.wh.Reset()
+	u.wh.Mul(u.W, u.H)
+	for j := range u.d {
+		u.d[j] = 0
+	}
+	r, c := u.V.Dims()
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			e := u.V.At(i, j) - u.wh.At(i, j)
+			u.d[j] += e * e
+		}
+	}
+	for j, v := range u.d {
+		u.d[j] = 1 / math.Max(math.Sqrt(v), epsilon)
+	}
+	scale := func(_, c int, v float64) float64 { return v * u.d[c] }
+	copyInto(&u.vd, u.V)
+	applyInPlace(scale, &u.vd)
+	copyInto(&u.whd, &u.wh)
+	applyInPlace(scale, &u.whd)
+}
+
+// gradients returns the gradients of the objective with respect
+// to W and H.
+func (u *l21) gradients() (gW, gH *mat.Dense) {
+	u.weights()
+	var e mat.Dense
+	e.Sub(&u.whd, &u.vd)
+
+	// ∇W = (WH - V)DHᵀ
+	gW = new(mat.Dense)
+	gW.Mul(&e, u.H.T())
+
+	// ∇H = Wᵀ(WH - V)D
+	gH = new(mat.Dense)
+	gH.Mul(u.W.T(), &e)
+
+	return gW, gH
+}
+
+func (u *l21) projNorm() float64 {
+	gW, gH := u.gradients()
+	return projNorm(gW, u.W, gH, u.H)
+}
+
+func (u *l21) projGradients() (gW, gH *mat.Dense) {
+	gW, gH = u.gradients()
+	projectGradient(gW, u.W, 0)
+	projectGradient(gH, u.H, 0)
+	return gW, gH
+}
+
+func (u *l21) update(_ context.Context) (ok bool, err error) {
+	// H *= (WᵀVD) / (WᵀWHD)
+	u.weights()
+	u.num.Reset()
+	u.num.Mul(u.W.T(), &u.vd)
+	u.den.Reset()
+	u.den.Mul(u.W.T(), &u.whd)
+	applyInPlace(ratio(&u.num, &u.den), u.H)
+
+	// W *= (VDHᵀ) / (WHDHᵀ)
+	u.weights()
+	u.num.Reset()
+	u.num.Mul(&u.vd, u.H.T())
+	u.den.Reset()
+	u.den.Mul(&u.whd, u.H.T())
+	applyInPlace(ratio(&u.num, &u.den), u.W)
+
+	return true, nil
+}
+
+func (u *l21) objective() float64 { return l21Norm(u.V, u.W, u.H) }
+
+func (u *l21) factors() (W, H *mat.Dense) { return u.W, u.H }
+
+// l21Norm returns the L2,1 norm of V-WH, the sum of the Euclidean
+// norms of its columns.
+func l21Norm(V, W, H *mat.Dense) float64 {
+	var wh mat.Dense
+	wh.Mul(W, H)
+	r, c := V.Dims()
+	var n float64
+	for j := 0; j < c; j++ {
+		var s float64
+		for i := 0; i < r; i++ {
+			e := V.At(i, j) - wh.At(i, j)
+			s += e * e
+		}
+		n += math.Sqrt(s)
+	}
+	return n
+}
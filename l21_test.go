@@ -0,0 +1,77 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestL21(t *testing.T) {
+	const (
+		rows, cols = 30, 40
+		k          = 3
+	)
+	src := rand.NewSource(1)
+	clean, Wo, Ho := lowRank(rows, cols, k, src)
+
+	// Corrupt a few columns with gross errors.
+	corrupt := make([]bool, cols)
+	V := mat.DenseCopyOf(clean)
+	rnd := rand.New(src)
+	for _, j := range []int{3, 17, 29} {
+		corrupt[j] = true
+		for i := 0; i < rows; i++ {
+			V.Set(i, j, V.At(i, j)+20*rnd.Float64())
+		}
+	}
+
+	// cleanError returns the relative residual of the
+	// uncorrupted columns of the clean data.
+	cleanError := func(W, H *mat.Dense) float64 {
+		var wh mat.Dense
+		wh.Mul(W, H)
+		var num, den float64
+		for j := 0; j < cols; j++ {
+			if corrupt[j] {
+				continue
+			}
+			for i := 0; i < rows; i++ {
+				v := clean.At(i, j)
+				e := v - wh.At(i, j)
+				num += e * e
+				den += v * v
+			}
+		}
+		return num / den
+	}
+
+	c := testConfig
+	c.Tolerance = 1e-10
+	c.MaxIter = 5000
+	W, H, _ := Factors(V, Wo, Ho, c)
+	frob := cleanError(W, H)
+
+	c.Objective = L21
+	W, H, res := FactorsResult(V, Wo, Ho, c)
+	if err := CheckNonNegative(W); err != nil {
+		t.Errorf("invalid W: %v", err)
+	}
+	if err := CheckNonNegative(H); err != nil {
+		t.Errorf("invalid H: %v", err)
+	}
+	if got, want := res.FinalObjective, l21Norm(V, W, H); got != want {
+		t.Errorf("unexpected final objective: got:%v want:%v", got, want)
+	}
+	if initial := l21Norm(V, Wo, Ho); res.FinalObjective >= initial {
+		t.Errorf("objective not reduced: got:%v initial:%v", res.FinalObjective, initial)
+	}
+	robust := cleanError(W, H)
+	if robust > frob/4 {
+		t.Errorf("clean structure not recovered: L21 error:%v Frobenius error:%v", robust, frob)
+	}
+}
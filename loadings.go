@@ -0,0 +1,62 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"fmt"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// TopFeatures returns the indices and values of the n largest entries of
+// column comp of W, the features contributing most to the component, in
+// descending order of value. Entries with equal values are returned in
+// ascending order of index. If n is greater than the number of rows of W,
+// all the entries of the column are returned. TopFeatures panics if comp
+// is not a valid column index of W or if n is negative.
+func TopFeatures(W *mat.Dense, comp, n int) (idx []int, val []float64) {
+	_, c := W.Dims()
+	if comp < 0 || comp >= c {
+		panic(fmt.Sprintf("nmf: component %d out of range", comp))
+	}
+	return top(mat.Col(nil, comp, W), n)
+}
+
+// TopSamples returns the indices and values of the n largest entries of
+// row comp of H, the samples in which the component is most expressed, in
+// descending order of value. Entries with equal values are returned in
+// ascending order of index. If n is greater than the number of columns of
+// H, all the entries of the row are returned. TopSamples panics if comp is
+// not a valid row index of H or if n is negative.
+func TopSamples(H *mat.Dense, comp, n int) (idx []int, val []float64) {
+	r, _ := H.Dims()
+	if comp < 0 || comp >= r {
+		panic(fmt.Sprintf("nmf: component %d out of range", comp))
+	}
+	return top(mat.Row(nil, comp, H), n)
+}
+
+// top returns the indices and values of the n largest elements
+// of x in descending order, breaking ties by ascending index.
+func top(x []float64, n int) (idx []int, val []float64) {
+	if n < 0 {
+		panic("nmf: negative number of entries")
+	}
+	if n > len(x) {
+		n = len(x)
+	}
+	order := make([]int, len(x))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return x[order[i]] > x[order[j]] })
+	idx = order[:n:n]
+	val = make([]float64, n)
+	for i, k := range idx {
+		val[i] = x[k]
+	}
+	return idx, val
+}
@@ -0,0 +1,67 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"reflect"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestTopFeaturesSamples(t *testing.T) {
+	W := mat.NewDense(5, 2, []float64{
+		0.1, 3,
+		0.7, 0,
+		0.2, 1,
+		0.7, 2,
+		0, 1,
+	})
+	H := W.T()
+	for _, test := range []struct {
+		comp, n int
+		idx     []int
+		val     []float64
+	}{
+		{comp: 0, n: 3, idx: []int{1, 3, 2}, val: []float64{0.7, 0.7, 0.2}},
+		{comp: 1, n: 2, idx: []int{0, 3}, val: []float64{3, 2}},
+		{comp: 1, n: 0, idx: []int{}, val: []float64{}},
+		{comp: 1, n: 10, idx: []int{0, 3, 2, 4, 1}, val: []float64{3, 2, 1, 1, 0}},
+	} {
+		idx, val := TopFeatures(W, test.comp, test.n)
+		if !reflect.DeepEqual(idx, test.idx) || !reflect.DeepEqual(val, test.val) {
+			t.Errorf("unexpected top features for component %d n=%d: got:%v %v want:%v %v",
+				test.comp, test.n, idx, val, test.idx, test.val)
+		}
+		idx, val = TopSamples(mat.DenseCopyOf(H), test.comp, test.n)
+		if !reflect.DeepEqual(idx, test.idx) || !reflect.DeepEqual(val, test.val) {
+			t.Errorf("unexpected top samples for component %d n=%d: got:%v %v want:%v %v",
+				test.comp, test.n, idx, val, test.idx, test.val)
+		}
+	}
+
+	for _, test := range []struct {
+		name    string
+		comp, n int
+	}{
+		{name: "negative component", comp: -1, n: 1},
+		{name: "component out of range", comp: 2, n: 1},
+		{name: "negative n", comp: 0, n: -1},
+	} {
+		for _, fn := range []func(){
+			func() { TopFeatures(W, test.comp, test.n) },
+			func() { TopSamples(mat.DenseCopyOf(H), test.comp, test.n) },
+		} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("expected panic for %s", test.name)
+					}
+				}()
+				fn()
+			}()
+		}
+	}
+}
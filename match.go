@@ -0,0 +1,247 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// MatchComponents returns the assignment of the columns of W1 to the columns
+// of W2 that maximises the sum of the cosine similarities of the matched
+// pairs, allowing the components of two factorisations of the same data to be
+// compared despite their arbitrary order and scale. Column i of W1 is matched
+// to column perm[i] of W2 with cosine similarity sims[i]. If W1 has more
+// columns than W2, the unmatched columns of W1 have a perm value of -1 and a
+// similarity of zero. Columns with zero norm have zero similarity to all
+// columns. The optimal assignment is found by the Hungarian algorithm.
+//
+// Kuhn (1955) 'The Hungarian method for the assignment problem.'
+// Nav. Res. Logist. Q. 2:83.
+//
+// MatchComponents panics if W1 and W2 do not have the same number of rows.
+func MatchComponents(W1, W2 *mat.Dense) (perm []int, sims []float64) {
+	r1, k1 := W1.Dims()
+	r2, k2 := W2.Dims()
+	if r1 != r2 {
+		panic("nmf: dimension mismatch between W1 and W2")
+	}
+
+	sim := cosineSimilarities(W1, W2)
+	if k1 <= k2 {
+		perm = assign(k1, k2, func(i, j int) float64 { return -sim.At(i, j) })
+	} else {
+		perm = make([]int, k1)
+		for i := range perm {
+			perm[i] = -1
+		}
+		for j, i := range assign(k2, k1, func(j, i int) float64 { return -sim.At(i, j) }) {
+			perm[i] = j
+		}
+	}
+	sims = make([]float64, k1)
+	for i, j := range perm {
+		if j >= 0 {
+			sims[i] = sim.At(i, j)
+		}
+	}
+	return perm, sims
+}
+
+// cosineSimilarities returns the matrix of cosine similarities between
+// the columns of A and the columns of B.
+func cosineSimilarities(A, B *mat.Dense) *mat.Dense {
+	var sim mat.Dense
+	sim.Mul(A.T(), B)
+	_, ka := A.Dims()
+	_, kb := B.Dims()
+	na := make([]float64, ka)
+	for i := range na {
+		na[i] = mat.Norm(A.ColView(i), 2)
+	}
+	nb := make([]float64, kb)
+	for j := range nb {
+		nb[j] = mat.Norm(B.ColView(j), 2)
+	}
+	applyInPlace(func(i, j int, v float64) float64 {
+		if na[i] == 0 || nb[j] == 0 {
+			return 0
+		}
+		return v / (na[i] * nb[j])
+	}, &sim)
+	return &sim
+}
+
+// assign returns the assignment of n rows to distinct columns of the
+// n×m cost matrix given by cost, with n <= m, that minimises the total
+// cost. Row i is assigned to column p[i].
+func assign(n, m int, cost func(i, j int) float64) []int {
+	// This is the shortest augmenting path formulation of the
+	// Hungarian algorithm with row and column potentials u and
+	// v. Row and column indices are offset by one, with index
+	// zero used as a sentinel.
+	var (
+		u   = make([]float64, n+1)
+		v   = make([]float64, m+1)
+		p   = make([]int, m+1)
+		way = make([]int, m+1)
+
+		minv = make([]float64, m+1)
+		used = make([]bool, m+1)
+	)
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		for j := range minv {
+			minv[j] = math.Inf(1)
+			used[j] = false
+		}
+		for {
+			used[j0] = true
+			i0 := p[j0]
+			delta := math.Inf(1)
+			j1 := 0
+			for j := 1; j <= m; j++ {
+				if used[j] {
+					continue
+				}
+				cur := cost(i0-1, j-1) - u[i0] - v[j]
+				if cur < minv[j] {
+					minv[j] = cur
+					way[j] = j0
+				}
+				if minv[j] < delta {
+					delta = minv[j]
+					j1 = j
+				}
+			}
+			for j := 0; j <= m; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+			if p[j0] == 0 {
+				break
+			}
+		}
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+
+	rows := make([]int, n)
+	for j := 1; j <= m; j++ {
+		if p[j] != 0 {
+			rows[p[j]-1] = j - 1
+		}
+	}
+	return rows
+}
+
+// MergeSimilarComponents returns copies of W and H in which each pair of columns
+// of W with a cosine similarity greater than cosThreshold has been merged into a
+// single component, reducing the rank of the factorisation, and the number of
+// merges performed. Pairs are merged greedily in decreasing order of similarity,
+// and the similarities of a merged component are recomputed before further
+// merges, so more than two components may be merged into one. The merged column
+// of W has the direction of the sum of the pair's unit columns weighted by the
+// norms of their contributions to WH, and the merged row of H is the least
+// squares fit of the pair's contribution to WH given that column, so the
+// reconstruction changes only slightly when the columns are nearly parallel.
+// The remaining components are copied unaltered and retain their order.
+// Columns with zero norm are never merged.
+//
+// MergeSimilarComponents panics if the number of columns of W does not equal
+// the number of rows of H.
+func MergeSimilarComponents(W, H *mat.Dense, cosThreshold float64) (Wnew, Hnew *mat.Dense, merged int) {
+	r, k := W.Dims()
+	hr, c := H.Dims()
+	if k != hr {
+		panic("nmf: dimension mismatch between W and H")
+	}
+	Wnew = mat.DenseCopyOf(W)
+	Hnew = mat.DenseCopyOf(H)
+	if k == 0 {
+		return Wnew, Hnew, 0
+	}
+	sims := cosineSimilarities(Wnew, Wnew)
+	norms := make([]float64, k)
+	for j := range norms {
+		norms[j] = mat.Norm(Wnew.ColView(j), 2)
+	}
+	// Columns with zero norm are inactive but are not
+	// removed; only merged columns are removed.
+	active := make([]bool, k)
+	for i := range active {
+		active[i] = norms[i] != 0
+	}
+	isMerged := make([]bool, k)
+	u := mat.NewVecDense(r, nil)
+	h := mat.NewVecDense(c, nil)
+	for {
+		bi, bj := -1, -1
+		best := cosThreshold
+		for i := 0; i < k; i++ {
+			for j := i + 1; j < k; j++ {
+				if active[i] && active[j] && sims.At(i, j) > best {
+					bi, bj, best = i, j, sims.At(i, j)
+				}
+			}
+		}
+		if bi < 0 {
+			break
+		}
+
+		// Merge component bj into component bi.
+		wi, wj := Wnew.ColView(bi), Wnew.ColView(bj)
+		hi, hj := Hnew.RowView(bi), Hnew.RowView(bj)
+		ni := norms[bi]
+		a, b := mat.Norm(hi, 2), mat.Norm(hj, 2)
+		if a == 0 && b == 0 {
+			a, b = 1, 1
+		}
+		u.ScaleVec(a, wi)
+		u.AddScaledVec(u, b, wj)
+		u.ScaleVec(1/mat.Norm(u, 2), u)
+		// With u of unit norm, the least squares fit of
+		// w_i h_i + w_j h_j is u (uᵀw_i h_i + uᵀw_j h_j).
+		h.ScaleVec(mat.Dot(u, wi), hi)
+		h.AddScaledVec(h, mat.Dot(u, wj), hj)
+		for l := 0; l < r; l++ {
+			Wnew.Set(l, bi, ni*u.AtVec(l))
+		}
+		for l := 0; l < c; l++ {
+			Hnew.Set(bi, l, h.AtVec(l)/ni)
+		}
+		active[bj] = false
+		isMerged[bj] = true
+		merged++
+
+		for j := 0; j < k; j++ {
+			var s float64
+			if active[j] && j != bi {
+				s = mat.Dot(Wnew.ColView(bi), Wnew.ColView(j)) / (ni * norms[j])
+			}
+			sims.Set(bi, j, s)
+			sims.Set(j, bi, s)
+		}
+	}
+
+	var removed []int
+	for j, ok := range isMerged {
+		if ok {
+			removed = append(removed, j)
+		}
+	}
+	Wnew, Hnew = pruneComponents(Wnew, Hnew, removed)
+	return Wnew, Hnew, merged
+}
@@ -0,0 +1,161 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestMatchComponents(t *testing.T) {
+	W1, _ := InitRandom(10, 1, 4, Uniform, rand.NewSource(1))
+	order := []int{2, 0, 3, 1}
+	W2 := mat.NewDense(10, 4, nil)
+	for i, j := range order {
+		W2.Slice(0, 10, j, j+1).(*mat.Dense).Scale(float64(i+2), W1.Slice(0, 10, i, i+1))
+	}
+
+	perm, sims := MatchComponents(W1, W2)
+	for i, j := range perm {
+		if j != order[i] {
+			t.Errorf("unexpected match for column %d: got:%d want:%d", i, j, order[i])
+		}
+		if math.Abs(sims[i]-1) > 1e-12 {
+			t.Errorf("unexpected similarity for column %d: %v", i, sims[i])
+		}
+	}
+
+	// Fewer columns in W2 leaves columns of W1 unmatched.
+	perm, sims = MatchComponents(W1, W2.Slice(0, 10, 0, 2).(*mat.Dense))
+	var matched int
+	for i, j := range perm {
+		switch {
+		case j < 0:
+			if sims[i] != 0 {
+				t.Errorf("unexpected similarity for unmatched column %d: %v", i, sims[i])
+			}
+		case j != order[i]:
+			t.Errorf("unexpected match for column %d: got:%d want:%d", i, j, order[i])
+		default:
+			matched++
+		}
+	}
+	if matched != 2 {
+		t.Errorf("unexpected number of matched columns: %d", matched)
+	}
+}
+
+func TestAssign(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for test := 0; test < 50; test++ {
+		n := 1 + rnd.Intn(5)
+		m := n + rnd.Intn(3)
+		cost := mat.NewDense(n, m, nil)
+		for i := 0; i < n; i++ {
+			for j := 0; j < m; j++ {
+				cost.Set(i, j, rnd.Float64())
+			}
+		}
+		p := assign(n, m, cost.At)
+		used := make(map[int]bool)
+		var got float64
+		for i, j := range p {
+			if used[j] {
+				t.Fatalf("column %d assigned twice", j)
+			}
+			used[j] = true
+			got += cost.At(i, j)
+		}
+		want := bruteAssign(cost, 0, make([]bool, m))
+		if math.Abs(got-want) > 1e-12 {
+			t.Errorf("non-optimal assignment for test %d: got:%v want:%v", test, got, want)
+		}
+	}
+}
+
+// bruteAssign returns the minimum cost of assigning rows i and
+// onwards to unused columns of cost.
+func bruteAssign(cost *mat.Dense, i int, used []bool) float64 {
+	n, m := cost.Dims()
+	if i == n {
+		return 0
+	}
+	best := math.Inf(1)
+	for j := 0; j < m; j++ {
+		if used[j] {
+			continue
+		}
+		used[j] = true
+		best = math.Min(best, cost.At(i, j)+bruteAssign(cost, i+1, used))
+		used[j] = false
+	}
+	return best
+}
+
+func TestMergeSimilarComponents(t *testing.T) {
+	const r, c = 20, 15
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		noise     float64
+		threshold float64
+		merged    int
+		tol       float64
+		zero      bool
+	}{
+		// Exactly parallel columns merge without
+		// changing the reconstruction.
+		{noise: 0, threshold: 0.999, merged: 1, tol: 1e-12},
+		{noise: 0.01, threshold: 0.99, merged: 1, tol: 1e-2},
+		{noise: 0.01, threshold: 1, merged: 0, tol: 0},
+		// A zero column is neither merged nor removed.
+		{noise: 0, threshold: 0.999, merged: 1, tol: 1e-12, zero: true},
+	} {
+		W, H := InitRandom(r, c, 4, Uniform, rand.NewSource(1))
+		for i := 0; i < r; i++ {
+			W.Set(i, 3, 2*W.At(i, 1)+test.noise*rnd.Float64())
+			if test.zero {
+				W.Set(i, 2, 0)
+			}
+		}
+		var want mat.Dense
+		want.Mul(W, H)
+
+		Wm, Hm, merged := MergeSimilarComponents(W, H, test.threshold)
+		if merged != test.merged {
+			t.Errorf("unexpected number of merges for threshold %v: got:%d want:%d", test.threshold, merged, test.merged)
+		}
+		if _, k := Wm.Dims(); k != 4-test.merged {
+			t.Errorf("unexpected rank after merging: got:%d want:%d", k, 4-test.merged)
+		}
+		if err := CheckNonNegative(Wm); err != nil {
+			t.Errorf("invalid W: %v", err)
+		}
+		if err := CheckNonNegative(Hm); err != nil {
+			t.Errorf("invalid H: %v", err)
+		}
+		var got mat.Dense
+		got.Mul(Wm, Hm)
+		got.Sub(&got, &want)
+		if d := mat.Norm(&got, 2) / mat.Norm(&want, 2); d > test.tol {
+			t.Errorf("unexpected change in reconstruction for noise %v: %v", test.noise, d)
+		}
+		// Unmerged components are unaltered.
+		for _, j := range []int{0, 2} {
+			if !mat.Equal(Wm.ColView(j), W.ColView(j)) || !mat.Equal(Hm.RowView(j), H.RowView(j)) {
+				t.Errorf("component %d altered by merging", j)
+			}
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for mismatched dimensions")
+		}
+	}()
+	MergeSimilarComponents(mat.NewDense(3, 2, nil), mat.NewDense(3, 3, nil), 0.9)
+}
@@ -0,0 +1,219 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Model is a learned non-negative factorisation and the configuration
+// used to produce it. Model may be serialised with encoding/gob via its
+// binary marshalling methods, or with encoding/json.
+type Model struct {
+	W, H   *mat.Dense
+	Config Config
+}
+
+// modelBinary is the gob wire form of a Model. The factors are held
+// in the gonum binary matrix format.
+type modelBinary struct {
+	W, H   []byte
+	Config Config
+}
+
+// MarshalBinary encodes the receiver into a binary form and returns
+// the result. The Callback field of the Config is not encoded.
+func (m *Model) MarshalBinary() ([]byte, error) {
+	var (
+		wire modelBinary
+		err  error
+	)
+	wire.W, err = marshalDense(m.W)
+	if err != nil {
+		return nil, err
+	}
+	wire.H, err = marshalDense(m.H)
+	if err != nil {
+		return nil, err
+	}
+	wire.Config = m.Config
+	// These fields are not retained by
+	// serialisation.
+	wire.Config.Rand = nil
+	wire.Config.GraphLaplacian = nil
+	var buf bytes.Buffer
+	err = gob.NewEncoder(&buf).Encode(wire)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes the binary form into the receiver.
+func (m *Model) UnmarshalBinary(data []byte) error {
+	var wire modelBinary
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&wire)
+	if err != nil {
+		return err
+	}
+	W, err := unmarshalDense(wire.W)
+	if err != nil {
+		return err
+	}
+	H, err := unmarshalDense(wire.H)
+	if err != nil {
+		return err
+	}
+	*m = Model{W: W, H: H, Config: wire.Config}
+	return nil
+}
+
+// marshalDense returns the binary form of m. Nil and empty
+// matrices are encoded as nil.
+func marshalDense(m *mat.Dense) ([]byte, error) {
+	if m == nil || m.IsEmpty() {
+		return nil, nil
+	}
+	return m.MarshalBinary()
+}
+
+// unmarshalDense returns the matrix encoded in data by marshalDense.
+func unmarshalDense(data []byte) (*mat.Dense, error) {
+	m := new(mat.Dense)
+	if len(data) == 0 {
+		return m, nil
+	}
+	err := m.UnmarshalBinary(data)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// modelJSON is the JSON form of a Model.
+type modelJSON struct {
+	W, H   denseJSON
+	Config Config
+}
+
+// denseJSON is the JSON form of a matrix, holding its dimensions
+// and row-major data.
+type denseJSON struct {
+	Rows int       `json:"rows"`
+	Cols int       `json:"cols"`
+	Data []float64 `json:"data"`
+}
+
+// MarshalJSON implements the json.Marshaler interface. The factors are
+// encoded as their dimensions and row-major data. The Callback field of
+// the Config is not encoded.
+func (m *Model) MarshalJSON() ([]byte, error) {
+	return json.Marshal(modelJSON{W: denseToJSON(m.W), H: denseToJSON(m.H), Config: m.Config})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (m *Model) UnmarshalJSON(data []byte) error {
+	var wire modelJSON
+	err := json.Unmarshal(data, &wire)
+	if err != nil {
+		return err
+	}
+	W, err := wire.W.dense("W")
+	if err != nil {
+		return err
+	}
+	H, err := wire.H.dense("H")
+	if err != nil {
+		return err
+	}
+	*m = Model{W: W, H: H, Config: wire.Config}
+	return nil
+}
+
+// denseToJSON returns the JSON form of m.
+func denseToJSON(m *mat.Dense) denseJSON {
+	if m == nil || m.IsEmpty() {
+		return denseJSON{}
+	}
+	r, c := m.Dims()
+	d := denseJSON{Rows: r, Cols: c, Data: make([]float64, 0, r*c)}
+	raw := m.RawMatrix()
+	for i := 0; i < r; i++ {
+		d.Data = append(d.Data, raw.Data[i*raw.Stride:i*raw.Stride+c]...)
+	}
+	return d
+}
+
+// dense returns the matrix held by d. The name is used in
+// error messages.
+func (d denseJSON) dense(name string) (*mat.Dense, error) {
+	switch {
+	case d.Rows < 0 || d.Cols < 0:
+		return nil, fmt.Errorf("nmf: %s has negative dimensions (%d×%d)", name, d.Rows, d.Cols)
+	case len(d.Data) != d.Rows*d.Cols:
+		return nil, fmt.Errorf("nmf: %s data length (%d) does not match dimensions (%d×%d)", name, len(d.Data), d.Rows, d.Cols)
+	case d.Rows == 0 || d.Cols == 0:
+		if len(d.Data) != 0 || d.Rows != d.Cols {
+			return nil, fmt.Errorf("nmf: %s has invalid dimensions (%d×%d)", name, d.Rows, d.Cols)
+		}
+		return new(mat.Dense), nil
+	}
+	return mat.NewDense(d.Rows, d.Cols, d.Data), nil
+}
+
+// stateSize is the length of the binary form of a State.
+const stateSize = 3 * 8
+
+// MarshalBinary encodes the receiver into a binary form and returns
+// the result.
+func (s State) MarshalBinary() ([]byte, error) {
+	data := make([]byte, stateSize)
+	for i, v := range []float64{s.grad, s.tolW, s.tolH} {
+		binary.LittleEndian.PutUint64(data[8*i:], math.Float64bits(v))
+	}
+	return data, nil
+}
+
+// UnmarshalBinary decodes the binary form into the receiver.
+func (s *State) UnmarshalBinary(data []byte) error {
+	if len(data) != stateSize {
+		return errors.New("nmf: invalid state length")
+	}
+	for i, v := range []*float64{&s.grad, &s.tolW, &s.tolH} {
+		*v = math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:]))
+	}
+	return nil
+}
+
+// stateJSON is the JSON form of a State.
+type stateJSON struct {
+	Grad float64 `json:"grad"`
+	TolW float64 `json:"tolW"`
+	TolH float64 `json:"tolH"`
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (s State) MarshalJSON() ([]byte, error) {
+	return json.Marshal(stateJSON{Grad: s.grad, TolW: s.tolW, TolH: s.tolH})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (s *State) UnmarshalJSON(data []byte) error {
+	var wire stateJSON
+	err := json.Unmarshal(data, &wire)
+	if err != nil {
+		return err
+	}
+	*s = State{grad: wire.Grad, tolW: wire.TolW, tolH: wire.TolH}
+	return nil
+}
@@ -0,0 +1,129 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func testModel() *Model {
+	V, Wo, Ho := testFactors()
+	c := testConfig
+	c.L2H = 0.1
+	c.Concurrency = 2
+	W, H, res := FactorsResult(V, Wo, Ho, c)
+	c.WarmStart = true
+	c.State = res.State
+	c.Callback = func(int, float64, time.Duration) bool { return true }
+	return &Model{W: W, H: H, Config: c}
+}
+
+func TestModelBinary(t *testing.T) {
+	m := testModel()
+
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(m)
+	if err != nil {
+		t.Fatalf("unexpected error encoding model: %v", err)
+	}
+	var got Model
+	err = gob.NewDecoder(&buf).Decode(&got)
+	if err != nil {
+		t.Fatalf("unexpected error decoding model: %v", err)
+	}
+	checkModel(t, &got, m)
+}
+
+func TestModelJSON(t *testing.T) {
+	m := testModel()
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("unexpected error encoding model: %v", err)
+	}
+	var got Model
+	err = json.Unmarshal(data, &got)
+	if err != nil {
+		t.Fatalf("unexpected error decoding model: %v", err)
+	}
+	checkModel(t, &got, m)
+
+	for _, bad := range []string{
+		`{"W":{"rows":2,"cols":2,"data":[1,2,3]}}`,
+		`{"W":{"rows":-1,"cols":1,"data":[]}}`,
+		`{"W":{"rows":0,"cols":2,"data":[]}}`,
+	} {
+		var m Model
+		if json.Unmarshal([]byte(bad), &m) == nil {
+			t.Errorf("expected error decoding %s", bad)
+		}
+	}
+}
+
+func TestModelEmpty(t *testing.T) {
+	var m Model
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error encoding empty model: %v", err)
+	}
+	var got Model
+	err = got.UnmarshalBinary(data)
+	if err != nil {
+		t.Fatalf("unexpected error decoding empty model: %v", err)
+	}
+	if !got.W.IsEmpty() || !got.H.IsEmpty() {
+		t.Error("expected empty factors")
+	}
+}
+
+func checkModel(t *testing.T, got, want *Model) {
+	t.Helper()
+	for _, f := range []struct {
+		name      string
+		got, want *mat.Dense
+	}{
+		{name: "W", got: got.W, want: want.W},
+		{name: "H", got: got.H, want: want.H},
+	} {
+		if !identical(f.got, f.want) {
+			t.Errorf("%s not identical after round trip:\ngot:\n%v\nwant:\n%v",
+				f.name, mat.Formatted(f.got), mat.Formatted(f.want))
+		}
+	}
+	if got.Config.Callback != nil {
+		t.Error("unexpected callback after round trip")
+	}
+	c := want.Config
+	c.Callback = nil
+	if !reflect.DeepEqual(got.Config, c) {
+		t.Errorf("unexpected config after round trip:\ngot: %+v\nwant:%+v", got.Config, c)
+	}
+}
+
+// identical returns whether a and b have the same dimensions and
+// bit-identical elements.
+func identical(a, b *mat.Dense) bool {
+	ar, ac := a.Dims()
+	br, bc := b.Dims()
+	if ar != br || ac != bc {
+		return false
+	}
+	for i := 0; i < ar; i++ {
+		for j := 0; j < ac; j++ {
+			if math.Float64bits(a.At(i, j)) != math.Float64bits(b.At(i, j)) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,157 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"context"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// epsilon is added to the denominators of multiplicative
+// updates to guard against division by zero.
+const epsilon = 1e-12
+
+// multiplicativeUpdate is the Lee and Seung multiplicative
+// update rule for the Frobenius norm objective.
+type multiplicativeUpdate struct {
+	V, W, H *mat.Dense
+	pW, pH  penalty
+
+	// orthW and orthH specify that the orthogonal
+	// update rules are used for W and H.
+	orthW, orthH bool
+
+	// maxW and maxH are the upper bounds
+	// of the entries of W and H.
+	maxW, maxH float64
+
+	// graph is the graph regularisation
+	// penalty on H. It may be nil.
+	graph *graph
+
+	// damping is the constant added to the
+	// numerators and denominators of the
+	// update ratios and used as the floor
+	// of the updated entries.
+	damping float64
+
+	num, den, tmp mat.Dense
+}
+
+func newMultiplicativeUpdate(V, Wo, Ho *mat.Dense, pW, pH penalty) *multiplicativeUpdate {
+	m := &multiplicativeUpdate{V: V, W: new(mat.Dense), H: new(mat.Dense), pW: pW, pH: pH}
+	m.W.CloneFrom(Wo)
+	m.H.CloneFrom(Ho)
+	return m
+}
+
+func (m *multiplicativeUpdate) projNorm() float64 {
+	gW, gH := gradients(m.V, m.W, m.H, m.pW, m.pH)
+	m.graph.addGradient(gH, m.H)
+	return boxProjNorm(gW, m.W, m.maxW, gH, m.H, m.maxH)
+}
+
+func (m *multiplicativeUpdate) projGradients() (gW, gH *mat.Dense) {
+	gW, gH = gradients(m.V, m.W, m.H, m.pW, m.pH)
+	m.graph.addGradient(gH, m.H)
+	projectGradient(gW, m.W, m.maxW)
+	projectGradient(gH, m.H, m.maxH)
+	return gW, gH
+}
+
+func (m *multiplicativeUpdate) update(_ context.Context) (ok bool, err error) {
+	// H *= (WᵀV) / (WᵀWH + ∇penalty)
+	m.num.Reset()
+	m.num.Mul(m.W.T(), m.V)
+	m.tmp.Reset()
+	m.den.Reset()
+	if m.orthH {
+		// H *= sqrt((WᵀV) / (WᵀVHᵀH + ∇penalty))
+		m.tmp.Mul(&m.num, m.H.T())
+		m.den.Mul(&m.tmp, m.H)
+	} else {
+		m.tmp.Mul(m.W.T(), m.W)
+		m.den.Mul(&m.tmp, m.H)
+	}
+	m.pH.addGradient(&m.den, m.H)
+	if m.graph != nil {
+		// H *= (WᵀV + λHL⁻) / (WᵀWH + λHL⁺ + ∇penalty)
+		m.graph.addTerm(&m.num, m.H, m.graph.neg)
+		m.graph.addTerm(&m.den, m.H, m.graph.pos)
+	}
+	m.damp(m.H)
+	if m.orthH {
+		applyInPlace(sqrtRatio(&m.num, &m.den), m.H)
+	} else {
+		applyInPlace(ratio(&m.num, &m.den), m.H)
+	}
+	if !unbounded(m.maxH) {
+		applyInPlace(boxFilt(m.maxH), m.H)
+	}
+
+	// W *= (VHᵀ) / (WHHᵀ + ∇penalty)
+	m.num.Reset()
+	m.num.Mul(m.V, m.H.T())
+	m.tmp.Reset()
+	m.den.Reset()
+	if m.orthW {
+		// W *= sqrt((VHᵀ) / (WWᵀVHᵀ + ∇penalty))
+		m.tmp.Mul(m.W.T(), &m.num)
+		m.den.Mul(m.W, &m.tmp)
+	} else {
+		m.tmp.Mul(m.H, m.H.T())
+		m.den.Mul(m.W, &m.tmp)
+	}
+	m.pW.addGradient(&m.den, m.W)
+	m.damp(m.W)
+	if m.orthW {
+		applyInPlace(sqrtRatio(&m.num, &m.den), m.W)
+	} else {
+		applyInPlace(ratio(&m.num, &m.den), m.W)
+	}
+	if !unbounded(m.maxW) {
+		applyInPlace(boxFilt(m.maxW), m.W)
+	}
+
+	return true, nil
+}
+
+// damp adds the damping constant to the numerator and denominator
+// of the update ratio and floors the entries of the factor X at the
+// constant so that zero entries can grow.
+func (m *multiplicativeUpdate) damp(X *mat.Dense) {
+	if m.damping == 0 {
+		return
+	}
+	d := m.damping
+	add := func(_, _ int, v float64) float64 { return v + d }
+	applyInPlace(add, &m.num)
+	applyInPlace(add, &m.den)
+	applyInPlace(func(_, _ int, v float64) float64 { return math.Max(v, d) }, X)
+}
+
+func (m *multiplicativeUpdate) objective() float64 {
+	return frobenius(m.V, m.W, m.H) + m.pW.value(m.W) + m.pH.value(m.H) + m.graph.value(m.H)
+}
+
+func (m *multiplicativeUpdate) factors() (W, H *mat.Dense) { return m.W, m.H }
+
+// ratio returns a function that scales its input by the ratio of
+// the corresponding elements of num and den.
+func ratio(num, den *mat.Dense) func(r, c int, v float64) float64 {
+	return func(r, c int, v float64) float64 {
+		return v * num.At(r, c) / (den.At(r, c) + epsilon)
+	}
+}
+
+// sqrtRatio returns a function that scales its input by the square
+// root of the ratio of the corresponding elements of num and den.
+func sqrtRatio(num, den *mat.Dense) func(r, c int, v float64) float64 {
+	return func(r, c int, v float64) float64 {
+		return v * math.Sqrt(num.At(r, c)/(den.At(r, c)+epsilon))
+	}
+}
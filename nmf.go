@@ -9,133 +9,1885 @@
 //
 // Chih-Jen Lin (2007) 'Projected grad Methods for Non-negative Matrix Factorization.'
 // Neural Computation 19:2756.
+//
+// The multiplicative update rules of Lee and Seung for the Frobenius norm and
+// generalised Kullback-Leibler divergence objectives are also provided.
+//
+// Factorisations are deterministic. No global random source is used; functions
+// that make random choices, such as InitRandom and FactorsBest, draw only from
+// the rand.Source they are given, and concurrent computations are arranged so
+// that the factors do not depend on goroutine scheduling. Given the same inputs
+// and the same source, repeated calls return bit-for-bit identical factors.
+//
+// Matrices are represented using gonum.org/v1/gonum/mat. The returned factors
+// are *mat.Dense values and so satisfy mat.Matrix, and may be passed directly
+// to code using the gonum mat package without conversion.
 package nmf
 
 import (
+	"context"
+	"fmt"
 	"math"
+	"math/rand"
 	"time"
 
-	"github.com/gonum/matrix/mat64"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/floats/scalar"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Method specifies the update rule used by a factorisation.
+type Method int
+
+const (
+	// ProjectedGradient specifies alternating non-negative least
+	// squares using projected gradients. This is the default method.
+	ProjectedGradient Method = iota
+
+	// MultiplicativeUpdate specifies the multiplicative update rule
+	// described in Lee and Seung (2001) 'Algorithms for Non-negative
+	// Matrix Factorization.' Advances in Neural Information Processing
+	// Systems 13:556.
+	//
+	// The MaxOuterSub and MaxInnerSub fields of Config are ignored
+	// when this method is used and the ok value returned by Factors
+	// is true if any update was made.
+	MultiplicativeUpdate
+
+	// HALS specifies the hierarchical alternating least squares
+	// method described in Cichocki and Phan (2009) 'Fast Local
+	// Algorithms for Large Scale Nonnegative Matrix and Tensor
+	// Factorizations.' IEICE Transactions on Fundamentals of
+	// Electronics, Communications and Computer Sciences E92-A:708.
+	// Each iteration updates the rows of H and then the columns
+	// of W in turn using closed form non-negative projections.
+	//
+	// The MaxOuterSub and MaxInnerSub fields of Config are ignored
+	// when this method is used and the ok value returned by Factors
+	// is true if any update was made.
+	HALS
+)
+
+// Objective specifies the objective function minimised by a factorisation.
+type Objective int
+
+const (
+	// Frobenius specifies minimisation of the squared Frobenius
+	// norm of V-WH. This is the default objective.
+	Frobenius Objective = iota
+
+	// KullbackLeibler specifies minimisation of the generalised
+	// Kullback-Leibler divergence D(V||WH). It is the appropriate
+	// objective for count data.
+	KullbackLeibler
+
+	// BetaDivergence specifies minimisation of the β-divergence
+	// D_β(V||WH) with β given by the Beta field of Config. The
+	// β-divergence is half the squared Frobenius norm when β is
+	// 2, the generalised Kullback-Leibler divergence when β is 1
+	// and the Itakura-Saito divergence when β is 0.
+	BetaDivergence
+
+	// ItakuraSaito specifies minimisation of the Itakura-Saito
+	// divergence D(V||WH). The divergence is scale invariant,
+	// making it appropriate for audio power spectrograms. It is
+	// equivalent to BetaDivergence with β equal to 0, and V
+	// should have strictly positive entries.
+	ItakuraSaito
+
+	// L21 specifies minimisation of the L2,1 norm of V-WH, the
+	// sum of the Euclidean norms of its columns. Since the
+	// residuals of each column are not squared across columns,
+	// a few grossly corrupted columns of V have much less
+	// influence on the factors than under the Frobenius
+	// objective, making it appropriate for data with outlying
+	// samples.
+	L21
+)
+
+// StopCriterion specifies the stopping criterion of a factorisation.
+type StopCriterion int
+
+const (
+	// ProjectedGradientNorm specifies stopping when the norm of
+	// the projected gradient falls below the tolerance scaled by
+	// the initial gradient norm. This is the default criterion.
+	ProjectedGradientNorm StopCriterion = iota
+
+	// RelativeObjective specifies stopping when the relative
+	// decrease in the objective, (prev-cur)/prev, falls below
+	// the tolerance.
+	RelativeObjective
+)
+
+// Preprocess specifies the preprocessing applied to V before
+// factorisation.
+type Preprocess int
+
+const (
+	// NoPreprocess specifies that V is factorised unaltered.
+	NoPreprocess Preprocess = iota
+
+	// L2ColumnNormalize specifies that the columns of V are
+	// scaled to unit Euclidean norm before factorisation.
+	L2ColumnNormalize
+
+	// L1ColumnNormalize specifies that the columns of V are
+	// scaled to unit sum before factorisation.
+	L1ColumnNormalize
+)
+
+// ConvergenceStatus specifies the reason a factorisation terminated.
+type ConvergenceStatus int
+
+const (
+	// Failed indicates that the factorisation terminated
+	// because of an error, including cancellation of its
+	// context and divergence leaving non-finite entries in
+	// the factors.
+	Failed ConvergenceStatus = iota
+
+	// Converged indicates that the stopping criterion
+	// was met.
+	Converged
+
+	// MaxIterReached indicates that MaxIter iterations
+	// were performed without meeting the stopping criterion.
+	MaxIterReached
+
+	// TimeLimitReached indicates that the time Limit was
+	// reached without meeting the stopping criterion.
+	TimeLimitReached
+
+	// Stalled indicates that an iteration made no change
+	// to the projected gradient norm, so that further
+	// iterations could not progress, or that the objective
+	// stagnated as described for Config.StagnationWindow.
+	Stalled
+
+	// Stopped indicates that the factorisation was stopped
+	// by the Callback.
+	Stopped
 )
 
 // Config determines the behaviour of a Factors call.
 type Config struct {
+	// Method is the update rule used for the factorisation.
+	Method Method
+
+	// Objective is the objective function minimised by the
+	// factorisation. When Objective is not Frobenius, Method
+	// is ignored and multiplicative updates are used.
+	Objective Objective
+
+	// Beta is the β parameter of the BetaDivergence objective.
+	// Values of Beta below 1 are appropriate for data with a
+	// large dynamic range, such as audio power spectrograms,
+	// since the divergence becomes less sensitive to the scale
+	// of the data. Beta is ignored for other objectives.
+	Beta float64
+
+	// Preprocess is the preprocessing applied to the columns
+	// of V. When V is preprocessed, the columns of V and Ho are
+	// scaled by the reciprocal of the column norms of V, the
+	// scaled problem is factorised and the columns of H are
+	// rescaled by the column norms, so that WH approximates
+	// the original V. Columns with zero norm are not scaled.
+	// The values reported in the Result are those of the
+	// scaled problem.
+	Preprocess Preprocess
+
 	// Tolerance is the stopping tolerance for the factorisation.
+	// For the Frobenius objective with the ProjectedGradientNorm
+	// stopping criterion, the factorisation stops when the
+	// projected gradient norm falls below Tolerance times the
+	// initial gradient norm. Otherwise, the factorisation stops
+	// when the relative decrease in the objective over an
+	// iteration falls below Tolerance.
 	Tolerance float64
 
+	// RelativeTolerance, if non-zero, is the stopping tolerance
+	// for the Frobenius objective as a fraction of the Frobenius
+	// norm of V. The factorisation stops when the objective falls
+	// below that of a residual with norm RelativeTolerance*||V||,
+	// so when ||V-WH|| < RelativeTolerance*||V|| in the absence
+	// of regularisation penalties, independent of the scale of
+	// V. RelativeTolerance takes precedence over Tolerance and
+	// StopCriterion. RelativeTolerance is used only by Factors
+	// and its variants; NaN entries of V are ignored when MaskNaN
+	// is set.
+	RelativeTolerance float64
+
+	// StopWhenRelResidBelow, if non-zero, specifies that the
+	// factorisation stops when the relative residual
+	// ||V-WH||/||V||, as returned by RelativeResidual, falls
+	// below StopWhenRelResidBelow. Unlike RelativeTolerance,
+	// the criterion excludes any regularisation penalties and
+	// applies to all objectives. It is checked in addition to
+	// the other stopping criteria. StopWhenRelResidBelow is
+	// used only by Factors and its variants; NaN entries of V
+	// are ignored when MaskNaN is set.
+	StopWhenRelResidBelow float64
+
+	// target is the objective value below which
+	// the factorisation is considered converged.
+	// It is set from RelativeTolerance when
+	// hasTarget is true.
+	target    float64
+	hasTarget bool
+
+	// residV is the matrix whose relative residual
+	// is checked against exactResidual or, if larger,
+	// StopWhenRelResidBelow, and residTarget is the
+	// corresponding bound on the squared residual
+	// norm. residV is nil unless the objective is
+	// already evaluated at each iteration. If
+	// residObjective is true, the objective is half
+	// the squared residual norm and is used in place
+	// of computing the residual.
+	residV         mat.Matrix
+	residTarget    float64
+	residObjective bool
+
+	// weights is the weight matrix of the objective
+	// minimised by FactorsWeighted. If weights is
+	// not nil, the factorisation is performed as for
+	// MaskNaN with weights in place of the NaN mask.
+	weights *mat.Dense
+
+	// clock is the source of the current time used
+	// for the time Limit and the reported durations.
+	// If clock is nil, the wall clock is used.
+	clock clock
+
+	// StopCriterion is the stopping criterion used for the
+	// Frobenius objective. Other objectives always use the
+	// RelativeObjective criterion.
+	StopCriterion StopCriterion
+
+	// Patience is the number of consecutive iterations for
+	// which the RelativeObjective criterion must be met before
+	// the factorisation stops. Values less than one are treated
+	// as one.
+	Patience int
+
+	// StagnationWindow and StagnationTolerance configure
+	// detection of a stagnated factorisation. If the relative
+	// improvement of the objective is less than
+	// StagnationTolerance for StagnationWindow consecutive
+	// iterations, the factorisation stops with Status Stalled.
+	// Stagnation is checked in addition to the stopping
+	// criterion. A zero StagnationWindow disables detection.
+	StagnationWindow    int
+	StagnationTolerance float64
+
 	// MaxIter is the maximum number of iterations performed by the
-	// main factorisation loop.
+	// main factorisation loop. If MaxIter is zero, no updates are
+	// made and the initial solutions are returned with ok false.
+	// The returned factors may then be the initial solution
+	// matrices themselves.
 	MaxIter int
 
 	// Limit is the maximum time spent by the factorisation.
+	// If Limit is zero, no time limit is applied. If Limit is
+	// negative, the factorisation stops before any updates are
+	// made.
 	Limit time.Duration
 
 	// MaxOuterSub and MaxInnerSub are the maximum number of iterations
 	// the sub-problem will perform in the outer and inner loops.
 	MaxOuterSub, MaxInnerSub int
+
+	// Accelerate specifies that the projected gradient
+	// sub-problems are solved by the accelerated projected
+	// gradient method of Nesterov, with a fixed step size
+	// and extrapolation from the previous two solutions in
+	// place of the line search. See Guan, Tao, Luo and Yuan
+	// (2012) 'NeNMF: An Optimal Gradient Method for
+	// Nonnegative Matrix Factorization.' IEEE Transactions
+	// on Signal Processing 60:2882. The line search
+	// parameters are not used when Accelerate is true.
+	Accelerate bool
+
+	// FrozenWColumns lists columns of W that are held fixed
+	// at their initial values, such as basis vectors known a
+	// priori. The gradients of the frozen columns are zeroed
+	// in the W sub-problems, while the corresponding rows of
+	// H remain free. FrozenWColumns is used only by the
+	// ProjectedGradient method. Frozen columns are altered by
+	// clamping to MaxW, by raising to ProjectionFloor, by
+	// ZeroThreshold, by the revival of a
+	// zero column when ReviveDeadComponents is set, and by the
+	// post-processing of Canonicalize and PruneZeroComponents.
+	// Factors panics if an index is not a valid column of W.
+	FrozenWColumns []int
+
+	// ProjectionFloor is the lower bound of the entries of
+	// W and H used by the ProjectedGradient method in place
+	// of zero. An entry projected to exactly zero, together
+	// with the corresponding entries of the other factor,
+	// may receive a zero gradient and so never recover; a
+	// small positive floor keeps every component able to
+	// grow back. Entries of the initial factors below the
+	// floor are raised to it. A zero ProjectionFloor
+	// specifies the usual non-negativity constraint. Factors
+	// panics if ProjectionFloor is negative, not finite or
+	// not less than a non-zero MaxW or MaxH.
+	ProjectionFloor float64
+
+	// InitialStep and StepDecay are the initial step size and
+	// the factor by which the step size is reduced or, inverted,
+	// increased by the line search of the projected gradient
+	// sub-problems. InitialStep must be positive and StepDecay
+	// must be in the open interval (0, 1). Zero values specify
+	// the defaults of 1 and 0.1.
+	InitialStep, StepDecay float64
+
+	// MaxStepSize and MinStepSize bound the step size of the
+	// line search of the projected gradient sub-problems. The
+	// search for a larger step stops, accepting the current
+	// step, when the next step would exceed MaxStepSize, and the
+	// search for a smaller step stops, leaving the sub-problem
+	// solution unchanged, when the next step would fall below
+	// MinStepSize. A zero value specifies no bound.
+	MaxStepSize, MinStepSize float64
+
+	// MaxOuterSubW, MaxInnerSubW, MaxOuterSubH and MaxInnerSubH,
+	// if non-zero, override MaxOuterSub and MaxInnerSub for the
+	// W and H sub-problems of the ProjectedGradient method.
+	MaxOuterSubW, MaxInnerSubW int
+	MaxOuterSubH, MaxInnerSubH int
+
+	// L1W and L1H are the coefficients of L1 regularisation
+	// penalties on W and H for the Frobenius objective, adding
+	// L1W*|W|_1 + L1H*|H|_1 to the objective. Larger values
+	// drive more factor entries exactly to zero, giving sparser
+	// factors. Zero values specify no L1 regularisation.
+	L1W, L1H float64
+
+	// L2W and L2H are the coefficients of L2 (Tikhonov)
+	// regularisation penalties on W and H for the Frobenius
+	// objective, adding L2W/2*||W||_F^2 + L2H/2*||H||_F^2 to
+	// the objective. The penalties shrink factor magnitudes,
+	// stabilising factorisation of ill-conditioned V, and may
+	// be combined with the L1 penalties to give an elastic net
+	// regularisation. Zero values specify no L2 regularisation.
+	L2W, L2H float64
+
+	// OrthogonalW and OrthogonalH specify that the columns of W
+	// or the rows of H are constrained to be near orthogonal,
+	// WᵀW ≈ I or HHᵀ ≈ I, giving factors with nearly disjoint
+	// support that may be interpreted as a hard clustering.
+	// When either is true, the factorisation uses the orthogonal
+	// multiplicative update rules described in Ding, Li, Peng and
+	// Park (2006) 'Orthogonal Nonnegative Matrix Tri-factorizations
+	// for Clustering.' Proceedings of the 12th ACM SIGKDD
+	// International Conference on Knowledge Discovery and Data
+	// Mining 126, and Method is ignored. Since the constrained
+	// solution is not a stationary point of the unconstrained
+	// objective, the factorisation will usually continue until
+	// MaxIter or the time Limit is reached.
+	OrthogonalW, OrthogonalH bool
+
+	// Damping, if positive, stabilises the MultiplicativeUpdate
+	// method for the Frobenius objective by adding Damping to
+	// the numerator and denominator of each update ratio and
+	// flooring the factor entries at Damping before they are
+	// scaled. The floor lets entries that have reached zero,
+	// which the undamped rule can never change, grow again,
+	// reviving dead components, and the shifted ratios are
+	// closer to one, smoothing oscillating updates. The cost
+	// is bias: entries no longer reach zero, so the factors are
+	// not exactly sparse, and the fixed points are perturbed by
+	// an amount of the order of Damping relative to the terms
+	// of the ratios. Damping should therefore be small compared
+	// to the entries of V and the factors. Zero specifies the
+	// undamped rule.
+	Damping float64
+
+	// SparsenessW and SparsenessH are target sparseness levels
+	// in [0, 1] for the columns of W and the rows of H for the
+	// Frobenius objective, as measured by Sparseness. When either
+	// is set, each constrained column of W or row of H is
+	// projected onto the nearest non-negative vector with the
+	// target sparseness after a gradient step, and unconstrained
+	// factors are updated multiplicatively, as described in Hoyer
+	// (2004) 'Non-negative Matrix Factorization with Sparseness
+	// Constraints.' Journal of Machine Learning Research 5:1457.
+	// The rows of a constrained H have unit L2 norm. Method and
+	// the regularisation fields are then ignored. Zero or NaN
+	// values leave a factor unconstrained. Since the constrained
+	// solution is not a stationary point of the unconstrained
+	// objective, the RelativeObjective stopping criterion is
+	// usually appropriate.
+	SparsenessW, SparsenessH float64
+
+	// GraphLaplacian, if not nil, is the n×n Laplacian of a
+	// similarity graph over the n columns of V, L = D - A for
+	// the affinity matrix A and its diagonal degree matrix D.
+	// GraphLambda is the weight of the graph regularisation
+	// penalty GraphLambda/2 * tr(HLHᵀ) added to the Frobenius
+	// objective, which encourages columns of V that are near in
+	// the graph to have similar encodings. When GraphLaplacian
+	// is not nil and GraphLambda is non-zero, the factorisation
+	// uses the multiplicative update rules described in Cai,
+	// He, Han and Huang (2011) 'Graph Regularized Nonnegative
+	// Matrix Factorization for Data Representation.' IEEE Trans.
+	// Pattern Anal. Mach. Intell. 33:1548, and Method is ignored.
+	// GraphLaplacian is not retained when a Model is serialised.
+	GraphLaplacian *mat.Dense `json:"-"`
+	GraphLambda    float64
+
+	// MaskNaN specifies that NaN entries of V are treated as
+	// missing values. Missing values do not contribute to the
+	// objective, so the product of the returned factors may
+	// be used to impute them. When MaskNaN is true, the
+	// factorisation minimises the Frobenius norm objective
+	// over the observed entries using multiplicative updates
+	// and Method and Objective are ignored.
+	MaskNaN bool
+
+	// WarmStart specifies that the factorisation continues from
+	// the adaptive state in State, which should be the State of
+	// the Result of a previous factorisation whose factors are
+	// passed as the initial solutions. The stopping tolerance is
+	// then scaled by the initial gradient norm of the previous
+	// factorisation rather than that of the new initial solutions,
+	// and the sub-problem tolerances of the ProjectedGradient
+	// method are retained, so a factorisation of a slowly changing
+	// matrix warm started from its previous factors will usually
+	// converge in few iterations. A zero State is ignored.
+	WarmStart bool
+	State     State
+
+	// Rand is the source of random values used for the
+	// initialisations and partitions drawn by Fit, FactorsBest
+	// and CrossValScore when they are not given a source. If
+	// Rand is nil, a source seeded with Seed is used. Rand is
+	// not retained when a Model is serialised.
+	Rand rand.Source `json:"-"`
+
+	// Seed is the seed of the random source used when Rand is
+	// nil; FactorsBest uses it as the base seed of independent
+	// sources for its restarts. A zero Seed specifies a seed
+	// taken from the current time, so results are only
+	// reproducible with a non-zero Seed or a Rand. The package
+	// never uses the global random source.
+	Seed int64
+
+	// MaxW and MaxH are upper bounds on the entries of W and H
+	// for the Frobenius objective, constraining the factors to
+	// the boxes [0, MaxW] and [0, MaxH]. The bounds are applied
+	// as projections by the ProjectedGradient and HALS methods,
+	// and by clamping after each update by the MultiplicativeUpdate
+	// method. Zero or +Inf values specify no upper bound.
+	MaxW, MaxH float64
+
+	// KeepBest specifies that the factors with the lowest
+	// objective value seen by the main factorisation loop are
+	// returned, rather than the factors at termination. The
+	// reported FinalObjective and FinalProjNorm are those of
+	// the returned factors. Setting KeepBest requires an
+	// evaluation of the objective at each iteration.
+	KeepBest bool
+
+	// ZeroThreshold, if positive, is the absolute value below
+	// which entries of the returned factors are set to zero.
+	// The threshold is applied only to the factors returned at
+	// termination, and the reported FinalObjective and
+	// FinalProjNorm are those of the factors before it is
+	// applied.
+	ZeroThreshold float64
+
+	// ReviveDeadComponents specifies that components of the
+	// factorisation that collapse to zero, with a zero column of
+	// W or zero row of H, are reinitialised after each iteration
+	// to fit the largest positive part of the residual V-WH.
+	ReviveDeadComponents bool
+
+	// PruneZeroComponents specifies that components that are
+	// zero at termination, with a zero column of W or zero row
+	// of H, are removed from the returned factors, so that the
+	// rank of the factors may be less than that of the initial
+	// solutions. The product WH is unaltered by pruning. If all
+	// the components are zero, empty factors are returned.
+	PruneZeroComponents bool
+
+	// Canonicalize specifies that the returned factors are put
+	// into a canonical form that does not depend on the order
+	// or scaling of the components of the initial solutions.
+	// Each column of W is rescaled to unit Euclidean norm, with
+	// the scale folded into the corresponding row of H, and the
+	// components are sorted in descending order of the index of
+	// the first non-zero row of their column of W, with ties
+	// broken in descending order of the norm of their row of H.
+	// Components with a zero column of W or zero row of H are
+	// set to zero and placed last.
+	Canonicalize bool
+
+	// RecordHistory specifies that the state of the factorisation
+	// at each iteration is recorded in the History field of the
+	// Result. Recording requires the objective to be evaluated at
+	// each iteration.
+	RecordHistory bool
+
+	// Concurrency is the maximum number of goroutines used to
+	// compute the matrix products and gradient projections of
+	// the projected gradient sub-problems. Values less than two
+	// specify serial computation. The factors are identical for all values
+	// of Concurrency.
+	Concurrency int
+
+	// MaxWorkingMemory, if positive, is the budget in bytes
+	// for the working memory of the products of V in the
+	// projected gradient sub-problems. When the transposed
+	// copy of V used by the W sub-problem would exceed the
+	// budget, the copy is not made, and the products of V
+	// are computed in column blocks sized so that the block
+	// operands and results fit within the budget, trading
+	// speed for a bounded peak allocation. Factors and the
+	// intermediate Gram matrices are not counted against the
+	// budget. Zero specifies no budget.
+	MaxWorkingMemory int64
+
+	// Validate specifies that Factors should check the dimensions
+	// of its inputs and that their entries are non-negative and
+	// finite before factorising. Factors panics if the inputs are
+	// not valid.
+	Validate bool
+
+	// Snapshot, if not nil, is sent copies of the current factors
+	// every SnapshotEvery iterations of the main factorisation
+	// loop, starting with the initial factors. Sends do not block;
+	// a snapshot is dropped if it cannot be sent immediately. The
+	// channel is not closed when the factorisation terminates.
+	// SnapshotEvery values less than one are treated as one.
+	// Snapshot is not retained when a Model is serialised.
+	Snapshot      chan<- Snapshot `json:"-"`
+	SnapshotEvery int
+
+	// SubproblemTrace, if not nil, is called at each step of the
+	// line search of the projected gradient sub-problems with the
+	// outer and inner iteration numbers of the sub-problem, the
+	// step size tried and whether it satisfied the sufficient
+	// decrease condition. SubproblemTrace is called for the W and
+	// H sub-problems in turn, and by Transform, FitBasis and
+	// UpdateColumns. SubproblemTrace is not retained when a Model
+	// is serialised.
+	SubproblemTrace func(outerIter, innerIter int, alpha float64, sufficient bool) `json:"-"`
+
+	// Callback, if not nil, is called at the end of each iteration
+	// of the main factorisation loop with the number of iterations
+	// completed, the projected gradient norm of the current factors
+	// and the time elapsed since the factorisation started. If
+	// Callback returns false, the factorisation is stopped and the
+	// current factors are returned. Callback is not retained when
+	// a Model is serialised.
+	Callback func(iter int, projNorm float64, elapsed time.Duration) bool `json:"-"`
+}
+
+// Result holds information about the termination of a factorisation.
+type Result struct {
+	// Iterations is the number of main loop iterations
+	// performed by the factorisation.
+	Iterations int
+
+	// InitialGradNorm is the norm of the gradient at the
+	// initial solutions. It is used to scale the stopping
+	// tolerance.
+	InitialGradNorm float64
+
+	// FinalProjNorm is the norm of the projected gradient
+	// at the returned factors.
+	FinalProjNorm float64
+
+	// FinalObjective is the value of the objective function
+	// at the returned factors. For the Frobenius objective
+	// this is half the squared Frobenius norm of V-WH plus
+	// any regularisation penalties.
+	FinalObjective float64
+
+	// Converged indicates whether the factorisation terminated
+	// because the stopping tolerance was met rather than
+	// because MaxIter or the time Limit was reached.
+	Converged bool
+
+	// Status is the reason the factorisation terminated.
+	Status ConvergenceStatus
+
+	// OK is the value returned as ok by Factors. OK reports
+	// whether the final update succeeded, which for the
+	// ProjectedGradient method is whether the sub-problems
+	// met their tolerances. It does not indicate that the
+	// factorisation converged; see Status.
+	OK bool
+
+	// Elapsed is the time spent in the factorisation.
+	Elapsed time.Duration
+
+	// ColumnScale holds the column norms of V used to scale
+	// the factorisation when Config.Preprocess is set.
+	ColumnScale []float64
+
+	// DeadComponents is the number of components of the
+	// returned factors that are zero, or that were removed
+	// when Config.PruneZeroComponents is true.
+	// RevivedComponents is the number of components
+	// reinitialised during the factorisation when
+	// Config.ReviveDeadComponents is true. Components are
+	// only tracked by Factors and its variants.
+	DeadComponents, RevivedComponents int
+
+	// Rank is the number of components of the returned
+	// factors.
+	Rank int
+
+	// State is the adaptive state of the factorisation at
+	// termination, for use with Config.WarmStart.
+	State State
+
+	// History holds the convergence history of the
+	// factorisation if Config.RecordHistory is true.
+	History []IterationStat
+
+	// PerComponentGrad holds the norm of the projected
+	// gradient of each component of the factorisation at
+	// the final iteration, the combined norm of the column
+	// of the gradient with respect to W and the row of the
+	// gradient with respect to H, if Config.RecordHistory is
+	// true. The squares of the elements sum to the square
+	// of FinalProjNorm. Components with small gradients have
+	// settled, while those with large gradients are still
+	// adjusting. PerComponentGrad is nil for the Convex, Semi
+	// and Symmetric factorisations.
+	PerComponentGrad []float64
+
+	// WSubIter and HSubIter are the numbers of outer
+	// iterations performed by the W and H sub-problems
+	// of the final update, and WSubOK and HSubOK report
+	// whether each succeeded. OK is the conjunction of
+	// WSubOK and HSubOK. A sub-problem
+	// that repeatedly reaches MaxOuterSub, or that stops
+	// at its first iteration, indicates which limits and
+	// tolerances need tuning. The fields are only set
+	// for the ProjectedGradient method.
+	WSubIter, HSubIter int
+	WSubOK, HSubOK     bool
+}
+
+// IterationStat holds the state of a factorisation at one iteration.
+type IterationStat struct {
+	// Iter is the number of iterations completed.
+	Iter int
+
+	// ProjNorm is the norm of the projected gradient.
+	ProjNorm float64
+
+	// Objective is the value of the objective function.
+	// For the Frobenius objective this is half the squared
+	// reconstruction error plus any regularisation penalties.
+	Objective float64
+
+	// Elapsed is the time since the factorisation started.
+	Elapsed time.Duration
+
+	// WSubIter, HSubIter, WSubOK and HSubOK hold the
+	// outcomes of the sub-problems of the update that
+	// produced the factors at this iteration for the
+	// ProjectedGradient method, as for Result. They are
+	// zero for the initial factors.
+	WSubIter, HSubIter int
+	WSubOK, HSubOK     bool
+
+	// WSubTime and HSubTime are the times spent in
+	// the W and H sub-problems of the update that
+	// produced the factors at this iteration for the
+	// ProjectedGradient method. They are zero for the
+	// initial factors. The remainder of the iteration
+	// time is spent in the convergence checks and the
+	// recording of the history.
+	WSubTime, HSubTime time.Duration
+}
+
+// Snapshot holds copies of the factors at an iteration of a
+// factorisation.
+type Snapshot struct {
+	// Iter is the number of iterations completed.
+	Iter int
+
+	// W and H are copies of the factors.
+	W, H *mat.Dense
 }
 
+// maxHistoryPrealloc is the maximum capacity of a convergence
+// history allocated before the factorisation starts.
+const maxHistoryPrealloc = 1 << 16
+
 // Factors returns matrices W and H that are non-negative factors of V within the
 // specified tolerance and computation limits given initial non-negative solutions Wo
 // and Ho.
-func Factors(V, Wo, Ho *mat64.Dense, c Config) (W, H *mat64.Dense, ok bool) {
-	to := time.Now()
+//
+// When the objective is evaluated at each iteration, because StopWhenRelResidBelow,
+// KeepBest, RecordHistory, RelativeTolerance or StagnationWindow is set or the
+// RelativeObjective stopping criterion is used, the residual ||V-WH|| is also
+// checked, and the factorisation stops with a Converged status as soon as the
+// residual falls below a small multiple of ||V||, since an exact factorisation
+// cannot be improved.
+//
+// If V is empty, empty factors are returned and ok is true. If V is not empty
+// and either of Wo or Ho is empty, Wo and Ho are returned and ok is false.
+func Factors(V, Wo, Ho *mat.Dense, c Config) (W, H *mat.Dense, ok bool) {
+	if c.Validate {
+		err := checkInputs(V, Wo, Ho, c.MaskNaN)
+		if err != nil {
+			panic(err)
+		}
+	}
+	W, H, res := FactorsResult(V, Wo, Ho, c)
+	return W, H, res.OK
+}
 
-	W = Wo
-	H = Ho
+// FactorsInto factorises V as Factors does, using W and H as the initial
+// solutions and overwriting them with the factors found. The receivers'
+// storage is retained, so that callers may reuse W and H across calls; the
+// factorisation itself still allocates working storage. Since the shapes of
+// W and H are retained, c.PruneZeroComponents is ignored.
+func FactorsInto(W, H, V *mat.Dense, c Config) (ok bool) {
+	c.PruneZeroComponents = false
+	if c.Validate {
+		err := checkInputs(V, W, H, c.MaskNaN)
+		if err != nil {
+			panic(err)
+		}
+	}
+	Wr, Hr, res := FactorsResult(V, W, H, c)
+	if Wr != W {
+		W.Copy(Wr)
+	}
+	if Hr != H {
+		H.Copy(Hr)
+	}
+	return res.OK
+}
+
+// FactorsResult returns matrices W and H that are non-negative factors of V within
+// the specified tolerance and computation limits given initial non-negative solutions
+// Wo and Ho. Details of the termination of the factorisation are returned in res.
+func FactorsResult(V, Wo, Ho *mat.Dense, c Config) (W, H *mat.Dense, res Result) {
+	W, H, res, _ = factors(context.Background(), V, Wo, Ho, c)
+	return W, H, res
+}
+
+// FactorsE returns matrices W and H that are non-negative factors of V within the
+// specified tolerance and computation limits given initial non-negative solutions Wo
+// and Ho. Unlike Factors, FactorsE returns an error if V is not an r×c matrix, Wo
+// an r×k matrix and Ho a k×c matrix, or if any of them contain negative or
+// non-finite entries. If the factorisation diverges, leaving non-finite entries
+// in the factors, ok is false and an error is returned.
+func FactorsE(V, Wo, Ho *mat.Dense, c Config) (W, H *mat.Dense, ok bool, err error) {
+	return FactorsContext(context.Background(), V, Wo, Ho, c)
+}
+
+// FactorsContext returns matrices W and H that are non-negative factors of V within
+// the specified tolerance and computation limits given initial non-negative solutions
+// Wo and Ho. If ctx is cancelled before the factorisation is complete, the factors
+// found so far are returned with ctx.Err(). The inputs are validated as described
+// for FactorsE.
+func FactorsContext(ctx context.Context, V, Wo, Ho *mat.Dense, c Config) (W, H *mat.Dense, ok bool, err error) {
+	err = checkInputs(V, Wo, Ho, c.MaskNaN)
+	if err != nil {
+		return Wo, Ho, false, err
+	}
+	W, H, res, err := factors(ctx, V, Wo, Ho, c)
+	return W, H, res.OK, err
+}
+
+func factors(ctx context.Context, V, Wo, Ho *mat.Dense, c Config) (W, H *mat.Dense, res Result, err error) {
+	to := c.now()
+
+	switch {
+	case V.IsEmpty():
+		// An empty matrix is trivially factorised
+		// by empty factors.
+		res.Converged = true
+		res.Status = Converged
+		res.OK = true
+		return new(mat.Dense), new(mat.Dense), res, nil
+	case Wo.IsEmpty() || Ho.IsEmpty():
+		return Wo, Ho, res, errZeroRank
+	}
+
+	if c.Preprocess != NoPreprocess {
+		scale := columnScale(V, c.Preprocess)
+		Vs := mat.DenseCopyOf(V)
+		Hs := mat.DenseCopyOf(Ho)
+		inv := func(_, j int, v float64) float64 { return v / scale[j] }
+		applyInPlace(inv, Vs)
+		applyInPlace(inv, Hs)
+
+		c.Preprocess = NoPreprocess
+		W, H, res, err = factors(ctx, Vs, Wo, Hs, c)
+
+		// H is either Hs or owned by the updater,
+		// so it may be rescaled in place.
+		applyInPlace(func(_, j int, v float64) float64 { return v * scale[j] }, H)
+		res.ColumnScale = scale
+		return W, H, res, err
+	}
 
+	var (
+		u    updater
+		grad float64
+	)
+	switch {
+	case c.MaskNaN || c.weights != nil:
+		c.Objective = Frobenius
+		weights := c.weights
+		if weights == nil {
+			weights = nanMask(V)
+		}
+		wu := newWeightedUpdate(V, weights, Wo, Ho, c)
+		grad = gradNorm(wu.gradients())
+		u = wu
+	case c.Objective == Frobenius && c.sparsenessConstrained():
+		sW, sH, _ := c.sparsenessTargets()
+		s := newSparse(V, Wo, Ho, sW, sH)
+		grad = gradNorm(gradients(V, Wo, Ho, penalty{}, penalty{}))
+		c.residObjective = true
+		u = s
+	case c.Objective == Frobenius:
+		pW, pH := c.penalties()
+		gW, gH := gradients(V, Wo, Ho, pW, pH)
+		g := newGraph(V, c)
+		g.addGradient(gH, Ho)
+		grad = gradNorm(gW, gH)
+		c.residObjective = pW == (penalty{}) && pH == (penalty{}) && g == nil
+
+		if c.OrthogonalW || c.OrthogonalH || g != nil {
+			c.Method = MultiplicativeUpdate
+		}
+		switch c.Method {
+		case ProjectedGradient:
+			tol := math.Max(0.001, c.Tolerance) * grad
+			u = newProjectedGradient(V, Wo, Ho, gW, gH, pW, pH, tol, c)
+		case MultiplicativeUpdate:
+			mu := newMultiplicativeUpdate(V, Wo, Ho, pW, pH)
+			mu.orthW, mu.orthH = c.OrthogonalW, c.OrthogonalH
+			mu.maxW, mu.maxH = c.MaxW, c.MaxH
+			if c.Damping < 0 || math.IsNaN(c.Damping) || math.IsInf(c.Damping, 1) {
+				panic(fmt.Sprintf("nmf: invalid damping: %v", c.Damping))
+			}
+			mu.damping = c.Damping
+			mu.graph = g
+			u = mu
+		case HALS:
+			h := newHierarchicalALS(V, Wo, Ho, pW, pH)
+			h.maxW, h.maxH = c.MaxW, c.MaxH
+			u = h
+		default:
+			panic("nmf: unknown method")
+		}
+	case c.Objective == KullbackLeibler:
+		kl := newKullbackLeibler(V, Wo, Ho)
+		grad = gradNorm(kl.gradients())
+		u = kl
+	case c.Objective == BetaDivergence, c.Objective == ItakuraSaito:
+		beta := c.Beta
+		if c.Objective == ItakuraSaito {
+			beta = 0
+		}
+		b := newBetaDivergence(V, Wo, Ho, beta)
+		grad = gradNorm(b.gradients())
+		u = b
+	case c.Objective == L21:
+		l := newL21(V, Wo, Ho)
+		grad = gradNorm(l.gradients())
+		u = l
+	default:
+		panic("nmf: unknown objective")
+	}
+	return run(ctx, u, V, Wo, Ho, grad, to, c)
+}
+
+// run performs the main factorisation loop of V using the update rule u
+// starting from Wo and Ho at time to, followed by the post-processing of
+// the factors specified by c. The initial gradient norm is given by grad.
+func run(ctx context.Context, u updater, V mat.Matrix, Wo, Ho *mat.Dense, grad float64, to time.Time, c Config) (W, H *mat.Dense, res Result, err error) {
+	if p, ok := u.(*projectedGradient); ok && c.WarmStart && c.State.tolW != 0 {
+		p.tolW, p.tolH = c.State.tolW, c.State.tolH
+	}
+	if c.WarmStart && c.State.grad != 0 {
+		grad = c.State.grad
+	}
+	if c.RelativeTolerance != 0 && c.Objective == Frobenius {
+		d := c.RelativeTolerance * matrixNorm(V)
+		c.target = 0.5 * d * d
+		c.hasTarget = true
+	}
+	// Stop as soon as V is factorised exactly, or
+	// when the requested relative residual is met,
+	// if the check adds little to the iteration.
+	if c.StopWhenRelResidBelow > 0 || c.evaluatesObjective() {
+		resid := math.Max(c.StopWhenRelResidBelow, exactResidual) * math.Max(matrixNorm(V), epsilon)
+		c.residV = V
+		c.residTarget = resid * resid
+	}
+
+	d := newComponents(u, V, c)
+	W, H, res, err = iterate(ctx, d, grad, to, c)
+	res.DeadComponents = d.dead
+	res.RevivedComponents = d.revived
+	if c.PruneZeroComponents {
+		dead := deadComponents(W, H)
+		if len(dead) != 0 {
+			W, H = pruneComponents(W, H, dead)
+		}
+		res.DeadComponents = len(dead)
+	}
+	if c.Canonicalize {
+		// Do not alter the initial
+		// solutions in place.
+		if W == Wo {
+			W = mat.DenseCopyOf(W)
+		}
+		if H == Ho {
+			H = mat.DenseCopyOf(H)
+		}
+		canonicalize(W, H)
+	}
+	_, res.Rank = W.Dims()
+	res.State.grad = grad
+	if p, ok := u.(*projectedGradient); ok {
+		res.State.tolW, res.State.tolH = p.tolW, p.tolH
+	}
+	return W, H, res, err
+}
+
+// State is the adaptive state of a factorisation. It is returned in
+// Result and may be passed in Config to warm start a later factorisation.
+type State struct {
+	// grad is the reference gradient norm used to
+	// scale the stopping tolerance.
+	grad float64
+
+	// tolW and tolH are the adapted tolerances
+	// of the projected gradient sub-problems.
+	tolW, tolH float64
+}
+
+// frobeniusNorm returns the Frobenius norm of m, ignoring NaN entries.
+func frobeniusNorm(m *mat.Dense) float64 {
+	var s float64
+	raw := m.RawMatrix()
+	for i := 0; i < raw.Rows; i++ {
+		for _, v := range raw.Data[i*raw.Stride : i*raw.Stride+raw.Cols] {
+			if !math.IsNaN(v) {
+				s += v * v
+			}
+		}
+	}
+	return math.Sqrt(s)
+}
+
+// copyDense copies src into dst, reusing the storage of dst
+// when it is not empty.
+func copyDense(dst, src *mat.Dense) {
+	if dst.IsEmpty() {
+		dst.CloneFrom(src)
+		return
+	}
+	dst.Copy(src)
+}
+
+// clock is a source of the current time.
+type clock interface {
+	Now() time.Time
+}
+
+// now returns the current time according to the clock of c.
+func (c Config) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock.Now()
+}
+
+// evaluatesObjective returns whether iterate evaluates the objective
+// at each iteration for the configuration c.
+func (c Config) evaluatesObjective() bool {
+	return c.KeepBest || c.RecordHistory || c.hasTarget || c.StagnationWindow > 0 ||
+		c.Objective != Frobenius || c.StopCriterion != ProjectedGradientNorm
+}
+
+// seed returns the seed of the random source of c, taken from the
+// current time if c.Seed is zero.
+func (c Config) seed() int64 {
+	if c.Seed == 0 {
+		return time.Now().UnixNano()
+	}
+	return c.Seed
+}
+
+// source returns the random source of c, c.Rand or, if c.Rand is
+// nil, a new source seeded as described for Config.Seed.
+func (c Config) source() rand.Source {
+	if c.Rand != nil {
+		return c.Rand
+	}
+	return rand.NewSource(c.seed())
+}
+
+// timedOut returns whether more than the time Limit of c has
+// elapsed since to. A zero Limit never times out.
+func (c Config) timedOut(to time.Time) bool {
+	return c.Limit != 0 && c.now().Sub(to) > c.Limit
+}
+
+// iterate performs the main factorisation loop using the update rule u
+// starting at time to. The initial gradient norm is given by grad.
+func iterate(ctx context.Context, u updater, grad float64, to time.Time, c Config) (W, H *mat.Dense, res Result, err error) {
+	patience := c.Patience
+	if patience < 1 {
+		patience = 1
+	}
+	every := c.SnapshotEvery
+	if every < 1 {
+		every = 1
+	}
+	var (
+		ok       bool
+		prev     float64
+		prevProj float64
+		run      int
+
+		// stagPrev and stagRun track the objective
+		// improvement for stagnation detection.
+		stagPrev float64
+		stagRun  int
+
+		// best, bestProj, bestW and bestH hold the
+		// lowest objective seen when KeepBest is set,
+		// and the corresponding projected gradient
+		// norm and factors.
+		best         = math.Inf(1)
+		bestProj     float64
+		bestW, bestH mat.Dense
+
+		// wh is the reconstruction used to
+		// evaluate the residual.
+		wh mat.Dense
+
+		// obj is the objective at the factors
+		// of iteration objIter.
+		obj     float64
+		objIter = -1
+	)
+	// objective returns the objective at the current
+	// factors, evaluating it at most once per iteration.
+	objective := func(i int) float64 {
+		if objIter != i {
+			obj, objIter = u.objective(), i
+		}
+		return obj
+	}
+	if c.RecordHistory {
+		n := c.MaxIter + 1
+		if n < 1 || n > maxHistoryPrealloc {
+			n = maxHistoryPrealloc
+		}
+		res.History = make([]IterationStat, 0, n)
+	}
+	for i := 0; ; i++ {
+		proj := u.projNorm()
+		res.FinalProjNorm = proj
+		if c.KeepBest {
+			if obj := objective(i); obj < best {
+				best, bestProj = obj, proj
+				W, H := u.factors()
+				copyDense(&bestW, W)
+				copyDense(&bestH, H)
+			}
+		}
+		if c.Snapshot != nil && i%every == 0 {
+			W, H := u.factors()
+			select {
+			case c.Snapshot <- Snapshot{Iter: i, W: mat.DenseCopyOf(W), H: mat.DenseCopyOf(H)}:
+			default:
+			}
+		}
+		if c.RecordHistory {
+			stat := IterationStat{
+				Iter:      i,
+				ProjNorm:  proj,
+				Objective: objective(i),
+				Elapsed:   c.now().Sub(to),
+			}
+			if s, ok := u.(subproblemReporter); ok && i != 0 {
+				stat.WSubIter, stat.HSubIter, stat.WSubOK, stat.HSubOK = s.subproblems()
+				stat.WSubTime, stat.HSubTime = s.subproblemTimes()
+			}
+			res.History = append(res.History, stat)
+		}
+		if i != 0 && c.Callback != nil && !c.Callback(i, proj, c.now().Sub(to)) {
+			res.Status = Stopped
+			break
+		}
+		if c.hasTarget {
+			if objective(i) < c.target {
+				res.Converged = true
+				res.Status = Converged
+				break
+			}
+		} else if c.Objective == Frobenius && c.StopCriterion == ProjectedGradientNorm {
+			if proj < c.Tolerance*grad {
+				res.Converged = true
+				res.Status = Converged
+				break
+			}
+			if i != 0 && proj == prevProj {
+				res.Status = Stalled
+				break
+			}
+			prevProj = proj
+		} else {
+			obj := objective(i)
+			if i != 0 && prev-obj < c.Tolerance*prev {
+				run++
+				if run >= patience {
+					res.Converged = true
+					res.Status = Converged
+					break
+				}
+			} else {
+				run = 0
+			}
+			prev = obj
+		}
+		if c.residV != nil {
+			var sq float64
+			if c.residObjective {
+				sq = 2 * objective(i)
+			} else {
+				W, H := u.factors()
+				if V, ok := c.residV.(*mat.Dense); ok {
+					sq = squaredResidual(&wh, V, W, H)
+				} else {
+					sq = sparseSquaredError(c.residV, W, H)
+				}
+			}
+			if sq < c.residTarget {
+				res.Converged = true
+				res.Status = Converged
+				break
+			}
+		}
+		if c.StagnationWindow > 0 {
+			obj := objective(i)
+			if i != 0 && stagPrev-obj < c.StagnationTolerance*stagPrev {
+				stagRun++
+				if stagRun >= c.StagnationWindow {
+					res.Status = Stalled
+					break
+				}
+			} else {
+				stagRun = 0
+			}
+			stagPrev = obj
+		}
+		if i >= c.MaxIter {
+			res.Status = MaxIterReached
+			break
+		}
+		if c.timedOut(to) {
+			res.Status = TimeLimitReached
+			break
+		}
+		if err = ctx.Err(); err != nil {
+			res.Status = Failed
+			break
+		}
+
+		ok, err = u.update(ctx)
+		if err != nil {
+			res.Status = Failed
+			break
+		}
+
+		res.Iterations++
+	}
+	W, H = u.factors()
+	if g, ok := u.(projGradienter); ok && c.RecordHistory {
+		res.PerComponentGrad = componentNorms(g.projGradients())
+	}
+
+	if s, ok := u.(subproblemReporter); ok && res.Iterations != 0 {
+		res.WSubIter, res.HSubIter, res.WSubOK, res.HSubOK = s.subproblems()
+	}
+
+	res.InitialGradNorm = grad
+	res.FinalObjective = u.objective()
+	if c.KeepBest && best < res.FinalObjective {
+		W, H = &bestW, &bestH
+		res.FinalObjective = best
+		res.FinalProjNorm = bestProj
+	}
+	if c.ZeroThreshold > 0 {
+		if res.Iterations == 0 {
+			// The factors may be the
+			// initial solutions.
+			W, H = mat.DenseCopyOf(W), mat.DenseCopyOf(H)
+		}
+		applyInPlace(zeroFilt(c.ZeroThreshold), W)
+		applyInPlace(zeroFilt(c.ZeroThreshold), H)
+	}
+	if !isFinite(W) || !isFinite(H) {
+		// The factorisation diverged, so the result
+		// is not valid whatever the updates reported.
+		ok = false
+		res.Converged = false
+		res.Status = Failed
+		if err == nil {
+			err = errNonFinite
+		}
+	}
+	res.OK = ok
+	res.Elapsed = c.now().Sub(to)
+
+	return W, H, res, err
+}
+
+// updater is a factorisation update rule.
+type updater interface {
+	// projNorm returns the norm of the projected
+	// gradient at the current factors.
+	projNorm() float64
+
+	// update performs a single iteration of the
+	// update rule, returning whether the update
+	// was successful.
+	update(ctx context.Context) (ok bool, err error)
+
+	// objective returns the value of the objective
+	// function at the current factors.
+	objective() float64
+
+	// factors returns the current factors.
+	factors() (W, H *mat.Dense)
+}
+
+// projGradienter is implemented by updaters that can return
+// the projected gradients at the current factors.
+type projGradienter interface {
+	// projGradients returns the projected gradients
+	// with respect to W and H. If the gradients are
+	// not available, nil matrices are returned.
+	projGradients() (gW, gH *mat.Dense)
+}
+
+// subproblemReporter is implemented by updaters that solve
+// separate sub-problems for W and H.
+type subproblemReporter interface {
+	// subproblems returns the numbers of outer
+	// iterations performed by the W and H
+	// sub-problems of the most recent update
+	// and whether each succeeded.
+	subproblems() (wIter, hIter int, wOK, hOK bool)
+
+	// subproblemTimes returns the times spent in
+	// the W and H sub-problems of the most recent
+	// update. The times are only measured when
+	// the history is recorded.
+	subproblemTimes() (w, h time.Duration)
+}
+
+// componentNorms returns the combined norms of the columns of gW
+// and the corresponding rows of gH. If gW is nil, nil is returned.
+func componentNorms(gW, gH *mat.Dense) []float64 {
+	if gW == nil {
+		return nil
+	}
+	r, k := gW.Dims()
+	_, c := gH.Dims()
+	norms := make([]float64, k)
+	for j := range norms {
+		var s float64
+		for i := 0; i < r; i++ {
+			v := gW.At(i, j)
+			s += v * v
+		}
+		for l := 0; l < c; l++ {
+			v := gH.At(j, l)
+			s += v * v
+		}
+		norms[j] = math.Sqrt(s)
+	}
+	return norms
+}
+
+// penalties returns the regularisation penalties on W and H
+// specified by c.
+func (c Config) penalties() (pW, pH penalty) {
+	return penalty{l1: c.L1W, l2: c.L2W}, penalty{l1: c.L1H, l2: c.L2H}
+}
+
+// penalty is a regularisation penalty on a factor.
+type penalty struct {
+	// l1 is the coefficient of the L1 norm
+	// of the factor.
+	l1 float64
+
+	// l2 is the coefficient of half the squared
+	// Frobenius norm of the factor.
+	l2 float64
+}
+
+// addGradient adds the gradient of the penalty at X to g.
+func (p penalty) addGradient(g, X *mat.Dense) {
+	if p.l2 != 0 {
+		applyInPlace(func(r, c int, v float64) float64 { return v + p.l2*X.At(r, c) }, g)
+	}
+	p.addL1(g)
+}
+
+// addL1 adds the gradient of the L1 penalty to g.
+func (p penalty) addL1(g *mat.Dense) {
+	if p.l1 != 0 {
+		applyInPlace(func(_, _ int, v float64) float64 { return v + p.l1 }, g)
+	}
+}
+
+// addGram adds the Hessian of the L2 penalty to the Gram matrix g.
+func (p penalty) addGram(g *mat.Dense) {
+	if p.l2 == 0 {
+		return
+	}
+	n, _ := g.Dims()
+	for i := 0; i < n; i++ {
+		g.Set(i, i, g.At(i, i)+p.l2)
+	}
+}
+
+// value returns the value of the penalty at X.
+func (p penalty) value(X *mat.Dense) float64 {
+	var v float64
+	if p.l1 != 0 {
+		// X is non-negative, so its L1 norm
+		// is the sum of its elements.
+		v += p.l1 * mat.Sum(X)
+	}
+	if p.l2 != 0 {
+		f := mat.Norm(X, 2)
+		v += 0.5 * p.l2 * f * f
+	}
+	return v
+}
+
+// gradients returns the gradients of the Frobenius norm objective
+// with respect to W and H, including the penalties pW and pH.
+func gradients(V mat.Matrix, W, H *mat.Dense, pW, pH penalty) (gW, gH *mat.Dense) {
 	var (
 		wr, wc = W.Dims()
 		hr, hc = H.Dims()
 
-		tmp mat64.Dense
+		tmp mat.Dense
 	)
 
-	var vhT mat64.Dense
-	gW := mat64.NewDense(wr, wc, nil)
+	var vhT mat.Dense
+	gW = mat.NewDense(wr, wc, nil)
 	tmp.Mul(H, H.T())
 	gW.Mul(W, &tmp)
-	vhT.Mul(V, H.T())
+	mul(&vhT, V, H.T(), 1)
 	gW.Sub(gW, &vhT)
+	pW.addGradient(gW, W)
 
-	var wTv mat64.Dense
-	gH := mat64.NewDense(hr, hc, nil)
+	var wTv mat.Dense
+	gH = mat.NewDense(hr, hc, nil)
 	tmp.Reset()
 	tmp.Mul(W.T(), W)
 	gH.Mul(&tmp, H)
-	wTv.Mul(W.T(), V)
+	mul(&wTv, W.T(), V, 1)
 	gH.Sub(gH, &wTv)
+	pH.addGradient(gH, H)
+
+	return gW, gH
+}
 
-	var gHT, gWHT mat64.Dense
-	gHT.Clone(gH.T())
+// gradNorm returns the norm of the gradient given the gradients
+// gW and gH.
+func gradNorm(gW, gH *mat.Dense) float64 {
+	var gHT, gWHT mat.Dense
+	gHT.CloneFrom(gH.T())
 	gWHT.Stack(gW, &gHT)
+	return mat.Norm(&gWHT, 2)
+}
 
-	grad := mat64.Norm(&gWHT, 2)
-	tolW := math.Max(0.001, c.Tolerance) * grad
-	tolH := tolW
+// frobenius returns half the squared Frobenius norm of V-WH.
+func frobenius(V mat.Matrix, W, H *mat.Dense) float64 {
+	if V, ok := V.(*mat.Dense); ok {
+		f := ReconstructionError(V, W, H, 2)
+		return 0.5 * f * f
+	}
+	return 0.5 * sparseSquaredError(V, W, H)
+}
 
-	var (
-		_ok  bool
-		iter int
-	)
+// projNorm returns the norm of the projected gradient given the
+// factors W and H and their gradients gW and gH. The gradients
+// are projected in place.
+func projNorm(gW, W, gH, H *mat.Dense) float64 {
+	return boxProjNorm(gW, W, 0, gH, H, 0)
+}
 
-	decFiltW := func(r, c int, v float64) float64 {
-		// decFiltW is applied to gW, so v = gW.At(r, c).
-		if v < 0 || W.At(r, c) > 0 {
-			return v
-		}
-		return 0
+// boxProjNorm returns the norm of the projected gradient given the
+// factors W and H, their gradients gW and gH and the upper bounds
+// of their entries, maxW and maxH. Upper bounds of zero or +Inf
+// specify no bound. The gradients are projected in place.
+func boxProjNorm(gW, W *mat.Dense, maxW float64, gH, H *mat.Dense, maxH float64) float64 {
+	projectGradient(gW, W, maxW)
+	projectGradient(gH, H, maxH)
+	return stackedNorm(gW, gH)
+}
+
+// stackedNorm returns the Frobenius norm of the matrix formed by
+// stacking gW and gH. Both must have a stride equal to their number
+// of columns.
+func stackedNorm(gW, gH *mat.Dense) float64 {
+	var proj float64
+	for _, v := range gW.RawMatrix().Data {
+		proj += v * v
+	}
+	for _, v := range gH.RawMatrix().Data {
+		proj += v * v
 	}
+	return math.Sqrt(proj)
+}
 
-	decFiltH := func(r, c int, v float64) float64 {
-		// decFiltH is applied to gH, so v = gH.At(r, c).
-		if v < 0 || H.At(r, c) > 0 {
+// decFilt returns a filter to be applied to the gradient of m
+// that retains gradient elements that would decrease the objective
+// without leaving the feasible region.
+func decFilt(m *mat.Dense) func(r, c int, v float64) float64 {
+	return func(r, c int, v float64) float64 {
+		// The filter is applied to the gradient of m,
+		// so v = g.At(r, c).
+		if v < 0 || m.At(r, c) > 0 {
 			return v
 		}
 		return 0
 	}
+}
 
-	var vT, hT, wT mat64.Dense
-	for i := 0; i < c.MaxIter; i++ {
-		gW.Apply(decFiltW, gW)
-		gH.Apply(decFiltH, gH)
+// projectGradient applies the equivalent of decFilt for a feasible
+// region bounded above by max to the gradient g of m in place. A max
+// of zero or +Inf specifies no bound. Unlike applying a filter, the
+// projection makes no allocations.
+func projectGradient(g, m *mat.Dense, max float64) {
+	projectBoundedGradient(g, m, 0, max)
+}
 
-		var proj float64
-		for _, v := range gW.RawMatrix().Data {
-			proj += v * v
+// projectBoundedGradient is the equivalent of projectGradient for a
+// feasible region bounded below by min and above by max.
+func projectBoundedGradient(g, m *mat.Dense, min, max float64) {
+	if unbounded(max) {
+		max = math.Inf(1)
+	}
+	rg, rm := g.RawMatrix(), m.RawMatrix()
+	for i := 0; i < rg.Rows; i++ {
+		row := rg.Data[i*rg.Stride : i*rg.Stride+rg.Cols]
+		x := rm.Data[i*rm.Stride : i*rm.Stride+rm.Cols]
+		for j, v := range row {
+			if (v < 0 && x[j] < max) || (v >= 0 && x[j] > min) {
+				continue
+			}
+			row[j] = 0
 		}
-		for _, v := range gH.RawMatrix().Data {
-			proj += v * v
+	}
+}
+
+// unbounded returns whether max specifies no upper bound.
+func unbounded(max float64) bool { return max == 0 || math.IsInf(max, 1) }
+
+// projectedGradient is the alternating non-negative least squares
+// update rule using projected gradient sub-problems.
+type projectedGradient struct {
+	V          mat.Matrix
+	W, H       *mat.Dense
+	gW, gH     *mat.Dense
+	pW, pH     penalty
+	tolW, tolH float64
+
+	// minTol is the floor of the adaptive
+	// sub-problem tolerances.
+	minTol float64
+
+	// outerW, innerW, outerH and innerH are the
+	// iteration limits of the W and H sub-problems.
+	outerW, innerW int
+	outerH, innerH int
+
+	// vT is the transpose of V. If V is a
+	// *mat.Dense, vT is held in vTd.
+	vT  mat.Matrix
+	vTd mat.Dense
+
+	// wT is the solution of the previous W
+	// sub-problem, and w and g hold its
+	// transpose and the transposed gradient.
+	wT   *mat.Dense
+	w, g mat.Dense
+
+	// t is reused to pass transposed factors
+	// without allocating.
+	t mat.Transpose
+
+	// workW and workH are the scratch space
+	// for the W and H sub-problems.
+	workW, workH workspace
+
+	// wIter, hIter, wOK and hOK hold the outcomes
+	// of the sub-problems of the most recent update.
+	wIter, hIter int
+	wOK, hOK     bool
+
+	// now is the clock used to time the sub-problems,
+	// and wTime and hTime hold the times spent in the
+	// sub-problems of the most recent update. now is
+	// nil unless the history is recorded.
+	now          func() time.Time
+	wTime, hTime time.Duration
+}
+
+func newProjectedGradient(V mat.Matrix, Wo, Ho, gW, gH *mat.Dense, pW, pH penalty, tol float64, c Config) *projectedGradient {
+	p := &projectedGradient{
+		V: V, W: Wo, H: Ho,
+		gW: gW, gH: gH,
+		pW: pW, pH: pH,
+		tolW: tol, tolH: tol,
+		minTol: minTolerance(gW, gH),
+		outerW: override(c.MaxOuterSubW, c.MaxOuterSub),
+		innerW: override(c.MaxInnerSubW, c.MaxInnerSub),
+		outerH: override(c.MaxOuterSubH, c.MaxOuterSub),
+		innerH: override(c.MaxInnerSubH, c.MaxInnerSub),
+	}
+	p.workW = newWorkspace(c, c.MaxW)
+	p.workH = newWorkspace(c, c.MaxH)
+	_, k := Wo.Dims()
+	for _, j := range c.FrozenWColumns {
+		if j < 0 || j >= k {
+			panic(fmt.Sprintf("nmf: frozen W column %d out of range", j))
 		}
-		proj = math.Sqrt(proj)
-		if proj < c.Tolerance*grad || time.Now().Sub(to) > c.Limit {
-			break
+	}
+	p.workW.frozen = c.FrozenWColumns
+	if c.RecordHistory {
+		p.now = c.now
+	}
+	if _, ok := V.(*mat.Dense); ok && !exceedsBudget(V, c.MaxWorkingMemory) {
+		copyInto(&p.vTd, V.T())
+		p.vT = &p.vTd
+	} else {
+		p.vT = mat.Transpose{Matrix: V}
+	}
+	return p
+}
+
+// stepEpsilon is the relative tolerance used to determine that
+// successive line search steps have reached the same point.
+const stepEpsilon = 16 * machineEpsilon
+
+// sameStep returns whether the line search candidates a and b are
+// equal to within rounding. Elements are compared relative to their
+// magnitude and to the largest element of a, so that the comparison
+// does not depend on the scale of the data.
+func sameStep(a, b *mat.Dense) bool {
+	// The candidates are non-negative.
+	abs := stepEpsilon * mat.Max(a)
+	ra, rb := a.RawMatrix(), b.RawMatrix()
+	for i := 0; i < ra.Rows; i++ {
+		rowA := ra.Data[i*ra.Stride : i*ra.Stride+ra.Cols]
+		rowB := rb.Data[i*rb.Stride : i*rb.Stride+rb.Cols]
+		for j, v := range rowA {
+			if !scalar.EqualWithinAbsOrRel(v, rowB[j], abs, stepEpsilon) {
+				return false
+			}
 		}
+	}
+	return true
+}
+
+// override returns v if it is non-zero and def otherwise.
+func override(v, def int) int {
+	if v != 0 {
+		return v
+	}
+	return def
+}
+
+// minTolerance returns the smallest sub-problem tolerance for the initial
+// gradients gW and gH. Projected gradient norms below this are dominated
+// by rounding error, so smaller tolerances cannot be met.
+func minTolerance(gW, gH *mat.Dense) float64 {
+	return math.Max(machineEpsilon*gradNorm(gW, gH), math.SmallestNonzeroFloat64)
+}
+
+// machineEpsilon is the difference between 1 and the next
+// larger float64.
+const machineEpsilon = 0x1p-52
+
+func (p *projectedGradient) projNorm() float64 {
+	projectBoundedGradient(p.gW, p.W, p.workW.floor, p.workW.upper)
+	projectBoundedGradient(p.gH, p.H, p.workH.floor, p.workH.upper)
+	return stackedNorm(p.gW, p.gH)
+}
+
+// transpose returns the transpose of m, reusing p.t so that
+// no allocation is made. The result is only valid until the
+// next call.
+func (p *projectedGradient) transpose(m *mat.Dense) mat.Matrix {
+	p.t.Matrix = m
+	return &p.t
+}
+
+func (p *projectedGradient) projGradients() (gW, gH *mat.Dense) {
+	p.projNorm()
+	return p.gW, p.gH
+}
+
+func (p *projectedGradient) update(ctx context.Context) (ok bool, err error) {
+	var (
+		_ok  bool
+		iter int
+
+		wTo mat.Matrix
+		gWT *mat.Dense
+	)
+
+	if p.wT != nil {
+		// Continue from the previous solution
+		// to avoid copying it back from p.W.
+		wTo = p.wT
+	} else {
+		wTo = p.W.T()
+	}
+	var start time.Time
+	if p.now != nil {
+		start = p.now()
+	}
+	p.wT, gWT, iter, ok, err = nnlsSubproblem(ctx, p.vT, p.transpose(p.H), wTo, p.tolW, p.outerW, p.innerW, p.pW, &p.workW)
+	if p.now != nil {
+		p.wTime = p.now().Sub(start)
+	}
+	p.wIter, p.wOK = iter, ok
+	p.hIter, p.hOK, p.hTime = 0, false, 0
+	if iter == 0 {
+		p.tolW = math.Max(0.1*p.tolW, p.minTol)
+	}
+
+	copyInto(&p.w, p.transpose(p.wT))
+	p.W = &p.w
+	copyInto(&p.g, p.transpose(gWT))
+	p.gW = &p.g
+
+	if err != nil {
+		return ok, err
+	}
+
+	if p.now != nil {
+		start = p.now()
+	}
+	p.H, p.gH, iter, _ok, err = nnlsSubproblem(ctx, p.V, p.W, p.H, p.tolH, p.outerH, p.innerH, p.pH, &p.workH)
+	if p.now != nil {
+		p.hTime = p.now().Sub(start)
+	}
+	p.hIter, p.hOK = iter, _ok
+	ok = ok && _ok
+	if iter == 0 {
+		p.tolH = math.Max(0.1*p.tolH, p.minTol)
+	}
+
+	return ok, err
+}
+
+func (p *projectedGradient) subproblems() (wIter, hIter int, wOK, hOK bool) {
+	return p.wIter, p.hIter, p.wOK, p.hOK
+}
+
+func (p *projectedGradient) subproblemTimes() (w, h time.Duration) {
+	return p.wTime, p.hTime
+}
+
+func (p *projectedGradient) objective() float64 {
+	return frobenius(p.V, p.W, p.H) + p.pW.value(p.W) + p.pH.value(p.H)
+}
+
+func (p *projectedGradient) factors() (W, H *mat.Dense) { return p.W, p.H }
+
+// refresh recomputes the gradients and discards the previous W
+// sub-problem solution after the factors have been altered.
+func (p *projectedGradient) refresh() {
+	p.wT = nil
+	p.gW, p.gH = gradients(p.V, p.W, p.H, p.pW, p.pH)
+}
 
-		vT.Clone(V.T())
-		hT.Clone(H.T())
-		wT.Clone(W.T())
-		W, gW, iter, ok = nnlsSubproblem(&vT, &hT, &wT, tolW, c.MaxOuterSub, c.MaxInnerSub)
-		if iter == 0 {
-			tolW *= 0.1
+// checkSteps panics if the line search parameters of c are invalid.
+func (c Config) checkSteps() {
+	switch {
+	case c.InitialStep < 0 || math.IsNaN(c.InitialStep) || math.IsInf(c.InitialStep, 1):
+		panic(fmt.Sprintf("nmf: invalid initial step size: %v", c.InitialStep))
+	case c.StepDecay < 0 || c.StepDecay >= 1 || math.IsNaN(c.StepDecay):
+		panic(fmt.Sprintf("nmf: step decay must be in (0, 1): %v", c.StepDecay))
+	}
+}
+
+// workspace holds scratch matrices for nnlsSubproblem, allowing
+// them to be reused between calls.
+type workspace struct {
+	WtV, WtW mat.Dense
+	G, d, dQ mat.Dense
+
+	// buf holds the current, previous and candidate
+	// solutions used by the line search.
+	buf [3]mat.Dense
+
+	// t is reused to hold the transpose of W.
+	t mat.Transpose
+
+	// y is the extrapolated solution used
+	// when accelerate is true.
+	y mat.Dense
+
+	// accelerate specifies that the accelerated
+	// projected gradient method is used in place
+	// of the line search.
+	accelerate bool
+
+	// concurrency is the maximum number of goroutines
+	// used to compute matrix products.
+	concurrency int
+
+	// maxMemory is the working memory budget in bytes
+	// for the products of V. Zero specifies no budget.
+	maxMemory int64
+
+	// frozen holds the indices of rows of the
+	// solution that are held fixed.
+	frozen []int
+
+	// floor and upper are the lower and upper bounds
+	// of the solution. An upper of zero or +Inf
+	// specifies no upper bound.
+	floor, upper float64
+
+	// alpha and beta are the initial step size and the
+	// step size decay of the line search. Zero values
+	// specify the defaults.
+	alpha, beta float64
+
+	// maxStep and minStep are the bounds of the line
+	// search step size. Zero specifies no bound.
+	maxStep, minStep float64
+
+	// trace, if not nil, is called at each step of
+	// the line search.
+	trace func(outerIter, innerIter int, alpha float64, sufficient bool)
+}
+
+// newWorkspace returns a workspace for the sub-problems of a factor
+// bounded above by upper, configured by the sub-problem fields of c.
+// It panics if the step sizes or ProjectionFloor of c are invalid.
+func newWorkspace(c Config, upper float64) workspace {
+	c.checkSteps()
+	if f := c.ProjectionFloor; f < 0 || math.IsInf(f, 1) || math.IsNaN(f) || (!unbounded(upper) && f >= upper) {
+		panic(fmt.Sprintf("nmf: invalid projection floor: %v", f))
+	}
+	return workspace{
+		accelerate:  c.Accelerate,
+		concurrency: c.Concurrency,
+		maxMemory:   c.MaxWorkingMemory,
+		floor:       c.ProjectionFloor,
+		upper:       upper,
+		alpha:       c.InitialStep,
+		beta:        c.StepDecay,
+		maxStep:     c.MaxStepSize,
+		minStep:     c.MinStepSize,
+		trace:       c.SubproblemTrace,
+	}
+}
+
+// solution returns a workspace buffer holding the values of Ho. If Ho
+// is already a workspace buffer it is returned unaltered. If the
+// workspace has a floor, entries below it are raised to the floor.
+func (w *workspace) solution(Ho mat.Matrix) *mat.Dense {
+	H := w.bufferOf(Ho)
+	if w.floor != 0 {
+		apply(floorFilt(w.floor, w.upper), H, w.concurrency)
+	}
+	return H
+}
+
+// bufferOf returns a workspace buffer holding the values of Ho,
+// reusing Ho if it is already a workspace buffer.
+func (w *workspace) bufferOf(Ho mat.Matrix) *mat.Dense {
+	for i := range w.buf {
+		if b := &w.buf[i]; Ho == mat.Matrix(b) {
+			return b
 		}
+	}
+	copyInto(&w.buf[0], Ho)
+	return &w.buf[0]
+}
 
-		wT.Reset()
-		wT.Clone(W.T())
-		W = &wT
+// freeze zeros the rows of the gradient g corresponding to the
+// frozen rows of the solution so that they are not updated.
+func (w *workspace) freeze(g *mat.Dense) {
+	for _, i := range w.frozen {
+		row := g.RawRowView(i)
+		for j := range row {
+			row[j] = 0
+		}
+	}
+}
 
-		var gWT mat64.Dense
-		gWT.Clone(gW.T())
-		*gW = gWT
+// transpose returns the transpose of m without allocating. If m is a
+// transpose, its underlying matrix is returned, otherwise the result
+// is held by w and is only valid until the next call.
+func (w *workspace) transpose(m mat.Matrix) mat.Matrix {
+	switch m := m.(type) {
+	case *mat.Transpose:
+		return m.Matrix
+	case mat.Transpose:
+		return m.Matrix
+	}
+	w.t.Matrix = m
+	return &w.t
+}
 
-		H, gH, iter, _ok = nnlsSubproblem(V, W, H, tolH, c.MaxOuterSub, c.MaxInnerSub)
-		ok = ok && _ok
-		if iter == 0 {
-			tolH *= 0.1
+// candidate returns a workspace buffer that is distinct from
+// both cur and prev.
+func (w *workspace) candidate(cur, prev *mat.Dense) *mat.Dense {
+	for i := range w.buf {
+		if b := &w.buf[i]; b != cur && b != prev {
+			return b
 		}
 	}
+	panic("nmf: no free workspace buffer")
+}
 
-	return W, H, ok
+// copyInto copies a into m, reusing the backing data of m if it
+// has sufficient capacity. Unlike Copy, the shape of m is changed
+// to match a.
+func copyInto(m *mat.Dense, a mat.Matrix) {
+	m.Reset()
+	m.Apply(func(_, _ int, v float64) float64 { return v }, a)
+}
+
+// applyInPlace replaces each element of m with the result of fn,
+// avoiding the allocation made by m.Apply(fn, m).
+func applyInPlace(fn func(r, c int, v float64) float64, m *mat.Dense) {
+	raw := m.RawMatrix()
+	for i := 0; i < raw.Rows; i++ {
+		row := raw.Data[i*raw.Stride : i*raw.Stride+raw.Cols]
+		for j, v := range row {
+			row[j] = fn(i, j, v)
+		}
+	}
 }
 
 func posFilt(r, c int, v float64) float64 {
@@ -145,51 +1897,111 @@ func posFilt(r, c int, v float64) float64 {
 	return 0
 }
 
-func nnlsSubproblem(V, W, Ho *mat64.Dense, tol float64, outer, inner int) (H, G *mat64.Dense, i int, ok bool) {
-	H = new(mat64.Dense)
-	H.Clone(Ho)
+// zeroFilt returns a filter that sets values with absolute
+// value below threshold to zero.
+func zeroFilt(threshold float64) func(r, c int, v float64) float64 {
+	return func(_, _ int, v float64) float64 {
+		if math.Abs(v) < threshold {
+			return 0
+		}
+		return v
+	}
+}
+
+// floorFilt returns a filter that projects values onto [min, max].
+// A max of zero or +Inf specifies no upper bound.
+func floorFilt(min, max float64) func(r, c int, v float64) float64 {
+	if min == 0 {
+		return boxFilt(max)
+	}
+	if unbounded(max) {
+		max = math.Inf(1)
+	}
+	return func(_, _ int, v float64) float64 {
+		return math.Min(math.Max(v, min), max)
+	}
+}
+
+// boxFilt returns a filter that projects values onto [0, max].
+// A max of zero or +Inf specifies no upper bound.
+func boxFilt(max float64) func(r, c int, v float64) float64 {
+	if unbounded(max) {
+		return posFilt
+	}
+	return func(r, c int, v float64) float64 {
+		return math.Min(posFilt(r, c, v), max)
+	}
+}
 
-	var WtV, WtW mat64.Dense
-	WtV.Mul(W.T(), V)
-	WtW.Mul(W.T(), W)
+// nnlsSubproblem solves min ||V - WH|| for H >= 0 by projected gradient from
+// the initial solution Ho. Scratch space is taken from work, which may be nil.
+// If work is not nil, the returned H and G are owned by work and are only
+// valid until the next call with the same work. Once the buffers of work
+// have grown to the size of the problem, repeated calls make no allocations
+// unless V or W is sparse or the products are computed concurrently.
+func nnlsSubproblem(ctx context.Context, V, W, Ho mat.Matrix, tol float64, outer, inner int, pen penalty, work *workspace) (H, G *mat.Dense, i int, ok bool, err error) {
+	if work == nil {
+		work = new(workspace)
+	}
+	if work.accelerate {
+		return nnlsAccelerated(ctx, V, W, Ho, tol, outer, pen, work)
+	}
+	H = work.solution(Ho)
+	WtV, WtW := work.gram(V, W, pen)
 
 	alpha, beta := 1., 0.1
-
-	decFilt := func(r, c int, v float64) float64 {
-		// decFilt is applied to G, so v = G.At(r, c).
-		if v < 0 || H.At(r, c) > 0 {
-			return v
-		}
-		return 0
+	if work.alpha != 0 {
+		alpha = work.alpha
+	}
+	if work.beta != 0 {
+		beta = work.beta
+	}
+	if work.maxStep != 0 {
+		alpha = math.Min(alpha, work.maxStep)
 	}
 
-	G = new(mat64.Dense)
+	project := floorFilt(work.floor, work.upper)
+
+	G, d, dQ := &work.G, &work.d, &work.dQ
+	G.Reset()
+	d.Reset()
+	dQ.Reset()
 	for i = 0; i < outer; i++ {
-		G.Mul(&WtW, H)
-		G.Sub(G, &WtV)
-		G.Apply(decFilt, G)
+		mul(G, WtW, H, work.concurrency)
+		G.Sub(G, WtV)
+		pen.addL1(G)
+		parallelProjectGradient(G, H, work.floor, work.upper, work.concurrency)
+		work.freeze(G)
 
-		if mat64.Norm(G, 2) < tol {
+		// Check for cancellation after the gradient is
+		// computed so that G is valid for the returned H.
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		if mat.Norm(G, 2) < tol {
 			break
 		}
 
 		var (
 			reduce bool
-			Hp     *mat64.Dense
-			d, dQ  mat64.Dense
+			Hp     *mat.Dense
 		)
 		for j := 0; j < inner; j++ {
-			var Hn mat64.Dense
+			Hn := work.candidate(H, Hp)
+			Hn.Reset()
 			Hn.Scale(alpha, G)
-			Hn.Sub(H, &Hn)
-			Hn.Apply(posFilt, &Hn)
+			Hn.Sub(H, Hn)
+			apply(project, Hn, work.concurrency)
 
-			d.Sub(&Hn, H)
-			dQ.Mul(&WtW, &d)
-			dQ.MulElem(&dQ, &d)
-			d.MulElem(G, &d)
+			d.Sub(Hn, H)
+			mul(dQ, WtW, d, work.concurrency)
+			dQ.MulElem(dQ, d)
+			d.MulElem(G, d)
 
-			sufficient := 0.99*mat64.Sum(&d)+0.5*mat64.Sum(&dQ) < 0
+			sufficient := 0.99*mat.Sum(d)+0.5*mat.Sum(dQ) < 0
+			if work.trace != nil {
+				work.trace(i, j, alpha, sufficient)
+			}
 
 			if j == 0 {
 				reduce = !sufficient
@@ -197,23 +2009,124 @@ func nnlsSubproblem(V, W, Ho *mat64.Dense, tol float64, outer, inner int) (H, G
 			}
 			if reduce {
 				if sufficient {
-					H = &Hn
+					H = Hn
 					ok = true
 					break
 				} else {
+					if alpha*beta < work.minStep {
+						break
+					}
 					alpha *= beta
 				}
 			} else {
-				if !sufficient || mat64.Equal(Hp, &Hn) {
+				if !sufficient || sameStep(Hp, Hn) {
 					H = Hp
 					break
 				} else {
+					if work.maxStep != 0 && alpha/beta > work.maxStep {
+						H = Hn
+						break
+					}
 					alpha /= beta
-					Hp = &Hn
+					Hp = Hn
 				}
 			}
 		}
 	}
 
-	return H, G, i, ok
+	return H, G, i, ok, err
+}
+
+// gram computes WᵀV and the penalised Gram matrix WᵀW into the
+// workspace and returns them.
+func (w *workspace) gram(V, W mat.Matrix, pen penalty) (WtV, WtW *mat.Dense) {
+	Wt := w.transpose(W)
+	WtV, WtW = &w.WtV, &w.WtW
+	WtV.Reset()
+	blockedMul(WtV, Wt, V, w.concurrency, w.maxMemory)
+	WtW.Reset()
+	mul(WtW, Wt, W, w.concurrency)
+	pen.addGram(WtW)
+	return WtV, WtW
+}
+
+// nnlsAccelerated is the equivalent of nnlsSubproblem using the accelerated
+// projected gradient method. Each iteration takes a projected gradient step
+// of size 1/L, where L is the largest eigenvalue of the penalised Gram
+// matrix, from a point extrapolated from the two previous solutions and
+// projected onto the feasible region. The step size reported to the trace
+// is 1/L. Scratch space is taken from work, which must not be nil.
+func nnlsAccelerated(ctx context.Context, V, W, Ho mat.Matrix, tol float64, outer int, pen penalty, work *workspace) (H, G *mat.Dense, i int, ok bool, err error) {
+	H = work.solution(Ho)
+	WtV, WtW := work.gram(V, W, pen)
+
+	r, _ := WtW.Dims()
+	sym := mat.NewSymDense(r, nil)
+	for j := 0; j < r; j++ {
+		for k := j; k < r; k++ {
+			sym.SetSym(j, k, WtW.At(j, k))
+		}
+	}
+	var (
+		eig       mat.EigenSym
+		lipschitz float64
+	)
+	if eig.Factorize(sym, false) {
+		lipschitz = floats.Max(eig.Values(nil))
+	} else {
+		// Fall back to the Frobenius norm, an
+		// upper bound on the largest eigenvalue.
+		lipschitz = mat.Norm(WtW, 2)
+	}
+	step := 1 / lipschitz
+
+	project := floorFilt(work.floor, work.upper)
+	G, Y, d := &work.G, &work.y, &work.d
+	G.Reset()
+	d.Reset()
+	copyInto(Y, H)
+	theta := 1.
+	for i = 0; i < outer; i++ {
+		mul(G, WtW, H, work.concurrency)
+		G.Sub(G, WtV)
+		pen.addL1(G)
+		parallelProjectGradient(G, H, work.floor, work.upper, work.concurrency)
+		work.freeze(G)
+
+		// Check for cancellation after the gradient is
+		// computed so that G is valid for the returned H.
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		if mat.Norm(G, 2) < tol || lipschitz == 0 {
+			break
+		}
+
+		// Take a projected gradient step from Y.
+		mul(d, WtW, Y, work.concurrency)
+		d.Sub(d, WtV)
+		pen.addL1(d)
+		work.freeze(d)
+		Hn := work.candidate(H, nil)
+		Hn.Reset()
+		Hn.Scale(-step, d)
+		Hn.Add(Y, Hn)
+		apply(project, Hn, work.concurrency)
+		if work.trace != nil {
+			work.trace(i, 0, step, true)
+		}
+
+		// Extrapolate from H and Hn.
+		next := (1 + math.Sqrt(1+4*theta*theta)) / 2
+		Y.Sub(Hn, H)
+		Y.Scale((theta-1)/next, Y)
+		Y.Add(Hn, Y)
+		apply(project, Y, work.concurrency)
+		theta = next
+
+		H = Hn
+		ok = true
+	}
+
+	return H, G, i, ok, err
 }
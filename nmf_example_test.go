@@ -6,31 +6,22 @@ package nmf_test
 
 import (
 	"fmt"
-	"math"
 	"math/rand"
 	"time"
 
-	"github.com/gonum/matrix/mat64"
 	"github.com/kortschak/nmf"
+	"gonum.org/v1/gonum/mat"
 )
 
-func posNorm(_, _ int, _ float64) float64 { return math.Abs(rand.NormFloat64()) }
-
 func ExampleFactors() {
-	rand.Seed(1)
-
-	V := mat64.NewDense(3, 4, []float64{20, 0, 30, 0, 0, 16, 1, 9, 0, 10, 6, 11})
-	fmt.Printf("V =\n%.3f\n\n", mat64.Formatted(V))
+	V := mat.NewDense(3, 4, []float64{20, 0, 30, 0, 0, 16, 1, 9, 0, 10, 6, 11})
+	fmt.Printf("V =\n%.3f\n\n", mat.Formatted(V))
 
 	categories := 5
 
 	rows, cols := V.Dims()
 
-	Wo := mat64.NewDense(rows, categories, nil)
-	Wo.Apply(posNorm, Wo)
-
-	Ho := mat64.NewDense(categories, cols, nil)
-	Ho.Apply(posNorm, Ho)
+	Wo, Ho := nmf.InitRandom(rows, cols, categories, nmf.HalfNormal, rand.NewSource(1))
 
 	conf := nmf.Config{
 		Tolerance:   1e-5,
@@ -42,14 +33,14 @@ func ExampleFactors() {
 
 	W, H, ok := nmf.Factors(V, Wo, Ho, conf)
 
-	var P, D mat64.Dense
+	var P, D mat.Dense
 	P.Mul(W, H)
 	D.Sub(V, &P)
 
 	fmt.Printf("Successfully factorised: %v\n\n", ok)
-	fmt.Printf("W =\n%.3f\n\nH =\n%.3f\n\n", mat64.Formatted(W), mat64.Formatted(H))
-	fmt.Printf("P =\n%.3f\n\n", mat64.Formatted(&P))
-	fmt.Printf("delta = %.3f\n", mat64.Norm(&D, 2))
+	fmt.Printf("W =\n%.3f\n\nH =\n%.3f\n\n", mat.Formatted(W), mat.Formatted(H))
+	fmt.Printf("P =\n%.3f\n\n", mat.Formatted(&P))
+	fmt.Printf("delta = %.3f\n", mat.Norm(&D, 2))
 
 	// Output:
 	// V =
@@ -78,3 +69,19 @@ func ExampleFactors() {
 	//
 	// delta = 0.000
 }
+
+func ExampleFit() {
+	V := mat.NewDense(3, 4, []float64{20, 0, 30, 0, 0, 16, 1, 9, 0, 10, 6, 11})
+
+	conf := nmf.NewConfig(nmf.WithTolerance(1e-5))
+	conf.Rand = rand.NewSource(1)
+
+	W, H, ok := nmf.Fit(V, 3, conf)
+
+	fmt.Printf("Successfully factorised: %v\n", ok)
+	fmt.Printf("delta = %.3f\n", nmf.ReconstructionError(V, W, H, 2))
+
+	// Output:
+	// Successfully factorised: true
+	// delta = 0.000
+}
@@ -0,0 +1,1662 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"gonum.org/v1/gonum/blas/blas32"
+	"gonum.org/v1/gonum/mat"
+)
+
+// testConfig is the base configuration for tests. Tests that
+// compare factors from separate runs clear Limit so that the
+// comparison does not depend on timing.
+var testConfig = Config{
+	Tolerance:   1e-5,
+	MaxIter:     100,
+	MaxOuterSub: 1000,
+	MaxInnerSub: 20,
+	Limit:       time.Second,
+}
+
+func TestFactorsEErrors(t *testing.T) {
+	for _, test := range []struct {
+		V, Wo, Ho *mat.Dense
+		want      string
+	}{
+		{
+			V:    mat.NewDense(3, 4, nil),
+			Wo:   mat.NewDense(3, 5, nil),
+			Ho:   mat.NewDense(4, 4, nil),
+			want: "nmf: Wo columns (5) must equal Ho rows (4)",
+		},
+		{
+			V:    mat.NewDense(3, 4, nil),
+			Wo:   mat.NewDense(2, 5, nil),
+			Ho:   mat.NewDense(5, 4, nil),
+			want: "nmf: V rows (3) must equal Wo rows (2)",
+		},
+		{
+			V:    mat.NewDense(3, 4, nil),
+			Wo:   mat.NewDense(3, 5, nil),
+			Ho:   mat.NewDense(5, 3, nil),
+			want: "nmf: V columns (4) must equal Ho columns (3)",
+		},
+		{
+			V:    mat.NewDense(1, 2, []float64{0, -1}),
+			Wo:   mat.NewDense(1, 1, nil),
+			Ho:   mat.NewDense(1, 2, nil),
+			want: "nmf: V has negative entry -1 at (0, 1)",
+		},
+		{
+			V:    mat.NewDense(1, 2, nil),
+			Wo:   mat.NewDense(1, 1, []float64{math.NaN()}),
+			Ho:   mat.NewDense(1, 2, nil),
+			want: "nmf: Wo has NaN entry at (0, 0)",
+		},
+		{
+			V:    mat.NewDense(1, 2, nil),
+			Wo:   mat.NewDense(1, 1, nil),
+			Ho:   mat.NewDense(1, 2, []float64{0, math.Inf(1)}),
+			want: "nmf: Ho has infinite entry at (0, 1)",
+		},
+	} {
+		_, _, _, err := FactorsE(test.V, test.Wo, test.Ho, testConfig)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("unexpected error: got:%v want:%s", err, test.want)
+		}
+	}
+}
+
+func TestCheckNonNegative(t *testing.T) {
+	for _, test := range []struct {
+		m    *mat.Dense
+		want string
+	}{
+		{m: mat.NewDense(2, 2, []float64{0, 1, 2, 3})},
+		{m: mat.NewDense(2, 2, []float64{0, 1, -2, 3}), want: "nmf: negative entry -2 at (1, 0)"},
+		{m: mat.NewDense(2, 2, []float64{0, math.NaN(), -2, 3}), want: "nmf: NaN entry at (0, 1)"},
+		{m: mat.NewDense(2, 2, []float64{0, 1, 2, math.Inf(1)}), want: "nmf: infinite entry at (1, 1)"},
+		{m: mat.NewDense(2, 3, []float64{0, 1, -1, 2, 3, 4}).Slice(0, 2, 0, 2).(*mat.Dense)},
+	} {
+		err := CheckNonNegative(test.m)
+		var got string
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.want {
+			t.Errorf("unexpected error: got:%q want:%q", got, test.want)
+		}
+	}
+}
+
+// testFactors returns the matrix and initial factors used by the package example.
+func testFactors() (V, Wo, Ho *mat.Dense) {
+	V = mat.NewDense(3, 4, []float64{20, 0, 30, 0, 0, 16, 1, 9, 0, 10, 6, 11})
+	Wo = mat.NewDense(3, 5, []float64{
+		0.791, 1.009, 0.133, 0.836, 1.271,
+		0.599, 0.220, 0.133, 1.391, 0.083,
+		0.263, 0.952, 0.288, 1.124, 0.603,
+	})
+	Ho = mat.NewDense(5, 4, []float64{
+		0.678, 0.171, 0.684, 0.235,
+		1.147, 0.540, 0.250, 1.310,
+		0.550, 1.392, 0.608, 0.495,
+		0.047, 0.163, 0.106, 0.920,
+		1.711, 0.395, 0.233, 0.783,
+	})
+	return V, Wo, Ho
+}
+
+func reconstructionDelta(V, W, H *mat.Dense) float64 {
+	var P, D mat.Dense
+	P.Mul(W, H)
+	D.Sub(V, &P)
+	return mat.Norm(&D, 2)
+}
+
+func TestMultiplicativeUpdate(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	wo := mat.DenseCopyOf(Wo)
+	ho := mat.DenseCopyOf(Ho)
+
+	c := testConfig
+	c.Method = MultiplicativeUpdate
+	c.MaxIter = 5000
+	W, H, ok := Factors(V, Wo, Ho, c)
+	if !ok {
+		t.Error("unexpected failure")
+	}
+	if !mat.Equal(Wo, wo) || !mat.Equal(Ho, ho) {
+		t.Error("initial factors modified")
+	}
+	if err := CheckNonNegative(W); err != nil {
+		t.Errorf("invalid W: %v", err)
+	}
+	if err := CheckNonNegative(H); err != nil {
+		t.Errorf("invalid H: %v", err)
+	}
+	before := reconstructionDelta(V, Wo, Ho)
+	after := reconstructionDelta(V, W, H)
+	if after > 1e-2*before {
+		t.Errorf("unexpected reconstruction error: got:%v initial:%v", after, before)
+	}
+}
+
+func TestKullbackLeibler(t *testing.T) {
+	V, Wo, Ho := testFactors()
+
+	c := testConfig
+	c.Objective = KullbackLeibler
+	c.Tolerance = 1e-10
+	c.MaxIter = 5000
+	W, H, res := FactorsResult(V, Wo, Ho, c)
+	if !res.OK {
+		t.Error("unexpected failure")
+	}
+	if err := CheckNonNegative(W); err != nil {
+		t.Errorf("invalid W: %v", err)
+	}
+	if err := CheckNonNegative(H); err != nil {
+		t.Errorf("invalid H: %v", err)
+	}
+	if math.IsInf(res.FinalObjective, 0) || math.IsNaN(res.FinalObjective) {
+		t.Fatalf("non-finite divergence: %v", res.FinalObjective)
+	}
+	initial := divergence(V, Wo, Ho)
+	if res.FinalObjective > 1e-2*initial {
+		t.Errorf("unexpected divergence: got:%v initial:%v", res.FinalObjective, initial)
+	}
+}
+
+func zeros(m *mat.Dense) int {
+	var n int
+	r, c := m.Dims()
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			if m.At(i, j) == 0 {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestL1Regularisation(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	for _, method := range []Method{ProjectedGradient, MultiplicativeUpdate} {
+		c := testConfig
+		c.Method = method
+		W, H, _ := Factors(V, Wo, Ho, c)
+		nW, nH := zeros(W), zeros(H)
+		sW, sH := mat.Sum(W), mat.Sum(H)
+
+		c.L1W = 2
+		c.L1H = 2
+		W, H, _ = Factors(V, Wo, Ho, c)
+		switch method {
+		case ProjectedGradient:
+			if zeros(W)+zeros(H) <= nW+nH {
+				t.Errorf("expected sparser factors: got:%d zeros want:>%d zeros", zeros(W)+zeros(H), nW+nH)
+			}
+		case MultiplicativeUpdate:
+			// Multiplicative updates do not reach
+			// exact zeros, so check the L1 norms.
+			if mat.Sum(W)+mat.Sum(H) >= sW+sH {
+				t.Errorf("expected smaller factors: got:%v want:<%v", mat.Sum(W)+mat.Sum(H), sW+sH)
+			}
+		}
+	}
+}
+
+func TestL2Regularisation(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	for _, method := range []Method{ProjectedGradient, MultiplicativeUpdate} {
+		prev := math.Inf(1)
+		for _, lambda := range []float64{0, 1, 10, 100} {
+			c := testConfig
+			c.Method = method
+			c.L2W = lambda
+			c.L2H = lambda
+			W, H, _ := Factors(V, Wo, Ho, c)
+			nW, nH := mat.Norm(W, 2), mat.Norm(H, 2)
+			mag := nW*nW + nH*nH
+			if mag >= prev {
+				t.Errorf("factor magnitude not reduced for method %d with lambda=%v: got:%v previous:%v",
+					method, lambda, mag, prev)
+			}
+			prev = mag
+		}
+	}
+}
+
+func TestHALS(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	c := testConfig
+	c.Method = HALS
+	c.MaxIter = 1000
+	W, H, res := FactorsResult(V, Wo, Ho, c)
+	if !res.Converged {
+		t.Errorf("failed to converge in %d iterations", res.Iterations)
+	}
+	if err := CheckNonNegative(W); err != nil {
+		t.Errorf("invalid W: %v", err)
+	}
+	if err := CheckNonNegative(H); err != nil {
+		t.Errorf("invalid H: %v", err)
+	}
+	if d := ReconstructionError(V, W, H, 2); d > 1e-3 {
+		t.Errorf("unexpected reconstruction error: %v", d)
+	}
+}
+
+func TestOrthogonalH(t *testing.T) {
+	// Construct V from clusters of columns so that it
+	// has a factorisation with orthogonal rows of H.
+	const rows, cols, k = 40, 30, 3
+	rnd := rand.New(rand.NewSource(1))
+	W0, _ := InitRandom(rows, cols, k, Uniform, rnd)
+	H0 := mat.NewDense(k, cols, nil)
+	for j := 0; j < cols; j++ {
+		H0.Set(rnd.Intn(k), j, 0.5+rnd.Float64())
+	}
+	var V mat.Dense
+	V.Mul(W0, H0)
+	Wo, Ho := InitRandom(rows, cols, k, Uniform, rnd)
+
+	// offDiagonal returns the largest off-diagonal element
+	// of the Gram matrix of the normalised rows of H.
+	offDiagonal := func(H *mat.Dense) float64 {
+		var g mat.Dense
+		g.Mul(H, H.T())
+		var max float64
+		k, _ := g.Dims()
+		for i := 0; i < k; i++ {
+			for j := i + 1; j < k; j++ {
+				max = math.Max(max, g.At(i, j)/math.Sqrt(g.At(i, i)*g.At(j, j)))
+			}
+		}
+		return max
+	}
+
+	c := testConfig
+	c.Method = MultiplicativeUpdate
+	c.MaxIter = 2000
+	_, H, _ := Factors(&V, Wo, Ho, c)
+	unconstrained := offDiagonal(H)
+
+	c.OrthogonalH = true
+	_, H, _ = Factors(&V, Wo, Ho, c)
+	if err := CheckNonNegative(H); err != nil {
+		t.Errorf("invalid H: %v", err)
+	}
+	if got := offDiagonal(H); got > 0.1 || got >= unconstrained {
+		t.Errorf("rows of H not near orthogonal: got:%v unconstrained:%v", got, unconstrained)
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	same := func(a, b *mat.Dense) bool {
+		ad, bd := a.RawMatrix().Data, b.RawMatrix().Data
+		if len(ad) != len(bd) {
+			return false
+		}
+		for i := range ad {
+			if math.Float64bits(ad[i]) != math.Float64bits(bd[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	V, _, _ := lowRank(30, 20, 3, rand.NewSource(1))
+	rows, cols := V.Dims()
+	for _, test := range []Config{
+		{Method: ProjectedGradient},
+		{Method: ProjectedGradient, Concurrency: 4},
+		{Method: MultiplicativeUpdate},
+		{Method: HALS},
+		{Objective: KullbackLeibler},
+	} {
+		c := testConfig
+		c.Method = test.Method
+		c.Objective = test.Objective
+		c.Concurrency = test.Concurrency
+		c.MaxIter = 20
+		c.Limit = 0
+
+		var W, H [2]*mat.Dense
+		for i := range W {
+			Wo, Ho := InitRandom(rows, cols, 3, Uniform, rand.NewSource(2))
+			W[i], H[i], _ = Factors(V, Wo, Ho, c)
+		}
+		if !same(W[0], W[1]) || !same(H[0], H[1]) {
+			t.Errorf("factors not reproducible for %+v", test)
+		}
+	}
+
+	c := testConfig
+	c.Limit = 0
+	var W, H [2]*mat.Dense
+	for i := range W {
+		W[i], H[i], _ = FactorsBest(V, 3, 4, c, rand.NewSource(2))
+	}
+	if !same(W[0], W[1]) || !same(H[0], H[1]) {
+		t.Error("FactorsBest factors not reproducible")
+	}
+}
+
+func TestRelativeObjective(t *testing.T) {
+	// Factorise with a lower rank than V so that
+	// the objective does not decrease to zero.
+	V, _, _ := lowRank(30, 20, 4, rand.NewSource(1))
+	Wo, Ho := InitRandom(30, 20, 2, Uniform, rand.NewSource(2))
+	const tol = 1e-3
+	prev := -1
+	for _, patience := range []int{1, 5} {
+		c := testConfig
+		c.Tolerance = tol
+		c.StopCriterion = RelativeObjective
+		c.Patience = patience
+		_, _, res := FactorsResult(V, Wo, Ho, c)
+		if !res.Converged {
+			t.Errorf("failed to converge in %d iterations with patience %d", res.Iterations, patience)
+		}
+		if res.Iterations <= prev {
+			t.Errorf("patience %d did not increase iterations: %d <= %d", patience, res.Iterations, prev)
+		}
+		prev = res.Iterations
+
+		// Replay the final iterations to check that the
+		// criterion was met for patience iterations.
+		c.Tolerance = 0
+		c.StopCriterion = ProjectedGradientNorm
+		var objs []float64
+		for i := res.Iterations - patience; i <= res.Iterations; i++ {
+			c.MaxIter = i
+			_, _, r := FactorsResult(V, Wo, Ho, c)
+			objs = append(objs, r.FinalObjective)
+		}
+		for i := 1; i < len(objs); i++ {
+			if rel := (objs[i-1] - objs[i]) / objs[i-1]; rel >= tol {
+				t.Errorf("stopped before criterion met with patience %d: relative decrease %v", patience, rel)
+			}
+		}
+	}
+}
+
+func TestRecordHistory(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	c := testConfig
+	c.MaxIter = 20
+	_, _, res := FactorsResult(V, Wo, Ho, c)
+	if res.History != nil {
+		t.Error("unexpected history when recording is off")
+	}
+
+	c.RecordHistory = true
+	W, H, res := FactorsResult(V, Wo, Ho, c)
+	if len(res.History) != res.Iterations+1 {
+		t.Fatalf("unexpected history length: got:%d want:%d", len(res.History), res.Iterations+1)
+	}
+	if cap(res.History) != c.MaxIter+1 {
+		t.Errorf("unexpected history capacity: got:%d want:%d", cap(res.History), c.MaxIter+1)
+	}
+	for i, s := range res.History {
+		if s.Iter != i {
+			t.Errorf("unexpected iteration at %d: %d", i, s.Iter)
+		}
+		if i != 0 && s.Elapsed < res.History[i-1].Elapsed {
+			t.Errorf("elapsed time decreased at %d", i)
+		}
+	}
+	last := res.History[len(res.History)-1]
+	if last.ProjNorm != res.FinalProjNorm {
+		t.Errorf("unexpected final projected gradient norm: got:%v want:%v", last.ProjNorm, res.FinalProjNorm)
+	}
+	if got, want := last.Objective, frobenius(V, W, H); got != want {
+		t.Errorf("unexpected final objective: got:%v want:%v", got, want)
+	}
+	if first := res.History[0].Objective; first != frobenius(V, Wo, Ho) {
+		t.Errorf("unexpected initial objective: got:%v want:%v", first, frobenius(V, Wo, Ho))
+	}
+}
+
+func TestPerComponentGrad(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	_, k := Wo.Dims()
+	c := testConfig
+	c.MaxIter = 10
+	_, _, res := FactorsResult(V, Wo, Ho, c)
+	if res.PerComponentGrad != nil {
+		t.Error("unexpected per component gradients when recording is off")
+	}
+
+	c.RecordHistory = true
+	for _, test := range []struct {
+		method Method
+		obj    Objective
+	}{
+		{method: ProjectedGradient, obj: Frobenius},
+		{method: MultiplicativeUpdate, obj: Frobenius},
+		{method: HALS, obj: Frobenius},
+		{obj: KullbackLeibler},
+	} {
+		c.Method = test.method
+		c.Objective = test.obj
+		_, _, res := FactorsResult(V, Wo, Ho, c)
+		if len(res.PerComponentGrad) != k {
+			t.Errorf("unexpected number of component gradients for method %d objective %d: got:%d want:%d",
+				test.method, test.obj, len(res.PerComponentGrad), k)
+			continue
+		}
+		var s float64
+		for _, g := range res.PerComponentGrad {
+			s += g * g
+		}
+		if got, want := math.Sqrt(s), res.FinalProjNorm; math.Abs(got-want) > 1e-12*math.Max(1, want) {
+			t.Errorf("component gradients do not match final projected gradient norm for method %d objective %d: got:%v want:%v",
+				test.method, test.obj, got, want)
+		}
+	}
+}
+
+func TestSubproblemStats(t *testing.T) {
+	V, Wo, Ho := lowRank(30, 20, 3, rand.NewSource(1))
+	c := testConfig
+	c.Tolerance = 1e-9
+	c.MaxIter = 10
+	c.MaxOuterSubH = 1
+	c.RecordHistory = true
+	_, _, res := FactorsResult(V, Wo, Ho, c)
+	if res.Iterations == 0 {
+		t.Fatal("no iterations performed")
+	}
+	if !res.WSubOK || res.WSubIter == 0 {
+		t.Errorf("unexpected W sub-problem outcome: iter=%d ok=%t", res.WSubIter, res.WSubOK)
+	}
+	if res.HSubIter != 1 {
+		t.Errorf("unexpected H sub-problem iterations: got:%d want:1", res.HSubIter)
+	}
+	if res.OK != (res.WSubOK && res.HSubOK) {
+		t.Errorf("OK does not match sub-problem outcomes: got:%t W:%t H:%t", res.OK, res.WSubOK, res.HSubOK)
+	}
+	if h := res.History[0]; h.WSubIter != 0 || h.HSubIter != 0 || h.WSubOK || h.HSubOK {
+		t.Errorf("unexpected sub-problem outcome for initial factors: %+v", h)
+	}
+	for _, h := range res.History[1:] {
+		if h.HSubIter != 1 {
+			t.Errorf("unexpected H sub-problem iterations at iteration %d: got:%d want:1", h.Iter, h.HSubIter)
+		}
+	}
+	last := res.History[len(res.History)-1]
+	if last.WSubIter != res.WSubIter || last.WSubOK != res.WSubOK || last.HSubOK != res.HSubOK {
+		t.Errorf("final history entry does not match result: got:%+v", last)
+	}
+
+	c.Method = MultiplicativeUpdate
+	_, _, res = FactorsResult(V, Wo, Ho, c)
+	if res.WSubIter != 0 || res.HSubIter != 0 || res.WSubOK || res.HSubOK {
+		t.Errorf("unexpected sub-problem outcome for multiplicative update: %+v", res)
+	}
+}
+
+func TestSubproblemTimes(t *testing.T) {
+	V, Wo, Ho := lowRank(30, 20, 3, rand.NewSource(1))
+	c := testConfig
+	c.Tolerance = 1e-9
+	c.MaxIter = 5
+	c.RecordHistory = true
+
+	// Each sub-problem reads the clock once
+	// before and once after it is solved.
+	c.clock = &stepClock{step: time.Second}
+	_, _, res := FactorsResult(V, Wo, Ho, c)
+	if h := res.History[0]; h.WSubTime != 0 || h.HSubTime != 0 {
+		t.Errorf("unexpected sub-problem times for initial factors: W:%v H:%v", h.WSubTime, h.HSubTime)
+	}
+	for _, h := range res.History[1:] {
+		if h.WSubTime != time.Second || h.HSubTime != time.Second {
+			t.Errorf("unexpected sub-problem times at iteration %d: W:%v H:%v want:%v", h.Iter, h.WSubTime, h.HSubTime, time.Second)
+		}
+	}
+
+	c.Method = MultiplicativeUpdate
+	_, _, res = FactorsResult(V, Wo, Ho, c)
+	for _, h := range res.History {
+		if h.WSubTime != 0 || h.HSubTime != 0 {
+			t.Errorf("unexpected sub-problem times for multiplicative update at iteration %d: W:%v H:%v", h.Iter, h.WSubTime, h.HSubTime)
+		}
+	}
+}
+
+func TestStopWhenRelResidBelow(t *testing.T) {
+	V, Wo, Ho := lowRank(30, 20, 3, rand.NewSource(1))
+	for _, method := range []Method{ProjectedGradient, MultiplicativeUpdate} {
+		c := Config{
+			Method:      method,
+			Tolerance:   1e-12,
+			MaxIter:     1000,
+			MaxOuterSub: 1000,
+			MaxInnerSub: 20,
+		}
+		_, _, ref := FactorsResult(V, Wo, Ho, c)
+
+		c.StopWhenRelResidBelow = 0.05
+		W, H, res := FactorsResult(V, Wo, Ho, c)
+		if !res.Converged || res.Status != Converged {
+			t.Errorf("unexpected status for method %d: %v", method, res.Status)
+		}
+		if got := RelativeResidual(V, W, H); got >= c.StopWhenRelResidBelow {
+			t.Errorf("relative residual not below threshold for method %d: got:%v", method, got)
+		}
+		if res.Iterations >= ref.Iterations {
+			t.Errorf("factorisation did not stop early for method %d: got:%d iterations want:<%d",
+				method, res.Iterations, ref.Iterations)
+		}
+	}
+}
+
+func TestExactFactorisation(t *testing.T) {
+	W := mat.NewDense(3, 2, []float64{1, 0, 2, 1, 0, 3})
+	H := mat.NewDense(2, 4, []float64{1, 2, 0, 1, 0, 1, 4, 2})
+	var V mat.Dense
+	V.Mul(W, H)
+
+	// A zero tolerance cannot be met by the other
+	// stopping criteria, so only the exact residual
+	// check ends the factorisation before MaxIter.
+	// The projected gradient norm criterion does not
+	// evaluate the objective, so the residual is only
+	// checked when the history is recorded.
+	for _, test := range []struct {
+		method    Method
+		criterion StopCriterion
+		history   bool
+	}{
+		{method: ProjectedGradient, criterion: ProjectedGradientNorm, history: true},
+		{method: MultiplicativeUpdate, criterion: RelativeObjective},
+		{method: HALS, criterion: RelativeObjective},
+	} {
+		c := Config{
+			Method:        test.method,
+			StopCriterion: test.criterion,
+			MaxIter:       100,
+			MaxOuterSub:   1000,
+			MaxInnerSub:   20,
+			RecordHistory: test.history,
+		}
+		_, _, res := FactorsResult(&V, W, H, c)
+		if res.Iterations != 0 || !res.Converged || res.Status != Converged {
+			t.Errorf("unexpected result for exact initial factors with method %d: iterations=%d status=%v",
+				test.method, res.Iterations, res.Status)
+		}
+	}
+
+	// Without the history, the projected gradient norm
+	// criterion does not check the residual, and the
+	// exact factors stall at a zero projected gradient.
+	c := Config{MaxIter: 100, MaxOuterSub: 1000, MaxInnerSub: 20}
+	if _, _, res := FactorsResult(&V, W, H, c); res.Status != Stalled {
+		t.Errorf("unexpected status for exact initial factors without residual check: %v", res.Status)
+	}
+
+	Wo, Ho := InitRandom(3, 4, 2, HalfNormal, rand.NewSource(1))
+	c = Config{
+		Tolerance:   1e-14,
+		MaxIter:     10000,
+		MaxOuterSub: 1000,
+		MaxInnerSub: 20,
+	}
+	Wf, Hf, res := FactorsResult(&V, Wo, Ho, c)
+	if res.Status != Converged || res.Iterations >= c.MaxIter {
+		t.Errorf("unexpected status for exactly factorisable matrix: %v after %d iterations", res.Status, res.Iterations)
+	}
+	if d := RelativeResidual(&V, Wf, Hf); d >= 1e-10 {
+		t.Errorf("unexpected relative residual: %v", d)
+	}
+}
+
+func TestFrozenWColumns(t *testing.T) {
+	V, Wo, Ho := lowRank(30, 20, 4, rand.NewSource(1))
+	frozen := []int{0, 2}
+	for _, accelerate := range []bool{false, true} {
+		c := testConfig
+		c.MaxIter = 50
+		c.Accelerate = accelerate
+		c.FrozenWColumns = frozen
+		W, H, _ := Factors(V, Wo, Ho, c)
+		r, k := W.Dims()
+		for j := 0; j < k; j++ {
+			changed := false
+			for i := 0; i < r; i++ {
+				if math.Float64bits(W.At(i, j)) != math.Float64bits(Wo.At(i, j)) {
+					changed = true
+					break
+				}
+			}
+			isFrozen := j == 0 || j == 2
+			if changed == isFrozen {
+				t.Errorf("unexpected change of W column %d with accelerate=%t: changed=%t frozen=%t",
+					j, accelerate, changed, isFrozen)
+			}
+		}
+		if mat.Equal(H, Ho) {
+			t.Errorf("H not updated with accelerate=%t", accelerate)
+		}
+		if got, initial := frobenius(V, W, H), frobenius(V, Wo, Ho); got >= initial {
+			t.Errorf("objective not reduced with accelerate=%t: got:%v initial:%v", accelerate, got, initial)
+		}
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic for out of range frozen column")
+			}
+		}()
+		c := testConfig
+		c.FrozenWColumns = []int{4}
+		Factors(V, Wo, Ho, c)
+	}()
+}
+
+func TestProjectionFloor(t *testing.T) {
+	V, Wo, Ho := lowRank(30, 20, 3, rand.NewSource(1))
+
+	// A component with a zero column of W and the
+	// corresponding zero row of H has zero gradients,
+	// so it cannot recover under exact non-negativity.
+	const dead = 2
+	for i := 0; i < 30; i++ {
+		Wo.Set(i, dead, 0)
+	}
+	for j := 0; j < 20; j++ {
+		Ho.Set(dead, j, 0)
+	}
+
+	c := testConfig
+	c.Tolerance = 1e-9
+	c.MaxIter = 200
+	W, H, _ := Factors(V, Wo, Ho, c)
+	if n := mat.Norm(W.ColView(dead), 2); n != 0 {
+		t.Errorf("unexpected recovery of dead component without floor: norm=%v", n)
+	}
+	stuck := RelativeResidual(V, W, H)
+
+	c.ProjectionFloor = 1e-6
+	W, H, _ = Factors(V, Wo, Ho, c)
+	for _, m := range []*mat.Dense{W, H} {
+		if min := mat.Min(m); min < c.ProjectionFloor {
+			t.Errorf("entry below projection floor: %v", min)
+		}
+	}
+	if n := mat.Norm(W.ColView(dead), 2); n < 0.1 {
+		t.Errorf("dead component not recovered with floor: norm=%v", n)
+	}
+	if resid := RelativeResidual(V, W, H); resid > stuck/10 {
+		t.Errorf("unexpected relative residual with floor: got:%v without floor:%v", resid, stuck)
+	}
+
+	for _, floor := range []float64{-1, math.Inf(1), math.NaN()} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for projection floor %v", floor)
+				}
+			}()
+			c.ProjectionFloor = floor
+			Factors(V, Wo, Ho, c)
+		}()
+	}
+}
+
+func TestDamping(t *testing.T) {
+	V, _, _ := lowRank(30, 20, 3, rand.NewSource(1))
+	Wo, Ho := InitRandom(30, 20, 3, Uniform, rand.NewSource(2))
+	Wo.Slice(0, 30, 1, 2).(*mat.Dense).Zero()
+	Ho.Slice(1, 2, 0, 20).(*mat.Dense).Zero()
+	c := Config{
+		Method:    MultiplicativeUpdate,
+		Tolerance: 1e-9,
+		MaxIter:   500,
+	}
+
+	W, H, res := FactorsResult(V, Wo, Ho, c)
+	if res.DeadComponents != 1 {
+		t.Errorf("unexpected number of dead components without damping: got:%d want:1", res.DeadComponents)
+	}
+	undamped := RelativeResidual(V, W, H)
+
+	c.Damping = 1e-6
+	W, H, res = FactorsResult(V, Wo, Ho, c)
+	if res.DeadComponents != 0 {
+		t.Errorf("unexpected number of dead components with damping: got:%d want:0", res.DeadComponents)
+	}
+	if damped := RelativeResidual(V, W, H); damped >= undamped {
+		t.Errorf("damping did not reduce residual: got:%v undamped:%v", damped, undamped)
+	}
+
+	for _, d := range []float64{-1, math.NaN(), math.Inf(1)} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for damping %v", d)
+				}
+			}()
+			c.Damping = d
+			Factors(V, Wo, Ho, c)
+		}()
+	}
+}
+
+func TestBoxConstraints(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	for _, method := range []Method{ProjectedGradient, MultiplicativeUpdate, HALS} {
+		c := testConfig
+		c.Method = method
+		W, H, _ := Factors(V, Wo, Ho, c)
+		maxW, maxH := 0.5*mat.Max(W), 0.5*mat.Max(H)
+
+		c.MaxW = maxW
+		c.MaxH = maxH
+		W, H, res := FactorsResult(V, Wo, Ho, c)
+		if err := CheckNonNegative(W); err != nil {
+			t.Errorf("invalid W for method %d: %v", method, err)
+		}
+		if err := CheckNonNegative(H); err != nil {
+			t.Errorf("invalid H for method %d: %v", method, err)
+		}
+		if got := mat.Max(W); got > maxW {
+			t.Errorf("W exceeds bound for method %d: %v > %v", method, got, maxW)
+		}
+		if got := mat.Max(H); got > maxH {
+			t.Errorf("H exceeds bound for method %d: %v > %v", method, got, maxH)
+		}
+		if math.IsNaN(res.FinalProjNorm) {
+			t.Errorf("invalid projected gradient norm for method %d", method)
+		}
+	}
+}
+
+func TestSubToleranceFloor(t *testing.T) {
+	// With a zero tolerance the factorisation of an exactly
+	// factorisable V continues past the point where the
+	// sub-problems make progress, repeatedly reducing the
+	// sub-problem tolerances.
+	V, Wo, Ho := testFactors()
+	c := testConfig
+	c.Tolerance = 0
+	c.MaxIter = 150
+	c.Limit = time.Hour
+	_, _, res := FactorsResult(V, Wo, Ho, c)
+
+	gW, gH := gradients(V, Wo, Ho, penalty{}, penalty{})
+	min := minTolerance(gW, gH)
+	if res.State.tolW < min || res.State.tolH < min {
+		t.Errorf("sub-problem tolerance below floor: tolW=%v tolH=%v min=%v", res.State.tolW, res.State.tolH, min)
+	}
+}
+
+func TestDegenerate(t *testing.T) {
+	c := testConfig
+	c.Validate = true
+
+	W, H, ok := Factors(new(mat.Dense), new(mat.Dense), new(mat.Dense), c)
+	if !ok {
+		t.Error("unexpected failure factorising empty matrix")
+	}
+	if !W.IsEmpty() || !H.IsEmpty() {
+		t.Error("expected empty factors for empty matrix")
+	}
+	_, _, ok, err := FactorsE(new(mat.Dense), new(mat.Dense), new(mat.Dense), c)
+	if !ok || err != nil {
+		t.Errorf("unexpected result factorising empty matrix: ok=%t err=%v", ok, err)
+	}
+
+	V, _, _ := testFactors()
+	_, _, ok, err = FactorsE(V, new(mat.Dense), new(mat.Dense), c)
+	if ok || err != errZeroRank {
+		t.Errorf("unexpected result for zero rank: ok=%t err=%v", ok, err)
+	}
+	c.Validate = false
+	_, _, ok = Factors(V, new(mat.Dense), new(mat.Dense), c)
+	if ok {
+		t.Error("unexpected success for zero rank")
+	}
+}
+
+func TestMaxIterZero(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	for _, method := range []Method{ProjectedGradient, MultiplicativeUpdate, HALS} {
+		c := testConfig
+		c.Method = method
+		c.MaxIter = 0
+		W, H, res := FactorsResult(V, Wo, Ho, c)
+		if res.OK || res.Iterations != 0 {
+			t.Errorf("unexpected result for method %d: ok=%t iterations=%d", method, res.OK, res.Iterations)
+		}
+		if !mat.Equal(W, Wo) || !mat.Equal(H, Ho) {
+			t.Errorf("factors altered with no iterations for method %d", method)
+		}
+	}
+}
+
+func TestZeroLimit(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	c := testConfig
+	c.Limit = 0
+	_, _, res := FactorsResult(V, Wo, Ho, c)
+	if !res.OK {
+		t.Errorf("factorisation with zero limit did not converge: %+v", res)
+	}
+
+	c.Tolerance = 0
+	c.MaxIter = 5
+	_, _, res = FactorsResult(V, Wo, Ho, c)
+	if res.Iterations != c.MaxIter {
+		t.Errorf("unexpected number of iterations with zero limit: got:%d want:%d", res.Iterations, c.MaxIter)
+	}
+
+	c.Limit = -1
+	_, _, res = FactorsResult(V, Wo, Ho, c)
+	if res.Iterations != 0 {
+		t.Errorf("unexpected iterations with negative limit: %d", res.Iterations)
+	}
+}
+
+// stepClock is a clock that advances by step
+// each time it is read.
+type stepClock struct {
+	now  time.Time
+	step time.Duration
+}
+
+func (c *stepClock) Now() time.Time {
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
+
+func TestTimeLimit(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	for _, method := range []Method{ProjectedGradient, MultiplicativeUpdate, HALS} {
+		c := testConfig
+		c.Method = method
+		c.Tolerance = 0
+		c.MaxIter = 1000
+		c.Limit = 10 * time.Second
+		c.clock = &stepClock{step: time.Second}
+
+		// The clock is read once at the start and once
+		// per iteration by the time limit check, so the
+		// limit is exceeded by the check after the tenth
+		// update.
+		_, _, res := FactorsResult(V, Wo, Ho, c)
+		if res.Status != TimeLimitReached {
+			t.Errorf("unexpected status for method %d: got:%v want:%v", method, res.Status, TimeLimitReached)
+		}
+		if res.Iterations != 10 {
+			t.Errorf("unexpected iterations for method %d: got:%d want:10", method, res.Iterations)
+		}
+		if res.Elapsed != 12*time.Second {
+			t.Errorf("unexpected elapsed time for method %d: got:%v want:%v", method, res.Elapsed, 12*time.Second)
+		}
+	}
+}
+
+func TestNonFiniteFactors(t *testing.T) {
+	// Multiplicative updates of a poorly scaled
+	// input overflow, filling W with NaN.
+	V, Wo, Ho := testFactors()
+	V.Scale(1e200, V)
+	c := testConfig
+	c.Method = MultiplicativeUpdate
+	W, H, res := FactorsResult(V, Wo, Ho, c)
+	if isFinite(W) && isFinite(H) {
+		t.Fatal("expected non-finite factors")
+	}
+	if res.OK || res.Converged || res.Status != Failed {
+		t.Errorf("unexpected result for non-finite factors: ok=%t converged=%t status=%v", res.OK, res.Converged, res.Status)
+	}
+	_, _, ok, err := FactorsE(V, Wo, Ho, c)
+	if ok || err != errNonFinite {
+		t.Errorf("unexpected FactorsE result for non-finite factors: ok=%t err=%v", ok, err)
+	}
+}
+
+func TestAccelerate(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	c := testConfig
+	c.MaxIter = 1000
+	c.Accelerate = true
+	W, H, res := FactorsResult(V, Wo, Ho, c)
+	if res.Status != Converged {
+		t.Errorf("accelerated factorisation did not converge: %v", res.Status)
+	}
+	for _, m := range []*mat.Dense{W, H} {
+		if err := CheckNonNegative(m); err != nil {
+			t.Errorf("invalid factor: %v", err)
+		}
+	}
+	if d := reconstructionDelta(V, W, H) / mat.Norm(V, 2); d > 1e-4 {
+		t.Errorf("unexpected relative reconstruction error: %v", d)
+	}
+}
+
+func TestKeepBest(t *testing.T) {
+	V, Wo, Ho := lowRank(20, 15, 5, rand.NewSource(1))
+	for _, method := range []Method{ProjectedGradient, MultiplicativeUpdate, HALS} {
+		c := testConfig
+		c.Method = method
+		c.L1H = 0.5
+		c.MaxIter = 50
+		c.RecordHistory = true
+		c.KeepBest = true
+		W, H, res := FactorsResult(V, Wo, Ho, c)
+
+		min := math.Inf(1)
+		for _, s := range res.History {
+			min = math.Min(min, s.Objective)
+		}
+		if res.FinalObjective > min {
+			t.Errorf("returned objective not the best for method %d: got:%v best:%v", method, res.FinalObjective, min)
+		}
+		pW, pH := c.penalties()
+		obj := frobenius(V, W, H) + pW.value(W) + pH.value(H)
+		if math.Abs(obj-res.FinalObjective) > 1e-12*obj {
+			t.Errorf("objective of returned factors does not match result for method %d: got:%v want:%v", method, obj, res.FinalObjective)
+		}
+	}
+}
+
+func TestZeroThreshold(t *testing.T) {
+	const threshold = 1e-3
+	V, Wo, Ho := lowRank(20, 15, 5, rand.NewSource(1))
+	for _, method := range []Method{ProjectedGradient, MultiplicativeUpdate, HALS} {
+		c := testConfig
+		c.Method = method
+		c.L1H = 0.5
+		W, H, res := FactorsResult(V, Wo, Ho, c)
+		c.ZeroThreshold = threshold
+		Wt, Ht, rest := FactorsResult(V, Wo, Ho, c)
+		if rest.Iterations != res.Iterations {
+			t.Errorf("threshold altered iterations for method %d: got:%d want:%d", method, rest.Iterations, res.Iterations)
+		}
+		for _, f := range []struct {
+			name      string
+			got, want *mat.Dense
+		}{
+			{name: "W", got: Wt, want: W},
+			{name: "H", got: Ht, want: H},
+		} {
+			r, c := f.want.Dims()
+			for i := 0; i < r; i++ {
+				for j := 0; j < c; j++ {
+					got, want := f.got.At(i, j), f.want.At(i, j)
+					if want < threshold {
+						want = 0
+					}
+					if got != want {
+						t.Errorf("unexpected %s entry (%d, %d) for method %d: got:%v want:%v", f.name, i, j, method, got, want)
+					}
+				}
+			}
+		}
+	}
+
+	V, Wo, Ho = testFactors()
+	Wc, Hc := mat.DenseCopyOf(Wo), mat.DenseCopyOf(Ho)
+	c := testConfig
+	c.MaxIter = 0
+	c.ZeroThreshold = math.Inf(1)
+	FactorsResult(V, Wo, Ho, c)
+	if !mat.Equal(Wo, Wc) || !mat.Equal(Ho, Hc) {
+		t.Error("initial solutions altered by threshold")
+	}
+}
+
+func TestSubproblemTrace(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	c := testConfig
+	var (
+		calls     int
+		lastOuter = -1
+	)
+	c.SubproblemTrace = func(outerIter, innerIter int, alpha float64, _ bool) {
+		calls++
+		if alpha <= 0 || math.IsNaN(alpha) {
+			t.Errorf("invalid step size: %v", alpha)
+		}
+		if innerIter < 0 || innerIter >= c.MaxInnerSub {
+			t.Errorf("inner iteration out of range: %d", innerIter)
+		}
+		if innerIter == 0 {
+			lastOuter = outerIter
+		} else if outerIter != lastOuter {
+			t.Errorf("outer iteration changed within line search: got:%d want:%d", outerIter, lastOuter)
+		}
+	}
+	_, _, want := FactorsResult(V, Wo, Ho, testConfig)
+	_, _, got := FactorsResult(V, Wo, Ho, c)
+	if calls == 0 {
+		t.Error("trace not called")
+	}
+	if got.Iterations != want.Iterations || got.FinalObjective != want.FinalObjective {
+		t.Error("trace altered factorisation")
+	}
+}
+
+func TestFactorsContextCancel(t *testing.T) {
+	V, Wo, Ho := lowRank(100, 50, 5, rand.NewSource(1))
+	c := testConfig
+	c.Tolerance = 0
+	c.MaxIter = 1e6
+	c.Limit = 10 * time.Second
+
+	check := func(name string, W, H *mat.Dense, err error) {
+		t.Helper()
+		if err != context.Canceled {
+			t.Errorf("unexpected error for %s: got:%v want:%v", name, err, context.Canceled)
+		}
+		if W == nil || H == nil {
+			t.Fatalf("nil factors for %s", name)
+		}
+		if W == Wo || H == Ho || mat.Equal(W, Wo) {
+			t.Errorf("initial factors returned for %s", name)
+		}
+		if !isFinite(W) || !isFinite(H) {
+			t.Errorf("non-finite factors for %s", name)
+		}
+	}
+
+	// Cancellation between updates is seen by the
+	// main loop before the next update, including
+	// for update rules that do not check ctx.
+	const stop = 5
+	for _, method := range []Method{ProjectedGradient, MultiplicativeUpdate, HALS} {
+		ctx, cancel := context.WithCancel(context.Background())
+		var calls int
+		c.Method = method
+		c.Callback = func(iter int, _ float64, _ time.Duration) bool {
+			calls++
+			if iter == stop {
+				cancel()
+			}
+			return true
+		}
+		W, H, _, err := FactorsContext(ctx, V, Wo, Ho, c)
+		check(fmt.Sprintf("main loop with method %d", method), W, H, err)
+		if calls != stop {
+			t.Errorf("unexpected number of iterations after cancellation with method %d: got:%d want:0", method, calls-stop)
+		}
+		cancel()
+	}
+	c.Method = ProjectedGradient
+
+	// Cancellation within a sub-problem is seen by the
+	// sub-problem before its next outer iteration.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var (
+		iters     int
+		cancelled bool
+		outer     int
+		after     int
+		leaked    bool
+	)
+	c.Callback = func(int, float64, time.Duration) bool {
+		iters++
+		return true
+	}
+	c.SubproblemTrace = func(outerIter, innerIter int, _ float64, _ bool) {
+		if cancelled {
+			after++
+			if outerIter != outer {
+				leaked = true
+			}
+			return
+		}
+		if iters >= 3 && outerIter == 1 && innerIter == 0 {
+			cancel()
+			cancelled, outer = true, outerIter
+		}
+	}
+	W, H, _, err := FactorsContext(ctx, V, Wo, Ho, c)
+	check("sub-problem", W, H, err)
+	if !cancelled {
+		t.Fatal("sub-problem not cancelled")
+	}
+	if leaked || after >= c.MaxInnerSub {
+		t.Errorf("sub-problem continued after cancellation: %d line search steps, new outer iteration=%t", after, leaked)
+	}
+	if iters != 3 {
+		t.Errorf("unexpected number of iterations after cancellation: got:%d want:0", iters-3)
+	}
+}
+
+func TestConvergenceStatus(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, test := range []struct {
+		name  string
+		ctx   context.Context
+		zeroW bool
+		conf  func(*Config)
+		want  ConvergenceStatus
+	}{
+		{
+			name: "converged",
+			conf: func(*Config) {},
+			want: Converged,
+		},
+		{
+			name: "max iter",
+			conf: func(c *Config) { c.MaxIter = 1 },
+			want: MaxIterReached,
+		},
+		{
+			name: "time limit",
+			conf: func(c *Config) { c.Limit = time.Nanosecond },
+			want: TimeLimitReached,
+		},
+		{
+			// Multiplicative updates cannot move
+			// away from a zero W.
+			name:  "stalled",
+			zeroW: true,
+			conf: func(c *Config) {
+				c.Method = MultiplicativeUpdate
+				c.Tolerance = 0
+			},
+			want: Stalled,
+		},
+		{
+			name: "stopped",
+			conf: func(c *Config) {
+				c.Callback = func(int, float64, time.Duration) bool { return false }
+			},
+			want: Stopped,
+		},
+		{
+			name: "cancelled",
+			ctx:  cancelled,
+			conf: func(*Config) {},
+			want: Failed,
+		},
+	} {
+		c := testConfig
+		test.conf(&c)
+		ctx := test.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		W := Wo
+		if test.zeroW {
+			r, k := Wo.Dims()
+			W = mat.NewDense(r, k, nil)
+		}
+		_, _, res, _ := factors(ctx, V, W, Ho, c)
+		if res.Status != test.want {
+			t.Errorf("unexpected status for %s: got:%d want:%d", test.name, res.Status, test.want)
+		}
+		if res.Converged != (test.want == Converged) {
+			t.Errorf("status inconsistent with convergence for %s", test.name)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	c := testConfig
+	c.Tolerance = 0
+	c.MaxIter = 20
+	ch := make(chan Snapshot, 100)
+	c.Snapshot = ch
+	c.SnapshotEvery = 5
+	W, H, res := FactorsResult(V, Wo, Ho, c)
+	close(ch)
+
+	var iters []int
+	var last Snapshot
+	for s := range ch {
+		iters = append(iters, s.Iter)
+		if s.W == W || s.H == H || s.W == Wo || s.H == Ho {
+			t.Errorf("snapshot at iteration %d is not a copy", s.Iter)
+		}
+		last = s
+	}
+	var want []int
+	for i := 0; i <= res.Iterations; i += c.SnapshotEvery {
+		want = append(want, i)
+	}
+	if !reflect.DeepEqual(iters, want) {
+		t.Errorf("unexpected snapshot iterations: got:%v want:%v", iters, want)
+	}
+	if last.Iter == res.Iterations && (!mat.Equal(last.W, W) || !mat.Equal(last.H, H)) {
+		t.Error("final snapshot does not match returned factors")
+	}
+
+	// A full channel must not block the factorisation.
+	full := make(chan Snapshot)
+	c.Snapshot = full
+	c.SnapshotEvery = 1
+	FactorsResult(V, Wo, Ho, c)
+}
+
+func TestRelativeTolerance(t *testing.T) {
+	const rt = 1e-2
+	V, Wo, Ho := lowRank(20, 15, 3, rand.NewSource(1))
+	for _, scale := range []float64{1e-3, 1, 1e3} {
+		var Vs, Ws, Hs mat.Dense
+		Vs.Scale(scale, V)
+		Ws.Scale(math.Sqrt(scale), Wo)
+		Hs.Scale(math.Sqrt(scale), Ho)
+		c := testConfig
+		c.Tolerance = 0
+		c.RelativeTolerance = rt
+		c.MaxIter = 1000
+		W, H, res := FactorsResult(&Vs, &Ws, &Hs, c)
+		if !res.Converged {
+			t.Errorf("factorisation did not converge for scale %v: status=%d", scale, res.Status)
+		}
+		if rel := ReconstructionError(&Vs, W, H, 2) / mat.Norm(&Vs, 2); rel >= rt {
+			t.Errorf("relative error above tolerance for scale %v: %v", scale, rel)
+		}
+		if res.Iterations == 0 {
+			t.Errorf("unexpected zero iterations for scale %v", scale)
+		}
+	}
+}
+
+func TestSubproblemBudgets(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	for _, test := range []struct {
+		outerW, innerW, outerH, innerH int
+		want                           [4]int
+	}{
+		{want: [4]int{1000, 20, 1000, 20}},
+		{outerW: 5, innerH: 3, want: [4]int{5, 20, 1000, 3}},
+		{outerW: 1, innerW: 2, outerH: 3, innerH: 4, want: [4]int{1, 2, 3, 4}},
+	} {
+		c := testConfig
+		c.MaxOuterSubW, c.MaxInnerSubW = test.outerW, test.innerW
+		c.MaxOuterSubH, c.MaxInnerSubH = test.outerH, test.innerH
+		gW, gH := gradients(V, Wo, Ho, penalty{}, penalty{})
+		p := newProjectedGradient(V, Wo, Ho, gW, gH, penalty{}, penalty{}, 1, c)
+		got := [4]int{p.outerW, p.innerW, p.outerH, p.innerH}
+		if got != test.want {
+			t.Errorf("unexpected sub-problem budgets: got:%v want:%v", got, test.want)
+		}
+	}
+
+	// Limiting both sub-problems to a single outer
+	// iteration is seen by the line search trace.
+	c := testConfig
+	c.MaxIter = 5
+	c.MaxOuterSubW, c.MaxOuterSubH = 1, 1
+	c.SubproblemTrace = func(outerIter, _ int, _ float64, _ bool) {
+		if outerIter != 0 {
+			t.Errorf("unexpected outer iteration: %d", outerIter)
+		}
+	}
+	FactorsResult(V, Wo, Ho, c)
+}
+
+func TestStepSizeBounds(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	c := testConfig
+	c.MaxStepSize = 1
+	c.MinStepSize = 1e-3
+	var calls int
+	c.SubproblemTrace = func(_, _ int, alpha float64, _ bool) {
+		calls++
+		if alpha < c.MinStepSize || c.MaxStepSize < alpha {
+			t.Errorf("step size out of bounds: %v", alpha)
+		}
+	}
+	W, H, res := FactorsResult(V, Wo, Ho, c)
+	if calls == 0 {
+		t.Error("trace not called")
+	}
+	for _, m := range []*mat.Dense{W, H} {
+		if err := CheckNonNegative(m); err != nil {
+			t.Errorf("invalid factor: %v", err)
+		}
+	}
+	if res.FinalObjective >= frobenius(V, Wo, Ho) {
+		t.Error("bounded steps made no progress")
+	}
+}
+
+func TestLineSearchParameters(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	c := testConfig
+	c.MaxIter = 5
+	c.InitialStep = 0.5
+	c.StepDecay = 0.5
+	var (
+		starts int
+		last   float64
+	)
+	c.SubproblemTrace = func(outerIter, innerIter int, alpha float64, _ bool) {
+		switch {
+		case outerIter == 0 && innerIter == 0:
+			starts++
+			if alpha != c.InitialStep {
+				t.Errorf("unexpected initial step: got:%v want:%v", alpha, c.InitialStep)
+			}
+		case innerIter != 0:
+			if r := alpha / last; r != c.StepDecay && r != 1/c.StepDecay {
+				t.Errorf("unexpected step ratio: got:%v want:%v or %v", r, c.StepDecay, 1/c.StepDecay)
+			}
+		}
+		last = alpha
+	}
+	FactorsResult(V, Wo, Ho, c)
+	if starts == 0 {
+		t.Error("trace not called")
+	}
+
+	for _, test := range []struct {
+		step, decay float64
+	}{
+		{step: -1},
+		{step: math.Inf(1)},
+		{step: math.NaN()},
+		{decay: -0.1},
+		{decay: 1},
+		{decay: 2},
+		{decay: math.NaN()},
+	} {
+		c := testConfig
+		c.InitialStep = test.step
+		c.StepDecay = test.decay
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for InitialStep=%v StepDecay=%v", test.step, test.decay)
+				}
+			}()
+			Factors(V, Wo, Ho, c)
+		}()
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic from Transform for InitialStep=%v StepDecay=%v", test.step, test.decay)
+				}
+			}()
+			Transform(Wo, V, c)
+		}()
+	}
+}
+
+func TestStagnation(t *testing.T) {
+	// A rank 2 factorisation of a rank 5 matrix
+	// plateaus well before MaxIter.
+	src := rand.NewSource(1)
+	V, _, _ := lowRank(20, 10, 5, src)
+	Wo, Ho := InitRandom(20, 10, 2, Uniform, src)
+	c := testConfig
+	c.Tolerance = 0
+	c.MaxIter = 100
+	c.Limit = 0
+
+	_, _, want := FactorsResult(V, Wo, Ho, c)
+
+	c.StagnationWindow = 5
+	c.StagnationTolerance = 1e-6
+	_, _, got := FactorsResult(V, Wo, Ho, c)
+	if got.Status != Stalled {
+		t.Errorf("unexpected status: got:%v want:%v", got.Status, Stalled)
+	}
+	if got.Converged {
+		t.Error("stagnated factorisation reported as converged")
+	}
+	if got.Iterations < c.StagnationWindow || got.Iterations >= want.Iterations {
+		t.Errorf("unexpected iteration count: got:%d, without detection:%d", got.Iterations, want.Iterations)
+	}
+
+	// Disabled detection leaves the result unchanged.
+	c.StagnationWindow = 0
+	_, _, got = FactorsResult(V, Wo, Ho, c)
+	if got.Iterations != want.Iterations || got.FinalObjective != want.FinalObjective {
+		t.Errorf("unexpected result with detection disabled: got:%d %v want:%d %v",
+			got.Iterations, got.FinalObjective, want.Iterations, want.FinalObjective)
+	}
+}
+
+func TestFactorsInto(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	for _, method := range []Method{ProjectedGradient, MultiplicativeUpdate, HALS} {
+		c := testConfig
+		c.Method = method
+		c.Limit = 0
+		Wwant, Hwant, ok := Factors(V, Wo, Ho, c)
+
+		W, H := mat.DenseCopyOf(Wo), mat.DenseCopyOf(Ho)
+		wd, hd := &W.RawMatrix().Data[0], &H.RawMatrix().Data[0]
+		if got := FactorsInto(W, H, V, c); got != ok {
+			t.Errorf("unexpected ok for method %d: got:%t want:%t", method, got, ok)
+		}
+		if &W.RawMatrix().Data[0] != wd || &H.RawMatrix().Data[0] != hd {
+			t.Errorf("buffers replaced for method %d", method)
+		}
+		if !mat.Equal(W, Wwant) || !mat.Equal(H, Hwant) {
+			t.Errorf("unexpected factors for method %d", method)
+		}
+	}
+}
+
+func TestCallback(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	c := testConfig
+	var calls []int
+	c.Callback = func(iter int, projNorm float64, _ time.Duration) bool {
+		calls = append(calls, iter)
+		if projNorm < 0 {
+			t.Errorf("negative projected gradient norm at iteration %d: %v", iter, projNorm)
+		}
+		return iter < 3
+	}
+	_, _, res := FactorsResult(V, Wo, Ho, c)
+	if res.Converged {
+		t.Error("unexpected convergence after early stop")
+	}
+	if res.Iterations != 3 {
+		t.Errorf("unexpected number of iterations: got:%d want:3", res.Iterations)
+	}
+	want := []int{1, 2, 3}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("unexpected callback iterations: got:%v want:%v", calls, want)
+	}
+}
+
+// lowRank returns a random non-negative rows×cols matrix of the given rank
+// and random initial factors with that rank.
+func lowRank(rows, cols, rank int, src rand.Source) (V, Wo, Ho *mat.Dense) {
+	A, B := InitRandom(rows, cols, rank, Uniform, src)
+	V = new(mat.Dense)
+	V.Mul(A, B)
+	Wo, Ho = InitRandom(rows, cols, rank, Uniform, src)
+	return V, Wo, Ho
+}
+
+// newSteadyState returns a projected gradient update rule for a fixed
+// size problem after enough updates to grow its workspace.
+func newSteadyState() updater {
+	V, Wo, Ho := lowRank(100, 50, 5, rand.NewSource(1))
+	c := Config{Tolerance: 1e-5, MaxOuterSub: 1000, MaxInnerSub: 20}
+	gW, gH := gradients(V, Wo, Ho, penalty{}, penalty{})
+	u := newComponents(newProjectedGradient(V, Wo, Ho, gW, gH, penalty{}, penalty{}, c.Tolerance*gradNorm(gW, gH), c), V, c)
+	for i := 0; i < 5; i++ {
+		u.update(context.Background())
+	}
+	return u
+}
+
+func TestProjectedGradientAllocs(t *testing.T) {
+	u := newSteadyState()
+	ctx := context.Background()
+	allocs := testing.AllocsPerRun(20, func() {
+		u.projNorm()
+		u.update(ctx)
+	})
+	if allocs != 0 {
+		t.Errorf("unexpected allocations per iteration: got:%v want:0", allocs)
+	}
+}
+
+func BenchmarkProjectedGradientUpdate(b *testing.B) {
+	u := newSteadyState()
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		u.projNorm()
+		u.update(ctx)
+	}
+}
+
+func benchmarkMethod(b *testing.B, method Method, accelerate bool) {
+	V, Wo, Ho := lowRank(100, 50, 5, rand.NewSource(1))
+	var sub int
+	c := Config{
+		Method:      method,
+		Tolerance:   1e-3,
+		MaxIter:     5000,
+		MaxOuterSub: 1000,
+		MaxInnerSub: 20,
+		Limit:       time.Minute,
+		Accelerate:  accelerate,
+		SubproblemTrace: func(_, inner int, _ float64, _ bool) {
+			if inner == 0 {
+				sub++
+			}
+		},
+	}
+	var iter int
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _, res := FactorsResult(V, Wo, Ho, c)
+		iter += res.Iterations
+	}
+	b.ReportMetric(float64(iter)/float64(b.N), "iterations/op")
+	if method == ProjectedGradient {
+		b.ReportMetric(float64(sub)/float64(b.N), "subiterations/op")
+	}
+}
+
+func BenchmarkProjectedGradient(b *testing.B)    { benchmarkMethod(b, ProjectedGradient, false) }
+func BenchmarkAccelerated(b *testing.B)          { benchmarkMethod(b, ProjectedGradient, true) }
+func BenchmarkMultiplicativeUpdate(b *testing.B) { benchmarkMethod(b, MultiplicativeUpdate, false) }
+func BenchmarkHALS(b *testing.B)                 { benchmarkMethod(b, HALS, false) }
+
+func TestFactors32(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	c := testConfig
+	c.Tolerance = 1e-4
+
+	W, H, ok := Factors32(general32(V), general32(Wo), general32(Ho), c)
+	if !ok {
+		t.Error("unexpected failure")
+	}
+	for _, v := range append(W.Data, H.Data...) {
+		if v < 0 || math.IsNaN(float64(v)) {
+			t.Fatalf("invalid factor entry: %v", v)
+		}
+	}
+	if d := ReconstructionError(V, dense64(W), dense64(H), 2); d > 0.05 {
+		t.Errorf("unexpected reconstruction error: %v", d)
+	}
+}
+
+func TestFactors32Large(t *testing.T) {
+	// A large, badly scaled matrix has sums of squares
+	// whose single precision rounding error would swamp
+	// the stopping and sufficient decrease tests.
+	const rows, cols, k = 1000, 400, 5
+	V, Wo, Ho := lowRank(rows, cols, k, rand.NewSource(1))
+	applyInPlace(func(i, _ int, v float64) float64 { return v * math.Pow(10, float64(i%3)) }, V)
+	c := testConfig
+	c.Tolerance = 1e-4
+	c.MaxIter = 1000
+	c.Limit = time.Minute
+
+	W, H, ok := Factors32(general32(V), general32(Wo), general32(Ho), c)
+	if !ok {
+		t.Error("unexpected failure")
+	}
+	W64, H64 := dense64(W), dense64(H)
+	got := RelativeResidual(V, W64, H64)
+
+	Wf, Hf, _ := Factors(V, Wo, Ho, c)
+	want := RelativeResidual(V, Wf, Hf)
+	if math.Abs(got-want) > 1e-3 {
+		t.Errorf("single precision result differs from double precision: got:%v want:%v", got, want)
+	}
+}
+
+func TestNorm32(t *testing.T) {
+	// Once a single precision sum reaches 2^24, adding
+	// one is lost to rounding, so the many small squares
+	// would be ignored.
+	const n = 1 << 20
+	g := newGeneral32(1, n+1)
+	g.Data[0] = 1 << 12
+	for i := 1; i <= n; i++ {
+		g.Data[i] = 1
+	}
+	want := math.Sqrt(1<<24 + n)
+	if got := norm32(g); math.Abs(got-want) > 1e-9*want {
+		t.Errorf("unexpected norm: got:%v want:%v", got, want)
+	}
+}
+
+// dense64 returns a double precision copy of m.
+func dense64(m blas32.General) *mat.Dense {
+	d := mat.NewDense(m.Rows, m.Cols, nil)
+	for i := 0; i < m.Rows; i++ {
+		for j := 0; j < m.Cols; j++ {
+			d.Set(i, j, float64(m.Data[i*m.Stride+j]))
+		}
+	}
+	return d
+}
+
+func general32(m *mat.Dense) blas32.General {
+	r, c := m.Dims()
+	g := blas32.General{Rows: r, Cols: c, Stride: c, Data: make([]float32, r*c)}
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			g.Data[i*c+j] = float32(m.At(i, j))
+		}
+	}
+	return g
+}
+
+func BenchmarkFactors(b *testing.B) {
+	V, Wo, Ho := lowRank(200, 50, 10, rand.NewSource(1))
+	c := Config{
+		Tolerance:   1e-6,
+		MaxIter:     20,
+		MaxOuterSub: 1000,
+		MaxInnerSub: 20,
+		Limit:       time.Minute,
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Factors(V, Wo, Ho, c)
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"context"
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// NNLS returns the non-negative matrix X that minimises ||AX - B||_F, solving the
+// non-negative least squares problem by projected gradient from the initial
+// non-negative solution Xo. The solver stops when the norm of the projected
+// gradient, AᵀAX - AᵀB, falls below tol or after outer iterations, performing
+// up to inner line search steps in each iteration. The returned iter is the
+// number of iterations performed and ok is true if the tolerance was met.
+// NNLS is the solver used for the sub-problems of the ProjectedGradient method.
+// The products AᵀA and AᵀB are formed once, so for a k×c solution each iteration
+// costs O(k²c) for the gradient plus the same for each line search step, and the
+// number of iterations grows with the condition number of A.
+// NNLS panics if A is not an r×k matrix, B an r×c matrix and Xo a k×c matrix.
+func NNLS(A, B, Xo *mat.Dense, tol float64, outer, inner int) (X *mat.Dense, iter int, ok bool) {
+	ar, ac := A.Dims()
+	br, bc := B.Dims()
+	xr, xc := Xo.Dims()
+	if ar != br || ac != xr || bc != xc {
+		panic(fmt.Sprintf("nmf: dimension mismatch: A is %d×%d, B is %d×%d and Xo is %d×%d", ar, ac, br, bc, xr, xc))
+	}
+	X, _, iter, _, _ = nnlsSubproblem(context.Background(), B, A, Xo, tol, outer, inner, penalty{}, nil)
+	return X, iter, iter < outer
+}
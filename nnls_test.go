@@ -0,0 +1,207 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestNNLS(t *testing.T) {
+	A := mat.NewDense(4, 2, []float64{
+		1, 0,
+		0, 1,
+		1, 1,
+		2, 1,
+	})
+	for _, test := range []struct {
+		X0   *mat.Dense
+		want *mat.Dense
+	}{
+		// An interior solution is the unconstrained
+		// least squares solution.
+		{X0: mat.NewDense(2, 1, []float64{1, 2}), want: mat.NewDense(2, 1, []float64{1, 2})},
+		// A negative unconstrained solution is clamped
+		// to the boundary.
+		{X0: mat.NewDense(2, 1, []float64{2, -1}), want: nil},
+	} {
+		var B mat.Dense
+		B.Mul(A, test.X0)
+		Xo := mat.NewDense(2, 1, []float64{1, 1})
+		X, iter, ok := NNLS(A, &B, Xo, 1e-10, 1000, 20)
+		if !ok {
+			t.Errorf("failed to converge in %d iterations", iter)
+		}
+		if err := CheckNonNegative(X); err != nil {
+			t.Errorf("invalid X: %v", err)
+		}
+		if test.want != nil {
+			if !mat.EqualApprox(X, test.want, 1e-8) {
+				t.Errorf("unexpected solution: got:%v want:%v", mat.Formatted(X.T()), mat.Formatted(test.want.T()))
+			}
+			continue
+		}
+
+		// Check the KKT conditions: the gradient is zero for
+		// positive elements and non-negative for zero elements.
+		var G, AtA, AtB mat.Dense
+		AtA.Mul(A.T(), A)
+		AtB.Mul(A.T(), &B)
+		G.Mul(&AtA, X)
+		G.Sub(&G, &AtB)
+		for i := 0; i < 2; i++ {
+			g, x := G.At(i, 0), X.At(i, 0)
+			if (x > 0 && g*g > 1e-16) || (x == 0 && g < -1e-8) {
+				t.Errorf("KKT conditions not met at %d: x=%v g=%v", i, x, g)
+			}
+		}
+	}
+}
+
+func TestLineSearchSteps(t *testing.T) {
+	// The number of line search steps is pinned for a small
+	// problem, and must not change when the problem is scaled
+	// by powers of two, which is exact.
+	const (
+		wantSteps = 82
+		wantIter  = 41
+	)
+	W := mat.NewDense(4, 2, []float64{1, 0, 2, 1, 0, 3, 1, 1})
+	H := mat.NewDense(2, 3, []float64{1, 0, 2, 3, 1, 0})
+	for _, scale := range []float64{1, math.Ldexp(1, -20), math.Ldexp(1, 20)} {
+		var V mat.Dense
+		V.Mul(W, H)
+		V.Scale(scale, &V)
+
+		var steps int
+		work := &workspace{trace: func(_, _ int, _ float64, _ bool) { steps++ }}
+		Ho := mat.NewDense(2, 3, nil)
+		_, _, iter, _, _ := nnlsSubproblem(context.Background(), &V, W, Ho, 1e-10*scale, 1000, 20, penalty{}, work)
+		if steps != wantSteps || iter != wantIter {
+			t.Errorf("unexpected line search for scale %v: got %d steps in %d iterations, want %d in %d",
+				scale, steps, iter, wantSteps, wantIter)
+		}
+	}
+}
+
+func TestAcceleratedSubproblem(t *testing.T) {
+	V, W, _ := lowRank(30, 20, 4, rand.NewSource(1))
+	var g mat.Dense
+	g.Mul(W.T(), V)
+	tol := 1e-9 * mat.Norm(&g, 2)
+	Ho := mat.NewDense(4, 20, nil)
+
+	want, _, _, _, _ := nnlsSubproblem(context.Background(), V, W, Ho, tol, 10000, 20, penalty{}, nil)
+	want = mat.DenseCopyOf(want)
+
+	var step float64
+	work := &workspace{
+		accelerate: true,
+		trace: func(_, inner int, alpha float64, sufficient bool) {
+			if step == 0 {
+				step = alpha
+			}
+			if inner != 0 || alpha != step || !sufficient {
+				t.Errorf("unexpected accelerated step: inner=%d alpha=%v sufficient=%t", inner, alpha, sufficient)
+			}
+		},
+	}
+	got, _, iter, ok, _ := nnlsSubproblem(context.Background(), V, W, Ho, tol, 10000, 20, penalty{}, work)
+	if !ok || iter == 10000 {
+		t.Errorf("accelerated sub-problem did not converge: ok=%t iter=%d", ok, iter)
+	}
+	if err := CheckNonNegative(got); err != nil {
+		t.Errorf("invalid solution: %v", err)
+	}
+	if !mat.EqualApprox(got, want, 1e-6) {
+		t.Errorf("unexpected solution:\ngot: %v\nwant:%v", mat.Formatted(got), mat.Formatted(want))
+	}
+}
+
+// conditioned returns a random r×k matrix with orthonormal singular
+// vectors and singular values spaced geometrically from one to 1/cond.
+func conditioned(r, k int, cond float64, rnd *rand.Rand) *mat.Dense {
+	random := func(r, c int) *mat.Dense {
+		m := mat.NewDense(r, c, nil)
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				m.Set(i, j, rnd.NormFloat64())
+			}
+		}
+		return m
+	}
+	var qr mat.QR
+	var U, V mat.Dense
+	qr.Factorize(random(r, k))
+	qr.QTo(&U)
+	qr.Factorize(random(k, k))
+	qr.QTo(&V)
+
+	A := mat.NewDense(r, k, nil)
+	for j := 0; j < k; j++ {
+		s := math.Pow(cond, -float64(j)/math.Max(float64(k-1), 1))
+		for i := 0; i < r; i++ {
+			A.Set(i, j, s*U.At(i, j))
+		}
+	}
+	A.Mul(mat.DenseCopyOf(A), V.T())
+	return A
+}
+
+func BenchmarkNNLS(b *testing.B) {
+	for _, size := range []struct{ r, k, c int }{
+		{r: 50, k: 5, c: 20},
+		{r: 200, k: 20, c: 100},
+	} {
+		for _, cond := range []float64{1, 1e1, 1e2} {
+			rnd := rand.New(rand.NewSource(1))
+			A := conditioned(size.r, size.k, cond, rnd)
+
+			// A target formed from a solution with entries of
+			// either sign has active constraints at the
+			// non-negative solution.
+			X := mat.NewDense(size.k, size.c, nil)
+			for i := 0; i < size.k; i++ {
+				for j := 0; j < size.c; j++ {
+					X.Set(i, j, rnd.NormFloat64())
+				}
+			}
+			var B mat.Dense
+			B.Mul(A, X)
+			Xo := mat.NewDense(size.k, size.c, nil)
+			for i := 0; i < size.k; i++ {
+				for j := 0; j < size.c; j++ {
+					Xo.Set(i, j, 1)
+				}
+			}
+
+			// Stop at a fixed fraction of the initial projected
+			// gradient norm so that the iterations to tolerance
+			// are comparable across problems.
+			var AtA, G mat.Dense
+			AtA.Mul(A.T(), A)
+			G.Mul(&AtA, Xo)
+			var AtB mat.Dense
+			AtB.Mul(A.T(), &B)
+			G.Sub(&G, &AtB)
+			projectGradient(&G, Xo, 0)
+			tol := 1e-6 * mat.Norm(&G, 2)
+
+			b.Run(fmt.Sprintf("%dx%dx%d/cond=%g", size.r, size.k, size.c, cond), func(b *testing.B) {
+				var iter int
+				for i := 0; i < b.N; i++ {
+					_, n, _ := NNLS(A, &B, Xo, tol, 10000, 20)
+					iter += n
+				}
+				b.ReportMetric(float64(iter)/float64(b.N), "iterations/op")
+			})
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// NormMode specifies how factors are rescaled by Normalize.
+type NormMode int
+
+const (
+	// NormalizeColumnsW rescales each column of W to have
+	// unit Euclidean norm.
+	NormalizeColumnsW NormMode = iota
+
+	// NormalizeRowsH rescales each row of H to sum to one.
+	NormalizeRowsH
+
+	// NormalizeEqual rescales each column of W and the
+	// corresponding row of H to have equal Euclidean norms.
+	NormalizeEqual
+)
+
+// Normalize rescales the columns of W and the rows of H in place according to
+// mode, resolving the scaling ambiguity of the factorisation. For each component
+// k, column k of W is multiplied by a scale and row k of H is divided by it, so
+// the product WH is unaltered up to rounding. Components with a zero column of W
+// or a zero row of H are left unaltered. Normalize panics if the number of
+// columns of W does not equal the number of rows of H.
+func Normalize(W, H *mat.Dense, mode NormMode) {
+	wr, wc := W.Dims()
+	hr, _ := H.Dims()
+	if wc != hr {
+		panic("nmf: dimension mismatch between W and H")
+	}
+
+	col := make([]float64, wr)
+	for k := 0; k < wc; k++ {
+		mat.Col(col, k, W)
+		row := H.RawRowView(k)
+		wNorm := floats.Norm(col, 2)
+		hNorm := floats.Norm(row, 2)
+
+		var scale float64
+		switch mode {
+		case NormalizeColumnsW:
+			scale = 1 / wNorm
+		case NormalizeRowsH:
+			scale = floats.Sum(row)
+		case NormalizeEqual:
+			scale = math.Sqrt(hNorm / wNorm)
+		default:
+			panic("nmf: unknown normalisation mode")
+		}
+		if wNorm == 0 || hNorm == 0 {
+			continue
+		}
+
+		for i, v := range col {
+			W.Set(i, k, v*scale)
+		}
+		floats.Scale(1/scale, row)
+	}
+}
+
+// SortComponents reorders the columns of W and the corresponding rows of H in
+// place in descending order of the contribution of each component to the
+// reconstruction, ||w_k h_k||_F, where w_k is column k of W and h_k is row k
+// of H. The product WH is unaltered. The returned order holds the original
+// index of each component in its new position. SortComponents panics if the
+// number of columns of W does not equal the number of rows of H.
+func SortComponents(W, H *mat.Dense) (order []int) {
+	wr, wc := W.Dims()
+	hr, _ := H.Dims()
+	if wc != hr {
+		panic("nmf: dimension mismatch between W and H")
+	}
+
+	contrib := make([]float64, wc)
+	order = make([]int, wc)
+	col := make([]float64, wr)
+	for k := range order {
+		order[k] = k
+		mat.Col(col, k, W)
+		contrib[k] = floats.Norm(col, 2) * floats.Norm(H.RawRowView(k), 2)
+	}
+	sort.SliceStable(order, func(i, j int) bool { return contrib[order[i]] > contrib[order[j]] })
+	permuteComponents(W, H, order)
+	return order
+}
+
+// permuteComponents reorders the columns of W and the corresponding
+// rows of H in place so that component k is the original component
+// order[k].
+func permuteComponents(W, H *mat.Dense, order []int) {
+	wr, _ := W.Dims()
+	_, hc := H.Dims()
+	Wo := mat.DenseCopyOf(W)
+	Ho := mat.DenseCopyOf(H)
+	for k, o := range order {
+		W.Slice(0, wr, k, k+1).(*mat.Dense).Copy(Wo.Slice(0, wr, o, o+1))
+		H.Slice(k, k+1, 0, hc).(*mat.Dense).Copy(Ho.Slice(o, o+1, 0, hc))
+	}
+}
+
+// canonicalize puts the factors W and H in place into the canonical form
+// described for Config.Canonicalize.
+func canonicalize(W, H *mat.Dense) {
+	wr, wc := W.Dims()
+	_, hc := H.Dims()
+	for _, k := range deadComponents(W, H) {
+		W.Slice(0, wr, k, k+1).(*mat.Dense).Zero()
+		H.Slice(k, k+1, 0, hc).(*mat.Dense).Zero()
+	}
+	Normalize(W, H, NormalizeColumnsW)
+
+	first := make([]int, wc)
+	norm := make([]float64, wc)
+	order := make([]int, wc)
+	for k := range order {
+		order[k] = k
+		first[k] = -1
+		for i := 0; i < wr; i++ {
+			if W.At(i, k) != 0 {
+				first[k] = i
+				break
+			}
+		}
+		norm[k] = floats.Norm(H.RawRowView(k), 2)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if first[a] != first[b] {
+			return first[a] > first[b]
+		}
+		return norm[a] > norm[b]
+	})
+	permuteComponents(W, H, order)
+}
+
+// columnScale returns the norms of the columns of V specified by
+// the preprocessing p, ignoring NaN entries. Zero norms are
+// returned as one.
+func columnScale(V *mat.Dense, p Preprocess) []float64 {
+	r, c := V.Dims()
+	scale := make([]float64, c)
+	for j := range scale {
+		var s float64
+		for i := 0; i < r; i++ {
+			v := V.At(i, j)
+			if math.IsNaN(v) {
+				continue
+			}
+			switch p {
+			case L2ColumnNormalize:
+				s += v * v
+			case L1ColumnNormalize:
+				s += math.Abs(v)
+			default:
+				panic("nmf: unknown preprocessing")
+			}
+		}
+		if p == L2ColumnNormalize {
+			s = math.Sqrt(s)
+		}
+		if s == 0 {
+			s = 1
+		}
+		scale[j] = s
+	}
+	return scale
+}
@@ -0,0 +1,202 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, mode := range []NormMode{NormalizeColumnsW, NormalizeRowsH, NormalizeEqual} {
+		W, H := InitRandom(6, 5, 3, Uniform, rand.NewSource(1))
+		// Include an empty component.
+		for i := 0; i < 6; i++ {
+			W.Set(i, 2, 0)
+		}
+		var want mat.Dense
+		want.Mul(W, H)
+		wantH2 := mat.Row(nil, 2, H)
+
+		Normalize(W, H, mode)
+
+		var got mat.Dense
+		got.Mul(W, H)
+		if !mat.EqualApprox(&got, &want, 1e-12) {
+			t.Errorf("product changed by normalisation mode %d", mode)
+		}
+		if !floats.Equal(mat.Row(nil, 2, H), wantH2) {
+			t.Errorf("empty component altered by normalisation mode %d", mode)
+		}
+		for k := 0; k < 2; k++ {
+			w := floats.Norm(mat.Col(nil, k, W), 2)
+			h := mat.Row(nil, k, H)
+			var ok bool
+			switch mode {
+			case NormalizeColumnsW:
+				ok = math.Abs(w-1) < 1e-12
+			case NormalizeRowsH:
+				ok = math.Abs(floats.Sum(h)-1) < 1e-12
+			case NormalizeEqual:
+				ok = math.Abs(w-floats.Norm(h, 2)) < 1e-12
+			}
+			if !ok {
+				t.Errorf("component %d not normalised by mode %d", k, mode)
+			}
+		}
+	}
+}
+
+func TestSortComponents(t *testing.T) {
+	W, H := InitRandom(6, 5, 4, Uniform, rand.NewSource(1))
+	// Give the components known contributions.
+	for k, s := range []float64{1, 8, 2, 4} {
+		for i := 0; i < 6; i++ {
+			W.Set(i, k, W.At(i, k)*s)
+		}
+	}
+	var want mat.Dense
+	want.Mul(W, H)
+
+	order := SortComponents(W, H)
+	if !reflect.DeepEqual(order, []int{1, 3, 2, 0}) {
+		t.Errorf("unexpected order: got:%v want:[1 3 2 0]", order)
+	}
+	var got mat.Dense
+	got.Mul(W, H)
+	if !mat.EqualApprox(&got, &want, 1e-12) {
+		t.Error("product changed by sorting")
+	}
+	prev := math.Inf(1)
+	for k := 0; k < 4; k++ {
+		c := floats.Norm(mat.Col(nil, k, W), 2) * floats.Norm(mat.Row(nil, k, H), 2)
+		if c > prev {
+			t.Errorf("components not in descending order at %d", k)
+		}
+		prev = c
+	}
+}
+
+func TestPreprocess(t *testing.T) {
+	V, Wo, Ho := lowRank(12, 10, 3, rand.NewSource(1))
+	// Give the columns of V widely differing scales.
+	for j := 0; j < 10; j++ {
+		col := V.Slice(0, 12, j, j+1).(*mat.Dense)
+		col.Scale(math.Pow(10, float64(j%4)), col)
+	}
+	// A zero column must not be scaled.
+	V.Slice(0, 12, 5, 6).(*mat.Dense).Zero()
+
+	for _, p := range []Preprocess{L2ColumnNormalize, L1ColumnNormalize} {
+		c := testConfig
+		c.Tolerance = 1e-8
+		c.MaxIter = 1000
+		c.Preprocess = p
+		W, H, res := FactorsResult(V, Wo, Ho, c)
+		if !res.Converged {
+			t.Errorf("factorisation did not converge for preprocessing %d: %+v", p, res.Status)
+		}
+		if len(res.ColumnScale) != 10 || res.ColumnScale[5] != 1 {
+			t.Errorf("unexpected column scale for preprocessing %d: %v", p, res.ColumnScale)
+		}
+		var P mat.Dense
+		P.Mul(W, H)
+		if !mat.EqualApprox(&P, V, 1e-3*mat.Max(V)) {
+			t.Errorf("product does not reconstruct V for preprocessing %d:\ngot:\n%.4v\nwant:\n%.4v",
+				p, mat.Formatted(&P), mat.Formatted(V))
+		}
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	W, H := InitRandom(6, 5, 4, Uniform, rand.NewSource(1))
+	// Zero a component that must be placed last,
+	// and give two components the same first row.
+	for i := 0; i < 6; i++ {
+		W.Set(i, 1, 0)
+	}
+	W.Set(0, 2, 0)
+	W.Set(0, 3, 0)
+	W.Set(1, 3, 0)
+	var want mat.Dense
+	want.Mul(W, H)
+
+	// Permute and rescale the components.
+	Wp := mat.DenseCopyOf(W)
+	Hp := mat.DenseCopyOf(H)
+	permuteComponents(Wp, Hp, []int{3, 0, 1, 2})
+	for k, s := range []float64{2, 0.5, 3, 10} {
+		for i := 0; i < 6; i++ {
+			Wp.Set(i, k, Wp.At(i, k)*s)
+		}
+		for j := 0; j < 5; j++ {
+			Hp.Set(k, j, Hp.At(k, j)/s)
+		}
+	}
+
+	canonicalize(W, H)
+	canonicalize(Wp, Hp)
+	if !mat.EqualApprox(W, Wp, 1e-14) || !mat.EqualApprox(H, Hp, 1e-14) {
+		t.Errorf("canonical forms differ:\nW =\n%.6v\nWp =\n%.6v", mat.Formatted(W), mat.Formatted(Wp))
+	}
+	var got mat.Dense
+	got.Mul(W, H)
+	if !mat.EqualApprox(&got, &want, 1e-12) {
+		t.Error("product changed by canonicalisation")
+	}
+
+	// Components 3 and 2 have their first non-zero
+	// rows at 2 and 1, and components 0 and 1 are
+	// last by first row and their zero column.
+	first := []int{2, 1, 0, -1}
+	for k, f := range first {
+		col := mat.Col(nil, k, W)
+		got := -1
+		for i, v := range col {
+			if v != 0 {
+				got = i
+				break
+			}
+		}
+		if got != f {
+			t.Errorf("unexpected first non-zero row of component %d: got:%d want:%d", k, got, f)
+		}
+		if n := floats.Norm(col, 2); f >= 0 && math.Abs(n-1) > 1e-14 {
+			t.Errorf("component %d not normalised: %v", k, n)
+		}
+	}
+
+	// Factorisations from permuted initial solutions
+	// have the same canonical form.
+	V, Wo, Ho := testFactors()
+	c := testConfig
+	c.Limit = 0
+	c.Canonicalize = true
+	W, H, _ = Factors(V, Wo, Ho, c)
+	_, k := Wo.Dims()
+	order := make([]int, k)
+	for i := range order {
+		order[i] = k - 1 - i
+	}
+	Wp, Hp = mat.DenseCopyOf(Wo), mat.DenseCopyOf(Ho)
+	permuteComponents(Wp, Hp, order)
+	Wp, Hp, _ = Factors(V, Wp, Hp, c)
+	if !mat.EqualApprox(W, Wp, 1e-10) || !mat.EqualApprox(H, Hp, 1e-10) {
+		t.Errorf("canonical factors depend on component order:\nW =\n%.6v\nWp =\n%.6v", mat.Formatted(W), mat.Formatted(Wp))
+	}
+
+	// The initial solutions are not altered.
+	c.MaxIter = 0
+	Wc, Hc := mat.DenseCopyOf(Wo), mat.DenseCopyOf(Ho)
+	Factors(V, Wo, Ho, c)
+	if !mat.Equal(Wo, Wc) || !mat.Equal(Ho, Hc) {
+		t.Error("initial solutions altered by canonicalisation")
+	}
+}
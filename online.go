@@ -0,0 +1,157 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// OnlineFactorizer learns a non-negative basis W from a stream of batches of
+// columns of V, without holding V in memory. It uses the online dictionary
+// learning method of Mairal et al., accumulating the sufficient statistics
+//
+//	A = sum HHᵀ and B = sum VHᵀ
+//
+// over the batches seen and updating W by block coordinate descent on the
+// surrogate objective defined by A and B. The columns of W are constrained
+// to lie within the unit ball.
+//
+// Mairal, Bach, Ponce and Sapiro (2010) 'Online learning for matrix
+// factorization and sparse coding.' J. Mach. Learn. Res. 11:19.
+type OnlineFactorizer struct {
+	c Config
+
+	W *mat.Dense
+
+	// A and B are the accumulated
+	// sufficient statistics.
+	A, B *mat.Dense
+
+	// n is the number of columns seen.
+	n int
+
+	col, wa mat.VecDense
+}
+
+// NewOnlineFactorizer returns an OnlineFactorizer for rank k factorisations
+// of matrices with the given number of rows. The batch encodings are found
+// with Transform using c. The update of W performs up to c.MaxIter passes of
+// block coordinate descent, stopping when the largest change in an element
+// of W is less than c.Tolerance times the largest element. Penalties and
+// bounds on W are ignored.
+// NewOnlineFactorizer panics if rows or k is not positive.
+func NewOnlineFactorizer(rows, k int, c Config) *OnlineFactorizer {
+	if rows <= 0 || k <= 0 {
+		panic(fmt.Sprintf("nmf: invalid online dimensions: rows=%d k=%d", rows, k))
+	}
+	return &OnlineFactorizer{
+		c: c,
+		W: mat.NewDense(rows, k, nil),
+		A: mat.NewDense(k, k, nil),
+		B: mat.NewDense(rows, k, nil),
+	}
+}
+
+// PartialFit updates the basis with the columns of batch. The basis is
+// initialised from the columns of the first batch, which should have at
+// least k columns for best results.
+// PartialFit panics if batch does not have the number of rows given to
+// NewOnlineFactorizer or if batch has no columns.
+func (o *OnlineFactorizer) PartialFit(batch *mat.Dense) {
+	rows, k := o.W.Dims()
+	br, bc := batch.Dims()
+	if br != rows {
+		panic(fmt.Sprintf("nmf: batch rows (%d) must equal basis rows (%d)", br, rows))
+	}
+	if bc == 0 {
+		panic("nmf: empty batch")
+	}
+	if o.n == 0 {
+		o.initialise(batch)
+	}
+	o.n += bc
+
+	H, _ := Transform(o.W, batch, o.c)
+	var tmp mat.Dense
+	tmp.Mul(H, H.T())
+	o.A.Add(o.A, &tmp)
+	tmp.Reset()
+	tmp.Mul(batch, H.T())
+	o.B.Add(o.B, &tmp)
+
+	o.col.Reset()
+	o.col.ReuseAsVec(rows)
+	o.wa.Reset()
+	o.wa.ReuseAsVec(rows)
+	for pass := 0; pass < o.c.MaxIter; pass++ {
+		var change float64
+		for j := 0; j < k; j++ {
+			ajj := o.A.At(j, j)
+			if ajj == 0 {
+				// The component is unused
+				// by the batches seen.
+				continue
+			}
+			// w_j = max(0, w_j + (b_j - Wa_j) / A_jj)
+			o.wa.MulVec(o.W, o.A.ColView(j))
+			var norm float64
+			for i := 0; i < rows; i++ {
+				v := math.Max(0, o.W.At(i, j)+(o.B.At(i, j)-o.wa.AtVec(i))/ajj)
+				o.col.SetVec(i, v)
+				norm += v * v
+			}
+			// Project onto the unit ball.
+			norm = math.Max(1, math.Sqrt(norm))
+			for i := 0; i < rows; i++ {
+				v := o.col.AtVec(i) / norm
+				change = math.Max(change, math.Abs(v-o.W.At(i, j)))
+				o.W.Set(i, j, v)
+			}
+		}
+		if change < o.c.Tolerance*mat.Max(o.W) {
+			break
+		}
+	}
+}
+
+// initialise sets the basis from the columns of batch. Column j of
+// the basis is column j mod c of the c-column batch, offset by the
+// mean of the batch in rows congruent to j modulo k to distinguish
+// repeated columns, and normalised to unit length.
+func (o *OnlineFactorizer) initialise(batch *mat.Dense) {
+	rows, k := o.W.Dims()
+	_, bc := batch.Dims()
+	mean := mat.Sum(batch) / float64(rows*bc)
+	if mean == 0 {
+		mean = 1
+	}
+	for j := 0; j < k; j++ {
+		var norm float64
+		for i := 0; i < rows; i++ {
+			v := batch.At(i, j%bc)
+			if i%k == j {
+				v += mean
+			}
+			o.W.Set(i, j, v)
+			norm += v * v
+		}
+		w := o.W.Slice(0, rows, j, j+1).(*mat.Dense)
+		if norm == 0 {
+			for i := 0; i < rows; i++ {
+				w.Set(i, 0, 1)
+			}
+			norm = float64(rows)
+		}
+		w.Scale(1/math.Sqrt(norm), w)
+	}
+}
+
+// Basis returns a copy of the current basis W.
+func (o *OnlineFactorizer) Basis() *mat.Dense {
+	return mat.DenseCopyOf(o.W)
+}
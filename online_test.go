@@ -0,0 +1,75 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"gonum.org/v1/gonum/blas/blas64"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestOnlineFactorizer(t *testing.T) {
+	const (
+		rows  = 30
+		cols  = 400
+		k     = 4
+		batch = 40
+	)
+	V, _, _ := lowRank(rows, cols, k, rand.NewSource(1))
+
+	c := testConfig
+	c.Tolerance = 1e-6
+	o := NewOnlineFactorizer(rows, k, c)
+	for epoch := 0; epoch < 5; epoch++ {
+		for j := 0; j < cols; j += batch {
+			o.PartialFit(V.Slice(0, rows, j, j+batch).(*mat.Dense))
+		}
+	}
+
+	W := o.Basis()
+	if err := CheckNonNegative(W); err != nil {
+		t.Fatalf("invalid basis: %v", err)
+	}
+	for j := 0; j < k; j++ {
+		if n := mat.Norm(W.ColView(j), 2); n > 1+1e-12 {
+			t.Errorf("basis column %d outside unit ball: norm=%v", j, n)
+		}
+	}
+
+	c.Tolerance = 1e-10
+	c.MaxOuterSub = 10000
+	H, _ := Transform(W, V, c)
+	var res mat.Dense
+	res.Mul(W, H)
+	res.Sub(V, &res)
+	if rel := mat.Norm(&res, 2) / mat.Norm(V, 2); rel > 2e-2 {
+		t.Errorf("unexpected relative reconstruction error: %v", rel)
+	}
+}
+
+func TestOnlineFactorizerPanics(t *testing.T) {
+	var empty mat.Dense
+	empty.SetRawMatrix(blas64.General{Rows: 3, Cols: 0, Stride: 1})
+	for _, test := range []struct {
+		name  string
+		batch *mat.Dense
+	}{
+		{name: "row mismatch", batch: mat.NewDense(2, 4, nil)},
+		{name: "empty batch", batch: &empty},
+	} {
+		func() {
+			defer func() {
+				r := recover()
+				if msg, ok := r.(string); !ok || !strings.HasPrefix(msg, "nmf: ") {
+					t.Errorf("unexpected panic for %s: %v", test.name, r)
+				}
+			}()
+			NewOnlineFactorizer(3, 2, testConfig).PartialFit(test.batch)
+		}()
+	}
+}
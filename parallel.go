@@ -0,0 +1,191 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"sync"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// mul sets dst to the product ab. If either a or b is sparse, the product
+// is computed by iterating over the non-zero entries of the sparse operand.
+// Otherwise, if n is greater than one and b is a *mat.Dense, the columns
+// of the product are partitioned across up to n goroutines, each writing
+// to a disjoint column block of dst. Since the sum for each element of the
+// product is computed in the same order as for the serial product, the
+// result is identical to dst.Mul(a, b).
+func mul(dst *mat.Dense, a mat.Matrix, b mat.Matrix, n int) {
+	switch {
+	case isSparse(b):
+		mulSparseRight(dst, a, b)
+		return
+	case isSparse(a):
+		mulSparseLeft(dst, a, b)
+		return
+	}
+
+	bd, isDense := b.(*mat.Dense)
+	r, _ := a.Dims()
+	_, c := b.Dims()
+	if n > c {
+		n = c
+	}
+	if n < 2 || !isDense {
+		dst.Mul(a, b)
+		return
+	}
+
+	reuseAs(dst, r, c)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		j0 := i * c / n
+		j1 := (i + 1) * c / n
+		wg.Add(1)
+		go func(j0, j1 int) {
+			defer wg.Done()
+			d := dst.Slice(0, r, j0, j1).(*mat.Dense)
+			d.Mul(a, bd.Slice(0, bd.RawMatrix().Rows, j0, j1))
+		}(j0, j1)
+	}
+	wg.Wait()
+}
+
+// reuseAs sets m to be an r×c matrix, reusing its backing data if it
+// has sufficient capacity. The values of the elements of m are undefined
+// after the call. reuseAs panics if m is non-empty and not r×c.
+func reuseAs(m *mat.Dense, r, c int) {
+	if m.IsEmpty() {
+		m.ReuseAs(r, c)
+		return
+	}
+	if mr, mc := m.Dims(); mr != r || mc != c {
+		panic(mat.ErrShape)
+	}
+}
+
+// apply replaces each element of m with the result of fn. If n is
+// greater than one, the rows of m are partitioned across up to n
+// goroutines, each applying fn to a disjoint block of the backing
+// data of m. fn must be safe for concurrent use and must not depend
+// on the order in which elements are visited, so the result is
+// identical to applyInPlace(fn, m).
+func apply(fn func(r, c int, v float64) float64, m *mat.Dense, n int) {
+	raw := m.RawMatrix()
+	if n > raw.Rows {
+		n = raw.Rows
+	}
+	if n < 2 {
+		applyInPlace(fn, m)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for k := 0; k < n; k++ {
+		i0 := k * raw.Rows / n
+		i1 := (k + 1) * raw.Rows / n
+		wg.Add(1)
+		go func(i0, i1 int) {
+			defer wg.Done()
+			for i := i0; i < i1; i++ {
+				row := raw.Data[i*raw.Stride : i*raw.Stride+raw.Cols]
+				for j, v := range row {
+					row[j] = fn(i, j, v)
+				}
+			}
+		}(i0, i1)
+	}
+	wg.Wait()
+}
+
+// parallelProjectGradient is the equivalent of projectBoundedGradient(g, m, min, max)
+// with the rows of g and m partitioned across up to n goroutines. The
+// result is identical to the serial projection.
+func parallelProjectGradient(g, m *mat.Dense, min, max float64, n int) {
+	r, c := g.Dims()
+	if n > r {
+		n = r
+	}
+	if n < 2 {
+		projectBoundedGradient(g, m, min, max)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for k := 0; k < n; k++ {
+		i0 := k * r / n
+		i1 := (k + 1) * r / n
+		wg.Add(1)
+		go func(i0, i1 int) {
+			defer wg.Done()
+			projectBoundedGradient(g.Slice(i0, i1, 0, c).(*mat.Dense), m.Slice(i0, i1, 0, c).(*mat.Dense), min, max)
+		}(i0, i1)
+	}
+	wg.Wait()
+}
+
+// exceedsBudget returns whether the backing data of a copy of
+// m would exceed the working memory budget in bytes. A budget
+// of zero or less specifies no budget.
+func exceedsBudget(m mat.Matrix, budget int64) bool {
+	if budget <= 0 {
+		return false
+	}
+	r, c := m.Dims()
+	return 8*int64(r)*int64(c) > budget
+}
+
+// blockedMul sets dst to the product ab as for mul with up to n
+// goroutines. If budget is positive, the columns of b and dst are
+// processed in blocks sized so that the block of b and the block of
+// dst together occupy at most budget bytes, with a minimum block
+// width of one column. Blocks are only formed when b is a *mat.Dense
+// or the transpose of a *mat.Dense; otherwise the product is computed
+// as for mul.
+func blockedMul(dst *mat.Dense, a, b mat.Matrix, n int, budget int64) {
+	r, _ := a.Dims()
+	br, c := b.Dims()
+	var width int
+	if budget > 0 {
+		width = int(budget / (8 * int64(r+br)))
+		if width < 1 {
+			width = 1
+		}
+	}
+	if width == 0 || width >= c {
+		mul(dst, a, b, n)
+		return
+	}
+	if _, ok := columnBlock(b, 0, c); !ok {
+		mul(dst, a, b, n)
+		return
+	}
+
+	reuseAs(dst, r, c)
+	for j0 := 0; j0 < c; j0 += width {
+		j1 := j0 + width
+		if j1 > c {
+			j1 = c
+		}
+		bb, _ := columnBlock(b, j0, j1)
+		mul(dst.Slice(0, r, j0, j1).(*mat.Dense), a, bb, n)
+	}
+}
+
+// columnBlock returns a view of columns [j0, j1) of m and true if m
+// is a *mat.Dense or the transpose of a *mat.Dense, and false otherwise.
+func columnBlock(m mat.Matrix, j0, j1 int) (mat.Matrix, bool) {
+	switch m := m.(type) {
+	case *mat.Dense:
+		r, _ := m.Dims()
+		return m.Slice(0, r, j0, j1), true
+	case mat.Transpose:
+		if d, ok := m.Matrix.(*mat.Dense); ok {
+			_, c := d.Dims()
+			return d.Slice(j0, j1, 0, c).T(), true
+		}
+	}
+	return nil, false
+}
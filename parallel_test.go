@@ -0,0 +1,217 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestMul(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	random := func(r, c int) *mat.Dense {
+		m := mat.NewDense(r, c, nil)
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				m.Set(i, j, rnd.Float64())
+			}
+		}
+		return m
+	}
+	for _, test := range []struct {
+		a mat.Matrix
+		b *mat.Dense
+	}{
+		{a: random(3, 4), b: random(4, 5)},
+		{a: random(70, 130).T(), b: random(70, 150)},
+		{a: random(100, 3), b: random(3, 2)},
+	} {
+		var want mat.Dense
+		want.Mul(test.a, test.b)
+		for _, n := range []int{1, 2, 3, 7, 200} {
+			var got mat.Dense
+			mul(&got, test.a, test.b, n)
+			if !mat.Equal(&got, &want) {
+				t.Errorf("unexpected product for concurrency %d", n)
+			}
+			// Reuse the receiver.
+			mul(&got, test.a, test.b, n)
+			if !mat.Equal(&got, &want) {
+				t.Errorf("unexpected product for concurrency %d with non-empty receiver", n)
+			}
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	base := mat.NewDense(13, 9, nil)
+	base.Apply(func(_, _ int, _ float64) float64 { return rnd.NormFloat64() }, base)
+	fn := func(r, c int, v float64) float64 {
+		if v < 0 || (r+c)%3 == 0 {
+			return 0
+		}
+		return v + float64(r*c)
+	}
+	for _, m := range []*mat.Dense{
+		base,
+		base.Slice(2, 11, 1, 7).(*mat.Dense),
+	} {
+		want := mat.DenseCopyOf(m)
+		applyInPlace(fn, want)
+		for _, n := range []int{0, 1, 2, 3, 8, 20} {
+			got := mat.DenseCopyOf(m)
+			apply(fn, got, n)
+			if !mat.Equal(got, want) {
+				t.Errorf("unexpected result for concurrency %d", n)
+			}
+		}
+	}
+
+	// Elements outside a slice must not be touched.
+	got := mat.DenseCopyOf(base)
+	apply(fn, got.Slice(2, 11, 1, 7).(*mat.Dense), 4)
+	r, c := got.Dims()
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			if 2 <= i && i < 11 && 1 <= j && j < 7 {
+				continue
+			}
+			if got.At(i, j) != base.At(i, j) {
+				t.Errorf("element (%d, %d) outside slice modified", i, j)
+			}
+		}
+	}
+}
+
+func TestParallelProjectGradient(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	g := mat.NewDense(13, 9, nil)
+	g.Apply(func(_, _ int, _ float64) float64 { return rnd.NormFloat64() }, g)
+	m := mat.NewDense(13, 9, nil)
+	m.Apply(func(_, _ int, _ float64) float64 {
+		if rnd.Intn(3) == 0 {
+			return 0
+		}
+		return rnd.Float64()
+	}, m)
+	for _, max := range []float64{0, 0.5} {
+		want := mat.DenseCopyOf(g)
+		projectGradient(want, m, max)
+		for _, n := range []int{0, 1, 2, 3, 8, 20} {
+			got := mat.DenseCopyOf(g)
+			parallelProjectGradient(got, m, 0, max, n)
+			if !mat.Equal(got, want) {
+				t.Errorf("unexpected projection for max %v and concurrency %d", max, n)
+			}
+		}
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	V, Wo, Ho := lowRank(50, 40, 4, rand.NewSource(1))
+	c := testConfig
+	c.MaxIter = 20
+	c.Limit = 0
+	wantW, wantH, _ := Factors(V, Wo, Ho, c)
+	for _, n := range []int{2, 3, 8} {
+		c.Concurrency = n
+		W, H, _ := Factors(V, Wo, Ho, c)
+		if !mat.Equal(W, wantW) || !mat.Equal(H, wantH) {
+			t.Errorf("factors differ from serial factors for concurrency %d", n)
+		}
+	}
+}
+
+func BenchmarkConcurrency(b *testing.B) {
+	V, Wo, Ho := lowRank(1000, 500, 20, rand.NewSource(1))
+	for _, n := range []int{1, 2, 4, 8} {
+		c := Config{
+			Tolerance:   1e-6,
+			MaxIter:     5,
+			MaxOuterSub: 1000,
+			MaxInnerSub: 20,
+			Limit:       time.Hour,
+			Concurrency: n,
+		}
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				Factors(V, Wo, Ho, c)
+			}
+		})
+	}
+}
+
+func BenchmarkFilters(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	m := mat.NewDense(2000, 1000, nil)
+	m.Apply(func(_, _ int, _ float64) float64 { return math.Max(0, rnd.NormFloat64()) }, m)
+	g := mat.NewDense(2000, 1000, nil)
+	g.Apply(func(_, _ int, _ float64) float64 { return rnd.NormFloat64() }, g)
+	for _, n := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("project/n=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				apply(posFilt, m, n)
+			}
+		})
+		b.Run(fmt.Sprintf("gradient/n=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				parallelProjectGradient(g, m, 0, 0, n)
+			}
+		})
+	}
+}
+
+func TestBlockedMul(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	a := mat.NewDense(4, 7, nil)
+	a.Apply(func(_, _ int, _ float64) float64 { return rnd.Float64() }, a)
+	b := mat.NewDense(7, 23, nil)
+	b.Apply(func(_, _ int, _ float64) float64 { return rnd.Float64() }, b)
+	bt := mat.DenseCopyOf(b.T())
+	sp := newCOO(b)
+
+	for _, test := range []struct {
+		name string
+		b    mat.Matrix
+	}{
+		{name: "dense", b: b},
+		{name: "transpose", b: bt.T()},
+		{name: "sparse", b: sp},
+	} {
+		var want mat.Dense
+		want.Mul(a, test.b)
+		for _, budget := range []int64{0, 1, 8 * 11 * 3, 8 * 11 * 5, 1 << 20} {
+			for _, n := range []int{1, 3} {
+				var got mat.Dense
+				blockedMul(&got, a, test.b, n, budget)
+				if !mat.EqualApprox(&got, &want, 1e-14) {
+					t.Errorf("unexpected product for %s operand with budget %d and concurrency %d",
+						test.name, budget, n)
+				}
+			}
+		}
+	}
+}
+
+func TestMaxWorkingMemory(t *testing.T) {
+	V, Wo, Ho := lowRank(40, 30, 4, rand.NewSource(1))
+	c := testConfig
+	c.MaxIter = 20
+	c.Limit = 0
+	wantW, wantH, _ := Factors(V, Wo, Ho, c)
+	for _, budget := range []int64{1, 8 * 40 * 5, 8 * 40 * 30} {
+		c.MaxWorkingMemory = budget
+		W, H, _ := Factors(V, Wo, Ho, c)
+		if !mat.EqualApprox(W, wantW, 1e-10) || !mat.EqualApprox(H, wantH, 1e-10) {
+			t.Errorf("factors differ from unbudgeted factors for budget %d", budget)
+		}
+	}
+}
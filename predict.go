@@ -0,0 +1,127 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/blas"
+	"gonum.org/v1/gonum/blas/blas64"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Predict returns the (i, j) entry of the reconstruction WH, the dot product
+// of row i of W and column j of H, without forming the product. Predict
+// panics if the number of columns of W does not equal the number of rows of
+// H or if i or j is out of range.
+func Predict(W, H *mat.Dense, i, j int) float64 {
+	checkPredict(W, H)
+	return predict(W.RawMatrix(), H.RawMatrix(), i, j)
+}
+
+// PredictBatch returns the entries of the reconstruction WH at the positions
+// (rows[n], cols[n]) without forming the product. PredictBatch panics if rows
+// and cols have different lengths, if the number of columns of W does not
+// equal the number of rows of H or if any index is out of range.
+func PredictBatch(W, H *mat.Dense, rows, cols []int) []float64 {
+	if len(rows) != len(cols) {
+		panic("nmf: length mismatch between rows and cols")
+	}
+	checkPredict(W, H)
+	w, h := W.RawMatrix(), H.RawMatrix()
+	p := make([]float64, len(rows))
+	for n, i := range rows {
+		p[n] = predict(w, h, i, cols[n])
+	}
+	return p
+}
+
+// checkPredict panics if W and H cannot be multiplied.
+func checkPredict(W, H *mat.Dense) {
+	_, wc := W.Dims()
+	hr, _ := H.Dims()
+	if wc != hr {
+		panic(fmt.Sprintf("nmf: W columns (%d) must equal H rows (%d)", wc, hr))
+	}
+}
+
+// predict returns the dot product of row i of w and column j of h.
+func predict(w, h blas64.General, i, j int) float64 {
+	if i < 0 || w.Rows <= i {
+		panic(fmt.Sprintf("nmf: row index %d out of range", i))
+	}
+	if j < 0 || h.Cols <= j {
+		panic(fmt.Sprintf("nmf: column index %d out of range", j))
+	}
+	var v float64
+	for k, x := range w.Data[i*w.Stride : i*w.Stride+w.Cols] {
+		v += x * h.Data[k*h.Stride+j]
+	}
+	return v
+}
+
+// Reconstruction is the product WH of a pair of factors. Its entries are
+// computed on demand from W and H by At, so the product is never formed.
+// A Reconstruction retains W and H, so changes to the factors are reflected
+// in its entries.
+type Reconstruction struct {
+	w, h blas64.General
+}
+
+// NewReconstruction returns the reconstruction WH. NewReconstruction panics
+// if the number of columns of W does not equal the number of rows of H.
+func NewReconstruction(W, H *mat.Dense) *Reconstruction {
+	checkPredict(W, H)
+	return &Reconstruction{w: W.RawMatrix(), h: H.RawMatrix()}
+}
+
+// Dims returns the dimensions of the reconstruction, the number of rows of
+// W and the number of columns of H.
+func (r *Reconstruction) Dims() (rows, cols int) { return r.w.Rows, r.h.Cols }
+
+// At returns the (i, j) entry of the reconstruction as for Predict.
+func (r *Reconstruction) At(i, j int) float64 { return predict(r.w, r.h, i, j) }
+
+// T returns the transpose of the reconstruction. No computation is performed.
+func (r *Reconstruction) T() mat.Matrix { return mat.Transpose{Matrix: r} }
+
+// Row returns row i of the reconstruction, the product of row i of W and H.
+// If dst is nil, a new slice is allocated, otherwise the row is stored in dst,
+// which must have length equal to the number of columns of H. Row panics if i
+// is out of range or if dst has the wrong length.
+func (r *Reconstruction) Row(dst []float64, i int) []float64 {
+	if i < 0 || r.w.Rows <= i {
+		panic(fmt.Sprintf("nmf: row index %d out of range", i))
+	}
+	if dst == nil {
+		dst = make([]float64, r.h.Cols)
+	}
+	if len(dst) != r.h.Cols {
+		panic(mat.ErrShape)
+	}
+	w := blas64.Vector{N: r.w.Cols, Inc: 1, Data: r.w.Data[i*r.w.Stride : i*r.w.Stride+r.w.Cols]}
+	blas64.Gemv(blas.Trans, 1, r.h, w, 0, blas64.Vector{N: len(dst), Inc: 1, Data: dst})
+	return dst
+}
+
+// Slice returns the reconstruction of rows [i, k) and columns [j, l), the
+// product of the corresponding rows of W and columns of H. No computation
+// is performed and the factors are shared. Slice panics if the bounds are
+// out of range.
+func (r *Reconstruction) Slice(i, k, j, l int) *Reconstruction {
+	if i < 0 || k < i || r.w.Rows < k || j < 0 || l < j || r.h.Cols < l {
+		panic(mat.ErrIndexOutOfRange)
+	}
+	w, h := r.w, r.h
+	if k > i {
+		w.Data = w.Data[i*w.Stride:]
+	} else {
+		w.Data = nil
+	}
+	w.Rows = k - i
+	h.Data = h.Data[j:]
+	h.Cols = l - j
+	return &Reconstruction{w: w, h: h}
+}
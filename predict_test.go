@@ -0,0 +1,117 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestPredict(t *testing.T) {
+	_, W, H := testFactors()
+	// Use views to check that strides are respected.
+	wr, wc := W.Dims()
+	hr, hc := H.Dims()
+	W = W.Slice(1, wr, 0, wc).(*mat.Dense)
+	H = H.Slice(0, hr, 1, hc).(*mat.Dense)
+	var WH mat.Dense
+	WH.Mul(W, H)
+
+	r, c := WH.Dims()
+	var rows, cols []int
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			want := WH.At(i, j)
+			if got := Predict(W, H, i, j); math.Abs(got-want) > 1e-14*math.Max(1, want) {
+				t.Errorf("unexpected prediction at (%d, %d): got:%v want:%v", i, j, got, want)
+			}
+			rows = append(rows, i)
+			cols = append(cols, j)
+		}
+	}
+	for n, got := range PredictBatch(W, H, rows, cols) {
+		if want := Predict(W, H, rows[n], cols[n]); got != want {
+			t.Errorf("unexpected batch prediction at (%d, %d): got:%v want:%v", rows[n], cols[n], got, want)
+		}
+	}
+
+	for _, fn := range []func(){
+		func() { Predict(W, H, -1, 0) },
+		func() { Predict(W, H, r, 0) },
+		func() { Predict(W, H, 0, c) },
+		func() { Predict(W, W, 0, 0) },
+		func() { PredictBatch(W, H, []int{0}, nil) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected panic")
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+func TestReconstruction(t *testing.T) {
+	_, W, H := testFactors()
+	var WH mat.Dense
+	WH.Mul(W, H)
+	R := NewReconstruction(W, H)
+
+	r, c := R.Dims()
+	if wr, wc := WH.Dims(); r != wr || c != wc {
+		t.Fatalf("unexpected dimensions: got:%d×%d want:%d×%d", r, c, wr, wc)
+	}
+	if !mat.EqualApprox(R, &WH, 1e-14) {
+		t.Error("unexpected reconstruction")
+	}
+	if !mat.EqualApprox(R.T(), WH.T(), 1e-14) {
+		t.Error("unexpected transposed reconstruction")
+	}
+	for i := 0; i < r; i++ {
+		got := R.Row(nil, i)
+		if !floats.EqualApprox(got, WH.RawRowView(i), 1e-14) {
+			t.Errorf("unexpected row %d: got:%v want:%v", i, got, WH.RawRowView(i))
+		}
+	}
+
+	for _, b := range [][4]int{{0, r, 0, c}, {1, r, 1, c - 1}, {0, 1, 2, c}, {r, r, 0, c}, {0, r, c, c}} {
+		got := R.Slice(b[0], b[1], b[2], b[3])
+		gr, gc := got.Dims()
+		if wr, wc := b[1]-b[0], b[3]-b[2]; gr != wr || gc != wc {
+			t.Errorf("unexpected dimensions of slice %v: got:%d×%d want:%d×%d", b, gr, gc, wr, wc)
+			continue
+		}
+		if gr == 0 || gc == 0 {
+			continue
+		}
+		if !mat.EqualApprox(got, WH.Slice(b[0], b[1], b[2], b[3]), 1e-14) {
+			t.Errorf("unexpected slice %v", b)
+		}
+	}
+
+	for _, fn := range []func(){
+		func() { NewReconstruction(H, W) },
+		func() { R.At(r, 0) },
+		func() { R.At(0, c) },
+		func() { R.Row(nil, -1) },
+		func() { R.Row(make([]float64, c+1), 0) },
+		func() { R.Slice(0, r+1, 0, c) },
+		func() { R.Slice(1, 0, 0, c) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected panic")
+				}
+			}()
+			fn()
+		}()
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// ReconstructionError returns the norm of V-WH for the given matrix norm.
+// Valid norms are those accepted by mat.Norm: 1, 2 (the Frobenius norm)
+// and math.Inf(1). ReconstructionError panics if the dimensions of V, W
+// and H are not compatible.
+func ReconstructionError(V, W, H *mat.Dense, norm float64) float64 {
+	mustFactorise(V, W, H)
+	var D mat.Dense
+	D.Mul(W, H)
+	D.Sub(V, &D)
+	return mat.Norm(&D, norm)
+}
+
+// RelativeResidual returns the relative reconstruction error ||V-WH||/||V||
+// using the Frobenius norm. The norm of V is floored at a small positive value
+// so that the relative residual of an all-zero V is finite. RelativeResidual
+// panics if the dimensions of V, W and H are not compatible.
+func RelativeResidual(V, W, H *mat.Dense) float64 {
+	return ReconstructionError(V, W, H, 2) / math.Max(mat.Norm(V, 2), epsilon)
+}
+
+// exactResidual is the relative residual below which a
+// factorisation is considered exact.
+const exactResidual = 1e-12
+
+// squaredResidual returns the squared Frobenius norm of V-WH,
+// ignoring NaN entries of V. The product WH is stored in wh,
+// reusing its backing data.
+func squaredResidual(wh, V, W, H *mat.Dense) float64 {
+	wh.Reset()
+	wh.Mul(W, H)
+	rv, rp := V.RawMatrix(), wh.RawMatrix()
+	var s float64
+	for i := 0; i < rv.Rows; i++ {
+		p := rp.Data[i*rp.Stride : i*rp.Stride+rp.Cols]
+		for j, v := range rv.Data[i*rv.Stride : i*rv.Stride+rv.Cols] {
+			if math.IsNaN(v) {
+				continue
+			}
+			d := v - p[j]
+			s += d * d
+		}
+	}
+	return s
+}
+
+// mustFactorise panics if V is not the same shape as the product WH.
+func mustFactorise(V, W, H *mat.Dense) {
+	vr, vc := V.Dims()
+	wr, wc := W.Dims()
+	hr, hc := H.Dims()
+	if wc != hr || vr != wr || vc != hc {
+		panic(fmt.Sprintf("nmf: dimension mismatch: V is %d×%d, W is %d×%d and H is %d×%d", vr, vc, wr, wc, hr, hc))
+	}
+}
+
+// ColumnErrors returns the Euclidean norms of the columns of V-WH, the
+// reconstruction residuals of the samples held in the columns of V.
+// ColumnErrors panics if the dimensions of V, W and H are not compatible.
+func ColumnErrors(V, W, H *mat.Dense) []float64 {
+	mustFactorise(V, W, H)
+	r, c := V.Dims()
+	errs := make([]float64, c)
+	col := mat.NewVecDense(r, nil)
+	for j := range errs {
+		// (V - WH)_j = V_j - W H_j
+		col.MulVec(W, H.ColView(j))
+		col.SubVec(V.ColView(j), col)
+		errs[j] = mat.Norm(col, 2)
+	}
+	return errs
+}
+
+// RowErrors returns the Euclidean norms of the rows of V-WH.
+// RowErrors panics if the dimensions of V, W and H are not compatible.
+func RowErrors(V, W, H *mat.Dense) []float64 {
+	mustFactorise(V, W, H)
+	r, c := V.Dims()
+	errs := make([]float64, r)
+	row := mat.NewVecDense(c, nil)
+	for i := range errs {
+		// (V - WH)_i = V_i - Hᵀ W_i
+		row.MulVec(H.T(), W.RowView(i))
+		row.SubVec(V.RowView(i), row)
+		errs[i] = mat.Norm(row, 2)
+	}
+	return errs
+}
+
+// InitialGradientNorm returns the norm of the gradient of the unregularised
+// Frobenius objective at the factors W and H. This is the value reported as
+// Result.InitialGradNorm when W and H are the initial solutions, and scales
+// Config.Tolerance in the ProjectedGradientNorm stopping criterion, so the
+// factorisation stops when the projected gradient norm falls below
+// Tolerance*InitialGradientNorm(V, Wo, Ho).
+// InitialGradientNorm panics if the dimensions of V, W and H are not compatible.
+func InitialGradientNorm(V, W, H *mat.Dense) float64 {
+	mustFactorise(V, W, H)
+	return gradNorm(gradients(V, W, H, penalty{}, penalty{}))
+}
+
+// KLDivergence returns the generalised Kullback-Leibler divergence of WH from V,
+//
+//	sum_ij V_ij log(V_ij / (WH)_ij) - V_ij + (WH)_ij
+//
+// using the convention that 0 log 0 = 0. Entries of WH are floored at a small
+// positive value to avoid infinite divergence. This is the objective minimised
+// by the KullbackLeibler objective. KLDivergence panics if the dimensions of V,
+// W and H are not compatible.
+func KLDivergence(V, W, H *mat.Dense) float64 {
+	mustFactorise(V, W, H)
+	return divergence(V, W, H)
+}
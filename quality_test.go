@@ -0,0 +1,117 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestReconstructionError(t *testing.T) {
+	V, W, H := testFactors()
+	var P, D mat.Dense
+	P.Mul(W, H)
+	D.Sub(V, &P)
+	for _, norm := range []float64{1, 2, math.Inf(1)} {
+		got := ReconstructionError(V, W, H, norm)
+		want := mat.Norm(&D, norm)
+		if got != want {
+			t.Errorf("unexpected error for norm %v: got:%v want:%v", norm, got, want)
+		}
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic for mismatched dimensions")
+			}
+		}()
+		ReconstructionError(V, H, W, 2)
+	}()
+}
+
+func TestColumnRowErrors(t *testing.T) {
+	V, W, H := testFactors()
+	var D mat.Dense
+	D.Mul(W, H)
+	D.Sub(V, &D)
+	r, c := D.Dims()
+
+	colErrs := ColumnErrors(V, W, H)
+	if len(colErrs) != c {
+		t.Fatalf("unexpected number of column errors: got:%d want:%d", len(colErrs), c)
+	}
+	for j, got := range colErrs {
+		want := mat.Norm(D.ColView(j), 2)
+		if math.Abs(got-want) > 1e-12*math.Max(1, want) {
+			t.Errorf("unexpected error for column %d: got:%v want:%v", j, got, want)
+		}
+	}
+
+	rowErrs := RowErrors(V, W, H)
+	if len(rowErrs) != r {
+		t.Fatalf("unexpected number of row errors: got:%d want:%d", len(rowErrs), r)
+	}
+	for i, got := range rowErrs {
+		want := mat.Norm(D.RowView(i), 2)
+		if math.Abs(got-want) > 1e-12*math.Max(1, want) {
+			t.Errorf("unexpected error for row %d: got:%v want:%v", i, got, want)
+		}
+	}
+
+	for _, fn := range []func(V, W, H *mat.Dense) []float64{ColumnErrors, RowErrors} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected panic for mismatched dimensions")
+				}
+			}()
+			fn(V, H, W)
+		}()
+	}
+}
+
+func TestInitialGradientNorm(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	_, _, res := FactorsResult(V, Wo, Ho, testConfig)
+	if got := InitialGradientNorm(V, Wo, Ho); got != res.InitialGradNorm {
+		t.Errorf("unexpected initial gradient norm: got:%v want:%v", got, res.InitialGradNorm)
+	}
+}
+
+func TestKLDivergence(t *testing.T) {
+	V := mat.NewDense(2, 2, []float64{1, 2, 0, 4})
+	W := mat.NewDense(2, 1, []float64{1, 1})
+	H := mat.NewDense(1, 2, []float64{2, 2})
+	// WH = [2 2; 2 2]
+	want := (math.Log(0.5) - 1 + 2) + (0 - 2 + 2) + (0 - 0 + 2) + (4*math.Log(2) - 4 + 2)
+	if got := KLDivergence(V, W, H); math.Abs(got-want) > 1e-14 {
+		t.Errorf("unexpected divergence: got:%v want:%v", got, want)
+	}
+	// A zero reconstruction of a positive entry is finite.
+	if got := KLDivergence(V, mat.NewDense(2, 1, nil), H); math.IsInf(got, 0) || math.IsNaN(got) {
+		t.Errorf("unexpected divergence for zero reconstruction: %v", got)
+	}
+}
+
+func TestRelativeResidual(t *testing.T) {
+	V, W, H := testFactors()
+	want := ReconstructionError(V, W, H, 2) / mat.Norm(V, 2)
+	if got := RelativeResidual(V, W, H); math.Abs(got-want) > 1e-14 {
+		t.Errorf("unexpected relative residual: got:%v want:%v", got, want)
+	}
+
+	r, c := V.Dims()
+	_, k := W.Dims()
+	Z := mat.NewDense(r, c, nil)
+	if got := RelativeResidual(Z, mat.NewDense(r, k, nil), mat.NewDense(k, c, nil)); got != 0 {
+		t.Errorf("unexpected relative residual for zero input: got:%v want:0", got)
+	}
+	if got := RelativeResidual(Z, W, H); math.IsInf(got, 0) || math.IsNaN(got) {
+		t.Errorf("unexpected relative residual for zero input: %v", got)
+	}
+}
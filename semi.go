@@ -0,0 +1,150 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// FactorsSemi returns matrices W and H that are factors of V within the specified
+// tolerance and computation limits given initial solutions Wo and Ho, where only
+// H is constrained to be non-negative. V and W may have entries of either sign.
+// The factorisation is the Semi-NMF described in Ding, Li and Jordan (2010)
+// 'Convex and Semi-Nonnegative Matrix Factorizations.' IEEE Transactions on
+// Pattern Analysis and Machine Intelligence 32:45.
+//
+// Each iteration solves for W by unconstrained least squares,
+//
+//	W = VHᵀ(HHᵀ)⁻¹
+//
+// and then updates H with a multiplicative rule using the positive and
+// negative parts of WᵀV and WᵀW. The Method, Objective and regularisation
+// fields of c are ignored. The returned ok is false if HHᵀ was singular
+// during the final W update.
+//
+// If c.Validate is true, FactorsSemi panics if the dimensions of the inputs
+// are not consistent, if V or Wo have non-finite entries or if Ho has
+// negative or non-finite entries.
+func FactorsSemi(V, Wo, Ho *mat.Dense, c Config) (W, H *mat.Dense, ok bool) {
+	if c.Validate {
+		err := checkSemiInputs(V, Wo, Ho)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	to := c.now()
+	u := newSemi(V, Wo, Ho)
+	grad := gradNorm(gradients(V, Wo, Ho, penalty{}, penalty{}))
+	c.Objective = Frobenius
+	W, H, res, _ := iterate(context.Background(), u, grad, to, c)
+	return W, H, res.OK
+}
+
+// checkSemiInputs returns an error if V, Wo and Ho are not valid inputs
+// to a Semi-NMF factorisation.
+func checkSemiInputs(V, Wo, Ho *mat.Dense) error {
+	err := checkDims(V, Wo, Ho)
+	if err != nil {
+		return err
+	}
+	for _, m := range []struct {
+		name string
+		m    *mat.Dense
+	}{
+		{name: "V", m: V},
+		{name: "Wo", m: Wo},
+	} {
+		r, c := m.m.Dims()
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				if v := m.m.At(i, j); math.IsNaN(v) || math.IsInf(v, 0) {
+					return fmt.Errorf("nmf: %s has %s at (%d, %d)", m.name, invalid(v), i, j)
+				}
+			}
+		}
+	}
+	i, j, v, ok := firstInvalid(Ho, false)
+	if !ok {
+		return fmt.Errorf("nmf: Ho has %s at (%d, %d)", invalid(v), i, j)
+	}
+	return nil
+}
+
+// semi is the Semi-NMF update rule for the Frobenius norm
+// objective with non-negative H and unconstrained W.
+type semi struct {
+	V, W, H *mat.Dense
+
+	hhT, hvT, wT mat.Dense
+
+	// pos and neg hold the positive and
+	// negative parts of WᵀW.
+	wTv, pos, neg mat.Dense
+	num, den      mat.Dense
+}
+
+func newSemi(V, Wo, Ho *mat.Dense) *semi {
+	s := &semi{V: V, W: new(mat.Dense), H: new(mat.Dense)}
+	s.W.CloneFrom(Wo)
+	s.H.CloneFrom(Ho)
+	return s
+}
+
+func (s *semi) projNorm() float64 {
+	gW, gH := gradients(s.V, s.W, s.H, penalty{}, penalty{})
+
+	// W is unconstrained, so only the gradient
+	// with respect to H is projected.
+	applyInPlace(decFilt(s.H), gH)
+	return gradNorm(gW, gH)
+}
+
+func (s *semi) update(_ context.Context) (ok bool, err error) {
+	// W = VHᵀ(HHᵀ)⁻¹, solved as (HHᵀ)Wᵀ = HVᵀ.
+	s.hhT.Reset()
+	s.hhT.Mul(s.H, s.H.T())
+	s.hvT.Reset()
+	s.hvT.Mul(s.H, s.V.T())
+	s.wT.Reset()
+	err = s.wT.Solve(&s.hhT, &s.hvT)
+	if _, isCond := err.(mat.Condition); err == nil || isCond {
+		copyInto(s.W, s.wT.T())
+		ok = true
+	}
+
+	// H *= sqrt(([WᵀV]⁺ + [WᵀW]⁻H) / ([WᵀV]⁻ + [WᵀW]⁺H))
+	s.wTv.Reset()
+	s.wTv.Mul(s.W.T(), s.V)
+	s.pos.Reset()
+	s.pos.Mul(s.W.T(), s.W)
+	copyInto(&s.neg, &s.pos)
+	applyInPlace(posPart, &s.pos)
+	applyInPlace(negPart, &s.neg)
+
+	s.num.Reset()
+	s.num.Mul(&s.neg, s.H)
+	applyInPlace(func(r, c int, v float64) float64 { return v + posPart(r, c, s.wTv.At(r, c)) }, &s.num)
+	s.den.Reset()
+	s.den.Mul(&s.pos, s.H)
+	applyInPlace(func(r, c int, v float64) float64 { return v + negPart(r, c, s.wTv.At(r, c)) }, &s.den)
+	applyInPlace(sqrtRatio(&s.num, &s.den), s.H)
+
+	return ok, nil
+}
+
+func (s *semi) objective() float64 { return frobenius(s.V, s.W, s.H) }
+
+func (s *semi) factors() (W, H *mat.Dense) { return s.W, s.H }
+
+// posPart returns the positive part of v, (|v|+v)/2.
+func posPart(_, _ int, v float64) float64 { return math.Max(v, 0) }
+
+// negPart returns the negative part of v, (|v|-v)/2.
+func negPart(_, _ int, v float64) float64 { return math.Max(-v, 0) }
@@ -0,0 +1,58 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestFactorsSemi(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	const rows, cols, k = 20, 15, 3
+
+	// Construct a mixed-sign V with a mixed-sign W
+	// and non-negative H.
+	W0 := mat.NewDense(rows, k, nil)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < k; j++ {
+			W0.Set(i, j, rnd.NormFloat64())
+		}
+	}
+	_, H0 := InitRandom(rows, cols, k, Uniform, rnd)
+	var V mat.Dense
+	V.Mul(W0, H0)
+
+	Wo := mat.NewDense(rows, k, nil)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < k; j++ {
+			Wo.Set(i, j, rnd.NormFloat64())
+		}
+	}
+	_, Ho := InitRandom(rows, cols, k, Uniform, rnd)
+
+	c := Config{
+		Tolerance: 1e-6,
+		MaxIter:   5000,
+		Limit:     time.Minute,
+		Validate:  true,
+	}
+	W, H, ok := FactorsSemi(&V, Wo, Ho, c)
+	if !ok {
+		t.Error("unexpected singular update")
+	}
+	if err := CheckNonNegative(H); err != nil {
+		t.Errorf("invalid H: %v", err)
+	}
+	if mat.Min(W) >= 0 {
+		t.Error("expected mixed-sign W")
+	}
+	if d := ReconstructionError(&V, W, H, 2); d > 1e-2*mat.Norm(&V, 2) {
+		t.Errorf("unexpected reconstruction error: %v", d)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// FactorsSparse returns matrices W and H that are non-negative factors of V within
+// the specified tolerance and computation limits given initial non-negative solutions
+// Wo and Ho. FactorsSparse is equivalent to Factors, but V may be any mat.Matrix.
+// If V is a mat.NonZeroDoer, the products involving V are computed by iterating over
+// its non-zero entries. The factorisation uses projected gradient sub-problems to
+// minimise the Frobenius norm objective and Method, Objective, MaskNaN and
+// Preprocess are ignored. The remaining fields of c, including the stopping
+// criteria and the post-processing of the factors, are used as for Factors.
+// FactorsSparse panics if c.Validate is true and the inputs are not valid.
+func FactorsSparse(V mat.Matrix, Wo, Ho *mat.Dense, c Config) (W, H *mat.Dense, ok bool) {
+	if c.Validate {
+		err := checkSparseInputs(V, Wo, Ho)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	to := c.now()
+	pW, pH := c.penalties()
+	gW, gH := gradients(V, Wo, Ho, pW, pH)
+	grad := gradNorm(gW, gH)
+	tol := math.Max(0.001, c.Tolerance) * grad
+	u := newProjectedGradient(V, Wo, Ho, gW, gH, pW, pH, tol, c)
+
+	c.Objective = Frobenius
+	c.residObjective = pW == (penalty{}) && pH == (penalty{})
+	W, H, res, _ := run(context.Background(), u, V, Wo, Ho, grad, to, c)
+	return W, H, res.OK
+}
+
+// checkSparseInputs returns an error if V, Wo and Ho are not valid inputs
+// to a sparse factorisation.
+func checkSparseInputs(V mat.Matrix, Wo, Ho *mat.Dense) error {
+	err := checkDims(V, Wo, Ho)
+	if err != nil {
+		return err
+	}
+	for _, m := range []struct {
+		name string
+		m    *mat.Dense
+	}{
+		{name: "Wo", m: Wo},
+		{name: "Ho", m: Ho},
+	} {
+		i, j, v, ok := firstInvalid(m.m, false)
+		if !ok {
+			return fmt.Errorf("nmf: %s has %s at (%d, %d)", m.name, invalid(v), i, j)
+		}
+	}
+	doNonZero(V, func(i, j int, v float64) {
+		if err == nil && (v < 0 || math.IsNaN(v) || math.IsInf(v, 0)) {
+			err = fmt.Errorf("nmf: V has %s at (%d, %d)", invalid(v), i, j)
+		}
+	})
+	return err
+}
+
+// isSparse returns whether m is a mat.NonZeroDoer or the transpose of one.
+func isSparse(m mat.Matrix) bool {
+	switch m := m.(type) {
+	case mat.NonZeroDoer:
+		return true
+	case mat.Transpose:
+		return isSparse(m.Matrix)
+	default:
+		return false
+	}
+}
+
+// doNonZero calls fn for each non-zero entry of m. If m is a mat.NonZeroDoer
+// or the transpose of one, its DoNonZero method is used. Otherwise every
+// entry of m is visited.
+func doNonZero(m mat.Matrix, fn func(i, j int, v float64)) {
+	switch m := m.(type) {
+	case mat.NonZeroDoer:
+		m.DoNonZero(fn)
+	case mat.Transpose:
+		doNonZero(m.Matrix, func(i, j int, v float64) { fn(j, i, v) })
+	default:
+		r, c := m.Dims()
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				if v := m.At(i, j); v != 0 {
+					fn(i, j, v)
+				}
+			}
+		}
+	}
+}
+
+// denseOf returns m as a *mat.Dense, copying m only if it is not
+// already a *mat.Dense.
+func denseOf(m mat.Matrix) *mat.Dense {
+	if d, ok := m.(*mat.Dense); ok {
+		return d
+	}
+	var d mat.Dense
+	copyInto(&d, m)
+	return &d
+}
+
+// zeroed sets m to be an r×c matrix of zeros, reusing its backing data
+// if it has sufficient capacity.
+func zeroed(m *mat.Dense, r, c int) *mat.Dense {
+	reuseAs(m, r, c)
+	raw := m.RawMatrix()
+	for i := 0; i < r; i++ {
+		row := raw.Data[i*raw.Stride : i*raw.Stride+c]
+		for j := range row {
+			row[j] = 0
+		}
+	}
+	return m
+}
+
+// mulSparseLeft sets dst to the product ab where a is sparse.
+func mulSparseLeft(dst *mat.Dense, a, b mat.Matrix) {
+	r, _ := a.Dims()
+	_, c := b.Dims()
+	d := zeroed(dst, r, c).RawMatrix()
+	bd := denseOf(b).RawMatrix()
+	doNonZero(a, func(i, k int, v float64) {
+		// Row i of the product gains v times row k of b.
+		dr := d.Data[i*d.Stride : i*d.Stride+c]
+		for j, bv := range bd.Data[k*bd.Stride : k*bd.Stride+c] {
+			dr[j] += v * bv
+		}
+	})
+}
+
+// mulSparseRight sets dst to the product ab where b is sparse.
+func mulSparseRight(dst *mat.Dense, a, b mat.Matrix) {
+	r, _ := a.Dims()
+	_, c := b.Dims()
+	d := zeroed(dst, r, c).RawMatrix()
+
+	// The rows of aT are the columns of a.
+	aT := denseOf(a.T()).RawMatrix()
+	doNonZero(b, func(k, j int, v float64) {
+		// Column j of the product gains v times column k of a.
+		for i, av := range aT.Data[k*aT.Stride : k*aT.Stride+r] {
+			d.Data[i*d.Stride+j] += v * av
+		}
+	})
+}
+
+// matrixNorm returns the Frobenius norm of m. NaN entries are ignored
+// if m is a *mat.Dense, and otherwise only the non-zero entries of m
+// are visited.
+func matrixNorm(m mat.Matrix) float64 {
+	if d, ok := m.(*mat.Dense); ok {
+		return frobeniusNorm(d)
+	}
+	var s float64
+	doNonZero(m, func(_, _ int, v float64) { s += v * v })
+	return math.Sqrt(s)
+}
+
+// sparseSquaredError returns the squared Frobenius norm of V-WH,
+// visiting only the non-zero entries of V. It uses the identity
+//
+//	||V-WH||^2 = ||V||^2 - 2 Σ V_ij (WH)_ij + tr(WᵀW HHᵀ)
+//
+// where the sum is over the non-zero entries of V.
+func sparseSquaredError(V mat.Matrix, W, H *mat.Dense) float64 {
+	w := W.RawMatrix()
+	h := H.RawMatrix()
+	var vv, vwh float64
+	doNonZero(V, func(i, j int, v float64) {
+		var wh float64
+		for l, wv := range w.Data[i*w.Stride : i*w.Stride+w.Cols] {
+			wh += wv * h.Data[l*h.Stride+j]
+		}
+		vv += v * v
+		vwh += v * wh
+	})
+
+	var wtw, hht mat.Dense
+	wtw.Mul(W.T(), W)
+	hht.Mul(H, H.T())
+	var tr float64
+	_, k := W.Dims()
+	for i := 0; i < k; i++ {
+		for j := 0; j < k; j++ {
+			tr += wtw.At(i, j) * hht.At(j, i)
+		}
+	}
+
+	return math.Max(0, vv-2*vwh+tr)
+}
@@ -0,0 +1,160 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// coo is a simple coordinate format sparse matrix.
+type coo struct {
+	r, c    int
+	i, j    []int
+	v       []float64
+	visited *int
+}
+
+func newCOO(m *mat.Dense) *coo {
+	r, c := m.Dims()
+	s := &coo{r: r, c: c, visited: new(int)}
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			if v := m.At(i, j); v != 0 {
+				s.i = append(s.i, i)
+				s.j = append(s.j, j)
+				s.v = append(s.v, v)
+			}
+		}
+	}
+	return s
+}
+
+func (s *coo) Dims() (r, c int) { return s.r, s.c }
+func (s *coo) T() mat.Matrix    { return mat.Transpose{Matrix: s} }
+func (s *coo) At(i, j int) float64 {
+	if uint(i) >= uint(s.r) || uint(j) >= uint(s.c) {
+		panic(mat.ErrIndexOutOfRange)
+	}
+	for k := range s.v {
+		if s.i[k] == i && s.j[k] == j {
+			return s.v[k]
+		}
+	}
+	return 0
+}
+func (s *coo) DoNonZero(fn func(i, j int, v float64)) {
+	for k, v := range s.v {
+		*s.visited++
+		fn(s.i[k], s.j[k], v)
+	}
+}
+
+// sparsify returns a copy of m with roughly the given fraction
+// of its entries set to zero.
+func sparsify(m *mat.Dense, frac float64, rnd *rand.Rand) *mat.Dense {
+	s := mat.DenseCopyOf(m)
+	s.Apply(func(_, _ int, v float64) float64 {
+		if rnd.Float64() < frac {
+			return 0
+		}
+		return v
+	}, s)
+	return s
+}
+
+func TestSparseMul(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	V, W, H := lowRank(30, 20, 4, rand.NewSource(1))
+	V = sparsify(V, 0.9, rnd)
+	S := newCOO(V)
+
+	for _, test := range []struct {
+		name         string
+		a, b         mat.Matrix
+		wantA, wantB mat.Matrix
+	}{
+		{name: "WᵀV", a: W.T(), b: S, wantA: W.T(), wantB: V},
+		{name: "HVᵀ", a: H, b: S.T(), wantA: H, wantB: V.T()},
+		{name: "VHᵀ", a: S, b: H.T(), wantA: V, wantB: H.T()},
+	} {
+		var want, got mat.Dense
+		want.Mul(test.wantA, test.wantB)
+		mul(&got, test.a, test.b, 1)
+		if !mat.EqualApprox(&got, &want, 1e-12) {
+			t.Errorf("unexpected product for %s", test.name)
+		}
+	}
+
+	if got, want := sparseSquaredError(S, W, H), math.Pow(ReconstructionError(V, W, H, 2), 2); math.Abs(got-want) > 1e-9*want {
+		t.Errorf("unexpected squared error: got:%v want:%v", got, want)
+	}
+}
+
+func TestFactorsSparse(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	V, Wo, Ho := lowRank(30, 20, 4, rand.NewSource(1))
+	V = sparsify(V, 0.8, rnd)
+	S := newCOO(V)
+
+	c := testConfig
+	c.MaxIter = 50
+	c.Limit = 0
+	c.Validate = true
+	wantW, wantH, _ := Factors(V, Wo, Ho, c)
+	W, H, _ := FactorsSparse(S, Wo, Ho, c)
+	if *S.visited == 0 {
+		t.Error("sparse entries not visited by DoNonZero")
+	}
+	if !mat.EqualApprox(W, wantW, 1e-6) || !mat.EqualApprox(H, wantH, 1e-6) {
+		t.Error("sparse factors do not match dense factors")
+	}
+
+	// The stopping criteria and post-processing
+	// are applied as for Factors. A zero component
+	// remains zero under projected gradient updates.
+	Wz, Hz := mat.DenseCopyOf(Wo), mat.DenseCopyOf(Ho)
+	r, n := V.Dims()
+	for i := 0; i < r; i++ {
+		Wz.Set(i, 0, 0)
+	}
+	for j := 0; j < n; j++ {
+		Hz.Set(0, j, 0)
+	}
+	_, _, base := FactorsResult(V, Wz, Hz, c)
+	for _, test := range []struct {
+		name  string
+		set   func(*Config)
+		rank  int
+		early bool
+	}{
+		{name: "canonicalize", set: func(c *Config) { c.Canonicalize = true }, rank: 4},
+		{name: "prune", set: func(c *Config) { c.PruneZeroComponents = true }, rank: 3},
+		{name: "residual", set: func(c *Config) { c.StopWhenRelResidBelow = 0.7 }, rank: 4, early: true},
+		{name: "penalised residual", set: func(c *Config) { c.StopWhenRelResidBelow = 0.7; c.L2W = 1e-3 }, rank: 4, early: true},
+	} {
+		c := c
+		test.set(&c)
+		wantW, wantH, want := FactorsResult(V, Wz, Hz, c)
+		if want.Rank != test.rank || (want.Iterations < base.Iterations) != test.early {
+			t.Errorf("unexpected dense result with %s: rank=%d iterations=%d", test.name, want.Rank, want.Iterations)
+		}
+		W, H, _ := FactorsSparse(S, Wz, Hz, c)
+		if !mat.EqualApprox(W, wantW, 1e-6) || !mat.EqualApprox(H, wantH, 1e-6) {
+			t.Errorf("sparse factors do not match dense factors with %s", test.name)
+		}
+	}
+
+	S.v[0] = -1
+	err := checkSparseInputs(S, Wo, Ho)
+	want := fmt.Sprintf("nmf: V has negative entry -1 at (%d, %d)", S.i[0], S.j[0])
+	if err == nil || err.Error() != want {
+		t.Errorf("unexpected error for negative sparse entry: got:%v want:%s", err, want)
+	}
+}
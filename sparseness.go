@@ -0,0 +1,342 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Sparseness returns the sparseness of x as defined in Hoyer (2004)
+// 'Non-negative Matrix Factorization with Sparseness Constraints.'
+// Journal of Machine Learning Research 5:1457,
+//
+//	(sqrt(n) - ||x||_1/||x||_2) / (sqrt(n) - 1)
+//
+// where n is the length of x. The sparseness is one for a vector with
+// a single non-zero element and zero for a vector with all elements
+// of equal magnitude. Sparseness returns NaN if x has fewer than two
+// elements or is zero.
+func Sparseness(x []float64) float64 {
+	n := float64(len(x))
+	l2 := floats.Norm(x, 2)
+	if n < 2 || l2 == 0 {
+		return math.NaN()
+	}
+	return (math.Sqrt(n) - floats.Norm(x, 1)/l2) / (math.Sqrt(n) - 1)
+}
+
+// sparsenessNorm returns the ratio of the L1 norm to the L2 norm of a
+// non-negative vector of length n with the given sparseness.
+func sparsenessNorm(n int, sparseness float64) float64 {
+	sqrtN := math.Sqrt(float64(n))
+	return sqrtN - (sqrtN-1)*sparseness
+}
+
+// sparsenessTargets returns the target sparseness of W and H, and
+// whether either is constrained. It panics if either target is not
+// zero, NaN or in (0, 1].
+func (c Config) sparsenessTargets() (sW, sH float64, ok bool) {
+	target := func(name string, s float64) float64 {
+		switch {
+		case math.IsNaN(s):
+			return 0
+		case s < 0 || s > 1:
+			panic(fmt.Sprintf("nmf: %s must be in [0, 1]: %v", name, s))
+		}
+		return s
+	}
+	sW = target("SparsenessW", c.SparsenessW)
+	sH = target("SparsenessH", c.SparsenessH)
+	return sW, sH, sW != 0 || sH != 0
+}
+
+// sparsenessConstrained returns whether either of the factors
+// has a sparseness constraint.
+func (c Config) sparsenessConstrained() bool {
+	_, _, ok := c.sparsenessTargets()
+	return ok
+}
+
+// projectSparse sets x to the non-negative vector closest in Euclidean
+// distance to x with L1 norm l1 and L2 norm l2, using the projection
+// operator described in Hoyer (2004). The length of x must be at least
+// two and l1 must be no greater than sqrt(len(x))*l2. work must have
+// the same length as x.
+func projectSparse(x []float64, l1, l2 float64, work []bool) {
+	n := len(x)
+	zero := work
+	for i := range zero {
+		zero[i] = false
+	}
+
+	// Project onto the hyperplane sum(x) = l1.
+	d := (l1 - floats.Sum(x)) / float64(n)
+	for i := range x {
+		x[i] += d
+	}
+	free := n
+	for {
+		// Project onto the sphere ||x||_2 = l2 within
+		// the hyperplane, moving away from its centre
+		// m, which has the minimum L2 norm.
+		m := l1 / float64(free)
+		var a, b, c float64
+		for i, v := range x {
+			if zero[i] {
+				continue
+			}
+			a += (v - m) * (v - m)
+			b += 2 * m * (v - m)
+			c += m * m
+		}
+		c -= l2 * l2
+		var alpha float64
+		if a != 0 {
+			disc := math.Max(b*b-4*a*c, 0)
+			alpha = (-b + math.Sqrt(disc)) / (2 * a)
+		}
+		negative := false
+		for i, v := range x {
+			if zero[i] {
+				continue
+			}
+			x[i] = m + alpha*(v-m)
+			if x[i] < 0 {
+				negative = true
+			}
+		}
+		if !negative {
+			return
+		}
+
+		// Fix negative elements at zero and return
+		// to the hyperplane within the remaining
+		// elements.
+		var sum float64
+		for i, v := range x {
+			if zero[i] {
+				continue
+			}
+			if v < 0 {
+				x[i] = 0
+				zero[i] = true
+				free--
+				continue
+			}
+			sum += v
+		}
+		d := (sum - l1) / float64(free)
+		for i := range x {
+			if !zero[i] {
+				x[i] -= d
+			}
+		}
+	}
+}
+
+// sparse is the projected gradient update rule for the Frobenius norm
+// objective with sparseness constraints on the columns of W and the rows
+// of H described in Hoyer (2004).
+type sparse struct {
+	V, W, H *mat.Dense
+
+	// sW and sH are the target sparseness of the
+	// columns of W and rows of H. Zero values
+	// specify an unconstrained factor.
+	sW, sH float64
+
+	// stepW and stepH are the adaptive gradient
+	// step sizes for the constrained factors.
+	stepW, stepH float64
+
+	g, step, next mat.Dense
+	num, den, tmp mat.Dense
+	col           []float64
+	work          []bool
+}
+
+func newSparse(V, Wo, Ho *mat.Dense, sW, sH float64) *sparse {
+	s := &sparse{
+		V: V, W: new(mat.Dense), H: new(mat.Dense),
+		sW: sW, sH: sH,
+		stepW: 1, stepH: 1,
+	}
+	s.W.CloneFrom(Wo)
+	s.H.CloneFrom(Ho)
+	r, _ := s.W.Dims()
+	_, c := s.H.Dims()
+	s.col = make([]float64, r)
+	if c > r {
+		r = c
+	}
+	s.work = make([]bool, r)
+
+	// Start from factors satisfying the constraints,
+	// preserving the scale of the product.
+	if sH != 0 {
+		s.normalizeH()
+		s.projectH(s.H)
+	}
+	if sW != 0 {
+		s.projectW(s.W)
+	}
+	return s
+}
+
+// projectW projects the columns of W onto the sparseness constraint,
+// retaining their L2 norms.
+func (s *sparse) projectW(W *mat.Dense) {
+	r, k := W.Dims()
+	if r < 2 {
+		return
+	}
+	l1 := sparsenessNorm(r, s.sW)
+	for j := 0; j < k; j++ {
+		mat.Col(s.col, j, W)
+		l2 := floats.Norm(s.col, 2)
+		projectSparse(s.col, l1*l2, l2, s.work[:r])
+		W.SetCol(j, s.col)
+	}
+}
+
+// projectH projects the rows of H onto the sparseness constraint
+// with unit L2 norm.
+func (s *sparse) projectH(H *mat.Dense) {
+	k, c := H.Dims()
+	if c < 2 {
+		return
+	}
+	l1 := sparsenessNorm(c, s.sH)
+	for i := 0; i < k; i++ {
+		projectSparse(H.RawRowView(i), l1, 1, s.work[:c])
+	}
+}
+
+// normalizeH scales the rows of H to unit L2 norm and the
+// corresponding columns of W by the inverse, leaving WH
+// unaltered.
+func (s *sparse) normalizeH() {
+	k, _ := s.H.Dims()
+	for i := 0; i < k; i++ {
+		row := s.H.RawRowView(i)
+		n := floats.Norm(row, 2)
+		if n == 0 {
+			continue
+		}
+		floats.Scale(1/n, row)
+		mat.Col(s.col, i, s.W)
+		floats.Scale(n, s.col)
+		s.W.SetCol(i, s.col)
+	}
+}
+
+func (s *sparse) projNorm() float64 {
+	gW, gH := gradients(s.V, s.W, s.H, penalty{}, penalty{})
+	return projNorm(gW, s.W, gH, s.H)
+}
+
+func (s *sparse) projGradients() (gW, gH *mat.Dense) {
+	gW, gH = gradients(s.V, s.W, s.H, penalty{}, penalty{})
+	projectGradient(gW, s.W, 0)
+	projectGradient(gH, s.H, 0)
+	return gW, gH
+}
+
+// minStep is the step size below which a constrained
+// gradient step is considered to have failed.
+const minStep = 1e-200
+
+func (s *sparse) update(_ context.Context) (ok bool, err error) {
+	if s.sH != 0 {
+		// Take a gradient step in H and project the rows,
+		// halving the step until the objective does not
+		// increase.
+		//  ∇H = Wᵀ(WH - V)
+		s.residual()
+		s.g.Reset()
+		s.g.Mul(s.W.T(), &s.tmp)
+		s.step.Reset()
+		obj := s.objective()
+		for {
+			s.step.Scale(s.stepH, &s.g)
+			copyInto(&s.next, s.H)
+			s.next.Sub(&s.next, &s.step)
+			s.projectH(&s.next)
+			if frobenius(s.V, s.W, &s.next) <= obj {
+				break
+			}
+			s.stepH /= 2
+			if s.stepH < minStep {
+				return false, nil
+			}
+		}
+		s.stepH *= 1.2
+		s.H.Copy(&s.next)
+	} else {
+		// H *= (WᵀV) / (WᵀWH)
+		s.num.Reset()
+		s.num.Mul(s.W.T(), s.V)
+		s.tmp.Reset()
+		s.tmp.Mul(s.W.T(), s.W)
+		s.den.Reset()
+		s.den.Mul(&s.tmp, s.H)
+		applyInPlace(ratio(&s.num, &s.den), s.H)
+	}
+
+	if s.sW != 0 {
+		// Give the rows of H unit norm, moving their
+		// scale into W, then take a gradient step in W
+		// and project the columns to their stepped norms.
+		//  ∇W = (WH - V)Hᵀ
+		s.normalizeH()
+		s.residual()
+		s.g.Reset()
+		s.g.Mul(&s.tmp, s.H.T())
+		s.step.Reset()
+		obj := s.objective()
+		for {
+			s.step.Scale(s.stepW, &s.g)
+			copyInto(&s.next, s.W)
+			s.next.Sub(&s.next, &s.step)
+			s.projectW(&s.next)
+			if frobenius(s.V, &s.next, s.H) <= obj {
+				break
+			}
+			s.stepW /= 2
+			if s.stepW < minStep {
+				return false, nil
+			}
+		}
+		s.stepW *= 1.2
+		s.W.Copy(&s.next)
+	} else {
+		// W *= (VHᵀ) / (WHHᵀ)
+		s.num.Reset()
+		s.num.Mul(s.V, s.H.T())
+		s.tmp.Reset()
+		s.tmp.Mul(s.H, s.H.T())
+		s.den.Reset()
+		s.den.Mul(s.W, &s.tmp)
+		applyInPlace(ratio(&s.num, &s.den), s.W)
+	}
+
+	return true, nil
+}
+
+// residual sets s.tmp to WH - V.
+func (s *sparse) residual() {
+	s.tmp.Reset()
+	s.tmp.Mul(s.W, s.H)
+	s.tmp.Sub(&s.tmp, s.V)
+}
+
+func (s *sparse) objective() float64 { return frobenius(s.V, s.W, s.H) }
+
+func (s *sparse) factors() (W, H *mat.Dense) { return s.W, s.H }
@@ -0,0 +1,138 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestSparseness(t *testing.T) {
+	for _, test := range []struct {
+		x    []float64
+		want float64
+	}{
+		{x: []float64{0, 0, 3, 0}, want: 1},
+		{x: []float64{2, 2, 2, 2}, want: 0},
+		{x: []float64{1, 1, 0, 0}, want: (2 - math.Sqrt(2)) / 1},
+		{x: []float64{1}, want: math.NaN()},
+		{x: []float64{0, 0}, want: math.NaN()},
+	} {
+		got := Sparseness(test.x)
+		if math.IsNaN(test.want) {
+			if !math.IsNaN(got) {
+				t.Errorf("unexpected sparseness for %v: got:%v want:NaN", test.x, got)
+			}
+			continue
+		}
+		if math.Abs(got-test.want) > 1e-14 {
+			t.Errorf("unexpected sparseness for %v: got:%v want:%v", test.x, got, test.want)
+		}
+	}
+}
+
+func TestProjectSparse(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, n := range []int{2, 5, 50} {
+		for _, target := range []float64{0.1, 0.5, 0.9, 1} {
+			x := make([]float64, n)
+			for i := range x {
+				x[i] = rnd.NormFloat64()
+			}
+			l2 := 2.
+			l1 := sparsenessNorm(n, target) * l2
+			projectSparse(x, l1, l2, make([]bool, n))
+			for i, v := range x {
+				if v < 0 {
+					t.Errorf("negative element %d for n=%d target=%v: %v", i, n, target, v)
+				}
+			}
+			if got := floats.Norm(x, 1); math.Abs(got-l1) > 1e-10 {
+				t.Errorf("unexpected L1 norm for n=%d target=%v: got:%v want:%v", n, target, got, l1)
+			}
+			if got := floats.Norm(x, 2); math.Abs(got-l2) > 1e-10 {
+				t.Errorf("unexpected L2 norm for n=%d target=%v: got:%v want:%v", n, target, got, l2)
+			}
+			if got := Sparseness(x); math.Abs(got-target) > 1e-10 {
+				t.Errorf("unexpected sparseness for n=%d: got:%v want:%v", n, got, target)
+			}
+		}
+	}
+}
+
+func TestFactorsSparseness(t *testing.T) {
+	V, Wo, Ho := lowRank(30, 20, 3, rand.NewSource(1))
+	c := Config{
+		Tolerance:     1e-6,
+		MaxIter:       200,
+		StopCriterion: RelativeObjective,
+		MaxOuterSub:   1000,
+		MaxInnerSub:   20,
+		Limit:         time.Minute,
+	}
+	initial := frobenius(V, Wo, Ho)
+	for _, test := range []struct{ sW, sH float64 }{
+		{sW: 0.6},
+		{sH: 0.4},
+		{sW: 0.5, sH: 0.3},
+	} {
+		c.SparsenessW, c.SparsenessH = test.sW, test.sH
+		W, H, _ := Factors(V, Wo, Ho, c)
+		for _, m := range []*mat.Dense{W, H} {
+			if i, j, v, ok := firstInvalid(m, false); !ok {
+				t.Errorf("invalid factor entry at (%d, %d) for sW=%v sH=%v: %v", i, j, test.sW, test.sH, v)
+			}
+		}
+		if test.sW != 0 {
+			_, k := W.Dims()
+			for j := 0; j < k; j++ {
+				if got := Sparseness(mat.Col(nil, j, W)); math.Abs(got-test.sW) > 1e-8 {
+					t.Errorf("unexpected sparseness of W column %d for sW=%v sH=%v: got:%v", j, test.sW, test.sH, got)
+				}
+			}
+		}
+		if test.sH != 0 {
+			k, _ := H.Dims()
+			for i := 0; i < k; i++ {
+				row := H.RawRowView(i)
+				if got := Sparseness(row); math.Abs(got-test.sH) > 1e-8 {
+					t.Errorf("unexpected sparseness of H row %d for sW=%v sH=%v: got:%v", i, test.sW, test.sH, got)
+				}
+				if n := floats.Norm(row, 2); math.Abs(n-1) > 1e-8 {
+					t.Errorf("unexpected norm of H row %d for sW=%v sH=%v: got:%v", i, test.sW, test.sH, n)
+				}
+			}
+		}
+		if obj := frobenius(V, W, H); obj >= initial {
+			t.Errorf("objective not reduced for sW=%v sH=%v: got:%v initial:%v", test.sW, test.sH, obj, initial)
+		}
+	}
+
+	// NaN targets leave the factorisation unchanged.
+	c.SparsenessW, c.SparsenessH = 0, 0
+	wantW, wantH, _ := Factors(V, Wo, Ho, c)
+	c.SparsenessW, c.SparsenessH = math.NaN(), math.NaN()
+	W, H, _ := Factors(V, Wo, Ho, c)
+	if !mat.Equal(W, wantW) || !mat.Equal(H, wantH) {
+		t.Error("unexpected factors for NaN sparseness targets")
+	}
+
+	for _, s := range []float64{-0.1, 1.5} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for sparseness %v", s)
+				}
+			}()
+			c.SparsenessW = s
+			Factors(V, Wo, Ho, c)
+		}()
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// FactorsSymmetric returns a non-negative matrix H such that HHᵀ approximates the
+// symmetric non-negative matrix A within the specified tolerance and computation
+// limits given the initial non-negative solution Ho. The objective ||A - HHᵀ||^2
+// is minimised by projected gradient descent with an Armijo line search of up to
+// c.MaxInnerSub steps per iteration. The factorisation stops when the projected
+// gradient norm falls below c.Tolerance times the initial gradient norm. Method,
+// Objective and the regularisation penalties in c are ignored.
+//
+// FactorsSymmetric returns an error if A is not square or Ho does not have the
+// same number of rows as A. If c.Validate is true, it also returns an error if A
+// or Ho have negative or non-finite entries, or if A is not symmetric to within
+// c.Tolerance times its largest entry.
+func FactorsSymmetric(A, Ho *mat.Dense, c Config) (H *mat.Dense, ok bool, err error) {
+	err = checkSymmetric(A, Ho, c)
+	if err != nil {
+		return Ho, false, err
+	}
+
+	to := c.now()
+	s := newSymmetric(A, Ho, c.MaxInnerSub)
+	s.gradient()
+	grad := mat.Norm(&s.g, 2)
+
+	c.Objective = Frobenius
+	_, H, res, _ := iterate(context.Background(), s, grad, to, c)
+	return H, res.OK, nil
+}
+
+// checkSymmetric returns an error if A and Ho are not valid inputs
+// to a symmetric factorisation.
+func checkSymmetric(A, Ho *mat.Dense, c Config) error {
+	ar, ac := A.Dims()
+	hr, _ := Ho.Dims()
+	switch {
+	case ar != ac:
+		return fmt.Errorf("nmf: A must be square: A is %d×%d", ar, ac)
+	case hr != ar:
+		return fmt.Errorf("nmf: A rows (%d) must equal Ho rows (%d)", ar, hr)
+	}
+	if !c.Validate {
+		return nil
+	}
+
+	for _, m := range []struct {
+		name string
+		m    *mat.Dense
+	}{
+		{name: "A", m: A},
+		{name: "Ho", m: Ho},
+	} {
+		i, j, v, ok := firstInvalid(m.m, false)
+		if !ok {
+			return fmt.Errorf("nmf: %s has %s at (%d, %d)", m.name, invalid(v), i, j)
+		}
+	}
+	tol := c.Tolerance * mat.Max(A)
+	for i := 0; i < ar; i++ {
+		for j := i + 1; j < ac; j++ {
+			if math.Abs(A.At(i, j)-A.At(j, i)) > tol {
+				return fmt.Errorf("nmf: A is not symmetric at (%d, %d)", i, j)
+			}
+		}
+	}
+	return nil
+}
+
+// symmetric is the projected gradient update rule for the
+// symmetric factorisation A = HHᵀ.
+type symmetric struct {
+	A, H *mat.Dense
+
+	// alpha is the current line search step size
+	// and inner is the maximum number of steps.
+	alpha float64
+	inner int
+
+	// h holds the current and candidate solutions.
+	h [2]mat.Dense
+
+	hhT, r, g, d mat.Dense
+}
+
+func newSymmetric(A, Ho *mat.Dense, inner int) *symmetric {
+	s := &symmetric{A: A, alpha: 1, inner: inner}
+	copyInto(&s.h[0], Ho)
+	s.H = &s.h[0]
+	return s
+}
+
+// residual sets s.r to HHᵀ - A and returns ||A - HHᵀ||^2.
+func (s *symmetric) residual(H *mat.Dense) float64 {
+	s.hhT.Reset()
+	s.hhT.Mul(H, H.T())
+	s.r.Reset()
+	s.r.Sub(&s.hhT, s.A)
+	f := mat.Norm(&s.r, 2)
+	return f * f
+}
+
+// gradient sets s.g to the gradient of the objective, 4(HHᵀ - A)H.
+func (s *symmetric) gradient() {
+	s.residual(s.H)
+	s.g.Reset()
+	s.g.Mul(&s.r, s.H)
+	applyInPlace(func(_, _ int, v float64) float64 { return 4 * v }, &s.g)
+}
+
+func (s *symmetric) projNorm() float64 {
+	s.gradient()
+	filt := decFilt(s.H)
+	raw := s.g.RawMatrix()
+	var proj float64
+	for i := 0; i < raw.Rows; i++ {
+		for j, v := range raw.Data[i*raw.Stride : i*raw.Stride+raw.Cols] {
+			v = filt(i, j, v)
+			proj += v * v
+		}
+	}
+	return math.Sqrt(proj)
+}
+
+func (s *symmetric) update(_ context.Context) (ok bool, err error) {
+	const (
+		beta  = 0.1
+		sigma = 0.01
+	)
+
+	s.gradient()
+	f := s.objective()
+	Hn := &s.h[0]
+	if Hn == s.H {
+		Hn = &s.h[1]
+	}
+	for j := 0; j < s.inner; j++ {
+		Hn.Reset()
+		Hn.Scale(s.alpha, &s.g)
+		Hn.Sub(s.H, Hn)
+		applyInPlace(posFilt, Hn)
+
+		s.d.Reset()
+		s.d.Sub(Hn, s.H)
+		s.d.MulElem(&s.g, &s.d)
+		if s.residual(Hn)-f <= sigma*mat.Sum(&s.d) {
+			s.H = Hn
+			if j == 0 {
+				// Try a longer step next time.
+				s.alpha /= beta
+			}
+			return true, nil
+		}
+		s.alpha *= beta
+	}
+	return false, nil
+}
+
+func (s *symmetric) objective() float64 { return s.residual(s.H) }
+
+func (s *symmetric) factors() (W, H *mat.Dense) { return s.H, s.H }
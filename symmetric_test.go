@@ -0,0 +1,70 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestFactorsSymmetric(t *testing.T) {
+	src := rand.NewSource(1)
+	B, _ := InitRandom(12, 1, 3, Uniform, src)
+	var A mat.Dense
+	A.Mul(B, B.T())
+	Ho, _ := InitRandom(12, 1, 3, Uniform, src)
+
+	c := Config{
+		Tolerance:   1e-6,
+		MaxIter:     5000,
+		MaxInnerSub: 20,
+		Limit:       time.Minute,
+		Validate:    true,
+	}
+	H, _, err := FactorsSymmetric(&A, Ho, c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := CheckNonNegative(H); err != nil {
+		t.Errorf("invalid H: %v", err)
+	}
+	if d := ReconstructionError(&A, H, mat.DenseCopyOf(H.T()), 2); d > 1e-3*mat.Norm(&A, 2) {
+		t.Errorf("unexpected reconstruction error: %v", d)
+	}
+
+	for _, test := range []struct {
+		A, Ho *mat.Dense
+		want  string
+	}{
+		{
+			A:    mat.NewDense(2, 3, []float64{1, 2, 3, 4, 5, 6}),
+			Ho:   mat.NewDense(2, 1, []float64{1, 1}),
+			want: "nmf: A must be square: A is 2×3",
+		},
+		{
+			A:    mat.NewDense(2, 2, []float64{1, 2, 2, 1}),
+			Ho:   mat.NewDense(3, 1, []float64{1, 1, 1}),
+			want: "nmf: A rows (2) must equal Ho rows (3)",
+		},
+		{
+			A:    mat.NewDense(2, 2, []float64{1, 2, 3, 1}),
+			Ho:   mat.NewDense(2, 1, []float64{1, 1}),
+			want: "nmf: A is not symmetric at (0, 1)",
+		},
+		{
+			A:    mat.NewDense(2, 2, []float64{1, -2, -2, 1}),
+			Ho:   mat.NewDense(2, 1, []float64{1, 1}),
+			want: "nmf: A has negative entry -2 at (0, 1)",
+		},
+	} {
+		_, _, err := FactorsSymmetric(test.A, test.Ho, c)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("unexpected error: got:%v want:%s", err, test.want)
+		}
+	}
+}
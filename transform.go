@@ -0,0 +1,131 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"context"
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Transform returns the non-negative matrix Hnew that minimises ||Vnew - W*Hnew||
+// for the fixed basis W, encoding the columns of Vnew in terms of the columns of
+// W. The non-negative least squares problem is solved by projected gradient from
+// a zero initial solution, stopping when the projected gradient norm falls below
+// c.Tolerance times the initial gradient norm, or after the outer iteration limit
+// of the H sub-problems. Any L1H and L2H penalties in c are applied to Hnew, and
+// the sub-problem fields of c, such as the iteration limits MaxOuterSubH and
+// MaxInnerSubH, the step size bounds, MaxH, ProjectionFloor, Concurrency and
+// MaxWorkingMemory, are used as for the H sub-problems of Factors. The returned
+// ok is true if the tolerance was met.
+func Transform(W, Vnew *mat.Dense, c Config) (Hnew *mat.Dense, ok bool) {
+	vr, vc := Vnew.Dims()
+	wr, wc := W.Dims()
+	if vr != wr {
+		panic("nmf: dimension mismatch between W and Vnew")
+	}
+
+	work := newWorkspace(c, c.MaxH)
+	_, pH := c.penalties()
+	var g mat.Dense
+	g.Mul(W.T(), Vnew)
+	g.Scale(-1, &g)
+	pH.addL1(&g)
+	tol := c.Tolerance * mat.Norm(&g, 2)
+
+	Ho := mat.NewDense(wc, vc, nil)
+	outer, inner := override(c.MaxOuterSubH, c.MaxOuterSub), override(c.MaxInnerSubH, c.MaxInnerSub)
+	Hnew, _, i, _, _ := nnlsSubproblem(context.Background(), Vnew, W, Ho, tol, outer, inner, pH, &work)
+	return Hnew, i < outer
+}
+
+// FitBasis returns the non-negative matrix W that minimises ||V - W*H|| for the
+// fixed coding matrix H, learning the basis for a known encoding of the columns
+// of V. It is the counterpart of Transform, solving the non-negative least
+// squares problem for Wᵀ by projected gradient from a zero initial solution and
+// stopping when the projected gradient norm falls below c.Tolerance times the
+// initial gradient norm, or after the outer iteration limit of the W
+// sub-problems. Any L1W and L2W penalties in c are applied to W, and the
+// sub-problem fields of c are used as for the W sub-problems of Factors, with
+// MaxW, MaxOuterSubW and MaxInnerSubW in place of MaxH, MaxOuterSubH and
+// MaxInnerSubH. The returned ok is true if the tolerance was met.
+func FitBasis(V, H *mat.Dense, c Config) (W *mat.Dense, ok bool) {
+	vr, vc := V.Dims()
+	hr, hc := H.Dims()
+	if vc != hc {
+		panic("nmf: dimension mismatch between H and V")
+	}
+
+	work := newWorkspace(c, c.MaxW)
+	pW, _ := c.penalties()
+	var g mat.Dense
+	g.Mul(H, V.T())
+	g.Scale(-1, &g)
+	pW.addL1(&g)
+	tol := c.Tolerance * mat.Norm(&g, 2)
+
+	WTo := mat.NewDense(hr, vr, nil)
+	outer, inner := override(c.MaxOuterSubW, c.MaxOuterSub), override(c.MaxInnerSubW, c.MaxInnerSub)
+	WT, _, i, _, _ := nnlsSubproblem(context.Background(), V.T(), H.T(), WTo, tol, outer, inner, pW, &work)
+	return mat.DenseCopyOf(WT.T()), i < outer
+}
+
+// UpdateColumns returns a copy of H with the columns indexed by cols re-solved
+// against the fixed basis W for the corresponding columns of V, leaving the
+// other columns of H unchanged. Each re-solved column minimises ||v - W*h|| for
+// h >= 0, starting from its value in H, so the factorisation is refreshed
+// without refitting when only a few columns of V have changed. The tolerance,
+// iteration limits, penalties and sub-problem fields of c are used as described
+// for Transform. The
+// returned ok is true if the tolerance was met.
+// UpdateColumns panics if the dimensions of W, H and V are inconsistent or if
+// cols holds an out of range or repeated column index.
+func UpdateColumns(W, H, V *mat.Dense, cols []int, c Config) (Hnew *mat.Dense, ok bool) {
+	err := checkDims(V, W, H)
+	if err != nil {
+		panic(err)
+	}
+	vr, vc := V.Dims()
+	k, _ := H.Dims()
+	seen := make(map[int]bool, len(cols))
+	for _, j := range cols {
+		if j < 0 || vc <= j {
+			panic(fmt.Sprintf("nmf: column index %d out of range", j))
+		}
+		if seen[j] {
+			panic(fmt.Sprintf("nmf: repeated column index %d", j))
+		}
+		seen[j] = true
+	}
+	Hnew = mat.DenseCopyOf(H)
+	if len(cols) == 0 {
+		return Hnew, true
+	}
+
+	// Gather the changed columns so that they
+	// are solved as a single sub-problem.
+	Vsub := mat.NewDense(vr, len(cols), nil)
+	Ho := mat.NewDense(k, len(cols), nil)
+	for i, j := range cols {
+		Vsub.Slice(0, vr, i, i+1).(*mat.Dense).Copy(V.Slice(0, vr, j, j+1))
+		Ho.Slice(0, k, i, i+1).(*mat.Dense).Copy(H.Slice(0, k, j, j+1))
+	}
+
+	work := newWorkspace(c, c.MaxH)
+	_, pH := c.penalties()
+	var g mat.Dense
+	g.Mul(W.T(), Vsub)
+	g.Scale(-1, &g)
+	pH.addL1(&g)
+	tol := c.Tolerance * mat.Norm(&g, 2)
+
+	outer, inner := override(c.MaxOuterSubH, c.MaxOuterSub), override(c.MaxInnerSubH, c.MaxInnerSub)
+	Hsub, _, iter, _, _ := nnlsSubproblem(context.Background(), Vsub, W, Ho, tol, outer, inner, pH, &work)
+	for i, j := range cols {
+		Hnew.Slice(0, k, j, j+1).(*mat.Dense).Copy(Hsub.Slice(0, k, i, i+1))
+	}
+	return Hnew, iter < outer
+}
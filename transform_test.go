@@ -0,0 +1,179 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestTransform(t *testing.T) {
+	var V mat.Dense
+	W := mat.NewDense(4, 2, []float64{1, 0, 2, 1, 0, 3, 1, 1})
+	H := mat.NewDense(2, 3, []float64{1, 0, 2, 3, 1, 0})
+	V.Mul(W, H)
+
+	Hnew, ok := Transform(W, &V, testConfig)
+	if !ok {
+		t.Error("transform did not converge")
+	}
+	if err := CheckNonNegative(Hnew); err != nil {
+		t.Errorf("invalid Hnew: %v", err)
+	}
+	if !mat.EqualApprox(Hnew, H, 1e-3) {
+		t.Errorf("unexpected encoding:\ngot:\n%.4v\nwant:\n%.4v", mat.Formatted(Hnew), mat.Formatted(H))
+	}
+}
+
+func TestUpdateColumns(t *testing.T) {
+	W := mat.NewDense(4, 2, []float64{1, 0, 2, 1, 0, 3, 1, 1})
+	H := mat.NewDense(2, 4, []float64{1, 0, 2, 1, 3, 1, 0, 2})
+	var V mat.Dense
+	V.Mul(W, H)
+
+	// Change two columns of V with a known encoding.
+	want := mat.DenseCopyOf(H)
+	for _, j := range []int{1, 3} {
+		want.Set(0, j, want.At(0, j)+1)
+		want.Set(1, j, want.At(1, j)+2)
+	}
+	V.Mul(W, want)
+
+	Hnew, ok := UpdateColumns(W, H, &V, []int{3, 1}, testConfig)
+	if !ok {
+		t.Error("update did not converge")
+	}
+	if !mat.EqualApprox(Hnew, want, 1e-3) {
+		t.Errorf("unexpected encoding:\ngot:\n%.4v\nwant:\n%.4v", mat.Formatted(Hnew), mat.Formatted(want))
+	}
+	for _, j := range []int{0, 2} {
+		for i := 0; i < 2; i++ {
+			if Hnew.At(i, j) != H.At(i, j) {
+				t.Errorf("unchanged column %d altered", j)
+			}
+		}
+	}
+	if !mat.Equal(H, mat.NewDense(2, 4, []float64{1, 0, 2, 1, 3, 1, 0, 2})) {
+		t.Error("input H altered")
+	}
+
+	for _, cols := range [][]int{{4}, {-1}, {1, 1}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for columns %v", cols)
+				}
+			}()
+			UpdateColumns(W, H, &V, cols, testConfig)
+		}()
+	}
+}
+
+func TestFitBasis(t *testing.T) {
+	var V mat.Dense
+	W := mat.NewDense(4, 2, []float64{1, 0, 2, 1, 0, 3, 1, 1})
+	H := mat.NewDense(2, 3, []float64{1, 0, 2, 3, 1, 0})
+	V.Mul(W, H)
+
+	Wnew, ok := FitBasis(&V, H, testConfig)
+	if !ok {
+		t.Error("fit did not converge")
+	}
+	if err := CheckNonNegative(Wnew); err != nil {
+		t.Errorf("invalid W: %v", err)
+	}
+	if !mat.EqualApprox(Wnew, W, 1e-3) {
+		t.Errorf("unexpected basis:\ngot:\n%.4v\nwant:\n%.4v", mat.Formatted(Wnew), mat.Formatted(W))
+	}
+}
+
+func TestTransformConfig(t *testing.T) {
+	var V mat.Dense
+	W := mat.NewDense(4, 2, []float64{1, 0, 2, 1, 0, 3, 1, 1})
+	H := mat.NewDense(2, 3, []float64{1, 0, 2, 3, 1, 0})
+	V.Mul(W, H)
+
+	// The sub-problem fields of c apply to Transform,
+	// FitBasis and UpdateColumns as they do to Factors.
+	const (
+		floor   = 1e-3
+		maxStep = 0.5
+		bound   = 2.5
+	)
+	c := testConfig
+	c.ProjectionFloor = floor
+	c.MaxStepSize = maxStep
+	c.MaxW, c.MaxH = bound, bound
+	var steps int
+	c.SubproblemTrace = func(_, _ int, alpha float64, _ bool) {
+		steps++
+		if alpha > maxStep {
+			t.Errorf("step size exceeds MaxStepSize: %v", alpha)
+		}
+	}
+	for _, test := range []struct {
+		name string
+		fn   func() *mat.Dense
+	}{
+		{name: "Transform", fn: func() *mat.Dense { H, _ := Transform(W, &V, c); return H }},
+		{name: "FitBasis", fn: func() *mat.Dense { W, _ := FitBasis(&V, H, c); return W }},
+		{name: "UpdateColumns", fn: func() *mat.Dense { H, _ := UpdateColumns(W, H, &V, []int{0, 1, 2}, c); return H }},
+	} {
+		steps = 0
+		got := test.fn()
+		if steps == 0 {
+			t.Errorf("no sub-problem steps traced for %s", test.name)
+		}
+		if min := mat.Min(got); min < floor {
+			t.Errorf("entry below ProjectionFloor for %s: %v", test.name, min)
+		}
+		if max := mat.Max(got); max > bound {
+			t.Errorf("entry above upper bound for %s: %v", test.name, max)
+		}
+	}
+}
+
+func TestTransformSubproblemLimits(t *testing.T) {
+	var V mat.Dense
+	W := mat.NewDense(4, 2, []float64{1, 0, 2, 1, 0, 3, 1, 1})
+	H := mat.NewDense(2, 3, []float64{1, 0, 2, 3, 1, 0})
+	V.Mul(W, H)
+
+	// The W and H sub-problem overrides take
+	// precedence over MaxOuterSub and MaxInnerSub.
+	c := testConfig
+	c.Tolerance = 0
+	c.MaxOuterSub, c.MaxInnerSub = 1000, 20
+	c.MaxOuterSubW, c.MaxInnerSubW = 3, 2
+	c.MaxOuterSubH, c.MaxInnerSubH = 2, 1
+	var outer, inner int
+	c.SubproblemTrace = func(o, i int, _ float64, _ bool) {
+		if o >= outer {
+			outer = o + 1
+		}
+		if i >= inner {
+			inner = i + 1
+		}
+	}
+	for _, test := range []struct {
+		name         string
+		fn           func() bool
+		outer, inner int
+	}{
+		{name: "Transform", fn: func() bool { _, ok := Transform(W, &V, c); return ok }, outer: 2, inner: 1},
+		{name: "FitBasis", fn: func() bool { _, ok := FitBasis(&V, H, c); return ok }, outer: 3, inner: 2},
+		{name: "UpdateColumns", fn: func() bool { _, ok := UpdateColumns(W, H, &V, []int{0, 2}, c); return ok }, outer: 2, inner: 1},
+	} {
+		outer, inner = 0, 0
+		if test.fn() {
+			t.Errorf("unexpected success for %s with zero tolerance", test.name)
+		}
+		if outer != test.outer || inner > test.inner {
+			t.Errorf("unexpected iterations for %s: outer=%d inner=%d want outer=%d inner<=%d",
+				test.name, outer, inner, test.outer, test.inner)
+		}
+	}
+}
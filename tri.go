@@ -0,0 +1,184 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// FactorsTri returns non-negative matrices W, S and H such that WSH approximates
+// V within the specified tolerance and computation limits given initial
+// non-negative solutions Wo, So and Ho. The rows of W give the membership of the
+// rows of V in row clusters and the columns of H the membership of the columns
+// of V in column clusters, with S holding the associations between the row and
+// column clusters, so the factorisation co-clusters the rows and columns of V.
+// The factorisation is the non-negative matrix tri-factorisation described in
+// Ding, Li, Peng and Park (2006) 'Orthogonal Nonnegative Matrix
+// Tri-factorizations for Clustering.' Proceedings of the 12th ACM SIGKDD
+// International Conference on Knowledge Discovery and Data Mining 126.
+//
+// For an r×c matrix V, Wo must be an r×k matrix, So a k×l matrix and Ho an l×c
+// matrix. FactorsTri uses multiplicative updates; the Method, Objective and
+// regularisation fields of c are ignored, as are KeepBest and Snapshot. If
+// c.Validate is true, FactorsTri panics if the dimensions of the inputs are not
+// consistent or if any of them have negative or non-finite entries.
+func FactorsTri(V, Wo, So, Ho *mat.Dense, c Config) (W, S, H *mat.Dense, ok bool) {
+	if c.Validate {
+		err := checkTriInputs(V, Wo, So, Ho)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	to := c.now()
+	u := newTri(V, Wo, So, Ho)
+	grad := triGradNorm(u.gradients())
+	c.Objective = Frobenius
+	c.KeepBest = false
+	c.Snapshot = nil
+	W, H, res, _ := iterate(context.Background(), u, grad, to, c)
+	S = u.S
+	if c.ZeroThreshold > 0 {
+		applyInPlace(zeroFilt(c.ZeroThreshold), S)
+	}
+	return W, S, H, res.OK && isFinite(S)
+}
+
+// checkTriInputs returns an error if V, Wo, So and Ho are not valid
+// inputs to a tri-factorisation.
+func checkTriInputs(V, Wo, So, Ho *mat.Dense) error {
+	vr, vc := V.Dims()
+	wr, wc := Wo.Dims()
+	sr, sc := So.Dims()
+	hr, hc := Ho.Dims()
+	switch {
+	case wc != sr:
+		return fmt.Errorf("nmf: Wo columns (%d) must equal So rows (%d)", wc, sr)
+	case sc != hr:
+		return fmt.Errorf("nmf: So columns (%d) must equal Ho rows (%d)", sc, hr)
+	case vr != wr:
+		return fmt.Errorf("nmf: V rows (%d) must equal Wo rows (%d)", vr, wr)
+	case vc != hc:
+		return fmt.Errorf("nmf: V columns (%d) must equal Ho columns (%d)", vc, hc)
+	}
+	for _, m := range []struct {
+		name string
+		m    *mat.Dense
+	}{
+		{name: "V", m: V},
+		{name: "Wo", m: Wo},
+		{name: "So", m: So},
+		{name: "Ho", m: Ho},
+	} {
+		i, j, v, ok := firstInvalid(m.m, false)
+		if !ok {
+			return fmt.Errorf("nmf: %s has %s at (%d, %d)", m.name, invalid(v), i, j)
+		}
+	}
+	return nil
+}
+
+// tri is the tri-factorisation update rule for the Frobenius
+// norm objective.
+type tri struct {
+	V, W, S, H *mat.Dense
+
+	sh, ws, tmp, num, den mat.Dense
+}
+
+func newTri(V, Wo, So, Ho *mat.Dense) *tri {
+	u := &tri{V: V, W: new(mat.Dense), S: new(mat.Dense), H: new(mat.Dense)}
+	u.W.CloneFrom(Wo)
+	u.S.CloneFrom(So)
+	u.H.CloneFrom(Ho)
+	return u
+}
+
+// gradients returns the gradients of the Frobenius norm objective
+// with respect to W, S and H.
+func (u *tri) gradients() (gW, gS, gH *mat.Dense) {
+	var sh, d, t mat.Dense
+	sh.Mul(u.S, u.H)
+	d.Mul(u.W, &sh)
+	d.Sub(&d, u.V)
+
+	// ∇W = (WSH - V)HᵀSᵀ
+	gW = new(mat.Dense)
+	gW.Mul(&d, sh.T())
+
+	// ∇S = Wᵀ(WSH - V)Hᵀ
+	t.Mul(u.W.T(), &d)
+	gS = new(mat.Dense)
+	gS.Mul(&t, u.H.T())
+
+	// ∇H = SᵀWᵀ(WSH - V)
+	gH = new(mat.Dense)
+	gH.Mul(u.S.T(), &t)
+
+	return gW, gS, gH
+}
+
+// triGradNorm returns the norm of the gradient given the gradients
+// gW, gS and gH.
+func triGradNorm(gW, gS, gH *mat.Dense) float64 {
+	return math.Hypot(gradNorm(gW, gH), mat.Norm(gS, 2))
+}
+
+func (u *tri) projNorm() float64 {
+	gW, gS, gH := u.gradients()
+	projectGradient(gS, u.S, 0)
+	return math.Hypot(projNorm(gW, u.W, gH, u.H), mat.Norm(gS, 2))
+}
+
+func (u *tri) update(_ context.Context) (ok bool, err error) {
+	// W *= (VHᵀSᵀ) / (WSHHᵀSᵀ)
+	u.sh.Reset()
+	u.sh.Mul(u.S, u.H)
+	u.num.Reset()
+	u.num.Mul(u.V, u.sh.T())
+	u.tmp.Reset()
+	u.tmp.Mul(&u.sh, u.sh.T())
+	u.den.Reset()
+	u.den.Mul(u.W, &u.tmp)
+	applyInPlace(ratio(&u.num, &u.den), u.W)
+
+	// H *= (SᵀWᵀV) / (SᵀWᵀWSH)
+	u.ws.Reset()
+	u.ws.Mul(u.W, u.S)
+	u.num.Reset()
+	u.num.Mul(u.ws.T(), u.V)
+	u.tmp.Reset()
+	u.tmp.Mul(u.ws.T(), &u.ws)
+	u.den.Reset()
+	u.den.Mul(&u.tmp, u.H)
+	applyInPlace(ratio(&u.num, &u.den), u.H)
+
+	// S *= (WᵀVHᵀ) / (WᵀWSHHᵀ)
+	u.tmp.Reset()
+	u.tmp.Mul(u.W.T(), u.V)
+	u.num.Reset()
+	u.num.Mul(&u.tmp, u.H.T())
+	u.tmp.Reset()
+	u.tmp.Mul(u.W.T(), &u.ws)
+	u.sh.Reset()
+	u.sh.Mul(&u.tmp, u.H)
+	u.den.Reset()
+	u.den.Mul(&u.sh, u.H.T())
+	applyInPlace(ratio(&u.num, &u.den), u.S)
+
+	return true, nil
+}
+
+func (u *tri) objective() float64 {
+	u.ws.Reset()
+	u.ws.Mul(u.W, u.S)
+	return frobenius(u.V, &u.ws, u.H)
+}
+
+func (u *tri) factors() (W, H *mat.Dense) { return u.W, u.H }
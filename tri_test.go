@@ -0,0 +1,101 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestFactorsTri(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	const (
+		rows, cols = 15, 12
+		k, l       = 3, 3
+	)
+	rowCluster := func(i int) int { return i * k / rows }
+	colCluster := func(j int) int { return j * l / cols }
+
+	// Construct a block-structured V from the
+	// cluster associations in assoc.
+	assoc := mat.NewDense(k, l, []float64{
+		5, 1, 0,
+		0, 4, 1,
+		1, 0, 6,
+	})
+	V := mat.NewDense(rows, cols, nil)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			V.Set(i, j, assoc.At(rowCluster(i), colCluster(j))+0.01*rnd.Float64())
+		}
+	}
+
+	Wo, _ := InitRandom(rows, cols, k, Uniform, rnd)
+	So, _ := InitRandom(k, l, l, Uniform, rnd)
+	_, Ho := InitRandom(rows, cols, l, Uniform, rnd)
+	c := Config{
+		Tolerance: 1e-6,
+		MaxIter:   2000,
+		Limit:     time.Minute,
+		Validate:  true,
+	}
+	W, S, H, _ := FactorsTri(V, Wo, So, Ho, c)
+	for _, m := range []*mat.Dense{W, S, H} {
+		if err := CheckNonNegative(m); err != nil {
+			t.Errorf("invalid factor: %v", err)
+		}
+	}
+
+	var WS, WSH mat.Dense
+	WS.Mul(W, S)
+	WSH.Mul(&WS, H)
+	WSH.Sub(V, &WSH)
+	if rel := mat.Norm(&WSH, 2) / mat.Norm(V, 2); rel > 0.01 {
+		t.Errorf("unexpected relative reconstruction error: %v", rel)
+	}
+
+	// The largest memberships recover the blocks.
+	checkClusters(t, "row", rows, k, rowCluster, func(i int) int { return floats.MaxIdx(W.RawRowView(i)) })
+	checkClusters(t, "column", cols, l, colCluster, func(j int) int { return floats.MaxIdx(mat.Col(nil, j, H)) })
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic for mismatched dimensions")
+			}
+		}()
+		FactorsTri(V, Wo, Ho, So, c)
+	}()
+}
+
+// checkClusters checks that the n items assigned to k clusters by
+// want are assigned consistently to distinct clusters by got.
+func checkClusters(t *testing.T, name string, n, k int, want, got func(int) int) {
+	t.Helper()
+	label := make(map[int]int)
+	used := make(map[int]bool)
+	for i := 0; i < n; i++ {
+		w, g := want(i), got(i)
+		l, ok := label[w]
+		if !ok {
+			if used[g] {
+				t.Errorf("%s clusters merged at %d", name, i)
+			}
+			label[w] = g
+			used[g] = true
+			continue
+		}
+		if l != g {
+			t.Errorf("%s %d assigned to cluster %d, want %d", name, i, g, l)
+		}
+	}
+	if len(used) != k {
+		t.Errorf("unexpected number of %s clusters: got:%d want:%d", name, len(used), k)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// maxBudgetRounds is the maximum number of factorisations
+// performed by FactorsUntil.
+const maxBudgetRounds = 10
+
+// FactorsUntil returns matrices W and H that are rank k non-negative factors
+// of V, refining the factorisation with increasing computation budgets until
+// the relative residual ||V-WH||/||V|| falls below targetRelResid. The initial
+// solutions are chosen as for Fit. Each round factorises V with
+// StopWhenRelResidBelow set to targetRelResid, warm-starting from the factors
+// and State of the previous round, and the next round doubles c.MaxIter and
+// c.Limit. If c.MaxIter is not positive, DefaultMaxIter is used for the first
+// round. At most 10 rounds are performed, so the final budget is 512 times
+// the initial budget. Rounds end early if a factorisation terminates for a
+// reason other than reaching MaxIter or Limit, since a larger budget would
+// not then reduce the residual. NaN entries of V are ignored when computing
+// the residual.
+//
+// The returned resid is the relative residual of the returned factors, and
+// ok is whether it is below targetRelResid. FactorsUntil panics if k is not
+// positive or if targetRelResid is negative or NaN.
+func FactorsUntil(V *mat.Dense, k int, targetRelResid float64, c Config) (W, H *mat.Dense, resid float64, ok bool) {
+	if k <= 0 {
+		panic(errZeroRank)
+	}
+	if targetRelResid < 0 || math.IsNaN(targetRelResid) {
+		panic(fmt.Sprintf("nmf: invalid target relative residual: %v", targetRelResid))
+	}
+	if V.IsEmpty() {
+		return new(mat.Dense), new(mat.Dense), 0, true
+	}
+
+	W, H = fitInit(V, k, c)
+	c.StopWhenRelResidBelow = targetRelResid
+	if c.MaxIter <= 0 {
+		c.MaxIter = DefaultMaxIter
+	}
+	norm := math.Max(frobeniusNorm(V), epsilon)
+	var wh mat.Dense
+	for round := 0; round < maxBudgetRounds; round++ {
+		var res Result
+		W, H, res = FactorsResult(V, W, H, c)
+		resid = math.Sqrt(squaredResidual(&wh, V, W, H)) / norm
+		if resid < targetRelResid {
+			return W, H, resid, true
+		}
+		if res.Status != MaxIterReached && res.Status != TimeLimitReached {
+			break
+		}
+		c.WarmStart = true
+		c.State = res.State
+		c.MaxIter *= 2
+		c.Limit *= 2
+	}
+	return W, H, resid, false
+}
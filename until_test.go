@@ -0,0 +1,56 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestFactorsUntil(t *testing.T) {
+	V, _, _ := lowRank(30, 20, 3, rand.NewSource(1))
+	c := Config{
+		Tolerance:   1e-9,
+		MaxIter:     2,
+		MaxOuterSub: 1000,
+		MaxInnerSub: 20,
+		Seed:        5,
+	}
+	Wo, Ho := InitRandom(30, 20, 3, Uniform, rand.NewSource(c.Seed))
+	_, _, res := FactorsResult(V, Wo, Ho, c)
+	if res.Status != MaxIterReached {
+		t.Fatalf("initial budget unexpectedly sufficient: %v", res.Status)
+	}
+
+	const target = 1e-4
+	W, H, resid, ok := FactorsUntil(V, 3, target, c)
+	if !ok || resid >= target {
+		t.Errorf("target not met: ok=%t resid=%v", ok, resid)
+	}
+	if got := RelativeResidual(V, W, H); math.Abs(got-resid) > 1e-14 {
+		t.Errorf("unexpected residual: got:%v want:%v", resid, got)
+	}
+
+	// An underspecified rank cannot meet the target.
+	_, _, resid, ok = FactorsUntil(V, 1, target, c)
+	if ok || resid < target {
+		t.Errorf("unexpected target met for rank one: resid=%v", resid)
+	}
+
+	for _, test := range []struct {
+		k      int
+		target float64
+	}{{0, target}, {3, -1}, {3, math.NaN()}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for k=%d target=%v", test.k, test.target)
+				}
+			}()
+			FactorsUntil(V, test.k, test.target, c)
+		}()
+	}
+}
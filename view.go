@@ -0,0 +1,124 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// FactorsView returns matrices W and H that are non-negative factors of the
+// sub-matrix of V formed from the rows and columns indexed by rows and cols, in
+// that order, within the specified tolerance and computation limits given initial
+// non-negative solutions Wo and Ho. A nil rows or cols selects all the rows or
+// columns of V. The sub-matrix is not copied; it is read through V, so blocks of
+// a large matrix may be fitted or held out for cross-validation without copying.
+// If the indices are contiguous and V can be sliced, the slice of V is used
+// directly, and if V is a mat.NonZeroDoer, the sub-matrix is also a mat.NonZeroDoer.
+// The factorisation is performed as described for FactorsSparse.
+//
+// For a sub-matrix with r rows and c columns, Wo must be an r×k matrix and Ho a
+// k×c matrix. FactorsView panics if any index is out of range.
+func FactorsView(V mat.Matrix, rows, cols []int, Wo, Ho *mat.Dense, c Config) (W, H *mat.Dense, ok bool) {
+	return FactorsSparse(subMatrix(V, rows, cols), Wo, Ho, c)
+}
+
+// subMatrix returns a view of the rows and columns of m indexed
+// by rows and cols. A nil rows or cols selects all the rows or
+// columns of m.
+func subMatrix(m mat.Matrix, rows, cols []int) mat.Matrix {
+	r, c := m.Dims()
+	rows = indices(rows, r, "row")
+	cols = indices(cols, c, "column")
+
+	if s, ok := m.(slicer); ok && contiguous(rows) && contiguous(cols) && len(rows) != 0 && len(cols) != 0 {
+		return s.Slice(rows[0], rows[0]+len(rows), cols[0], cols[0]+len(cols))
+	}
+	v := view{m: m, rows: rows, cols: cols}
+	if nz, ok := m.(mat.NonZeroDoer); ok {
+		return sparseView{view: v, nz: nz}
+	}
+	return v
+}
+
+// slicer is a matrix that can return a view of a contiguous
+// sub-matrix.
+type slicer interface {
+	Slice(i, k, j, l int) mat.Matrix
+}
+
+// indices returns idx after checking that its elements are in
+// [0, n). If idx is nil, the indices 0 to n-1 are returned.
+func indices(idx []int, n int, name string) []int {
+	if idx == nil {
+		idx = make([]int, n)
+		for i := range idx {
+			idx[i] = i
+		}
+		return idx
+	}
+	for _, i := range idx {
+		if i < 0 || n <= i {
+			panic(fmt.Sprintf("nmf: %s index %d out of range", name, i))
+		}
+	}
+	return idx
+}
+
+// contiguous returns whether idx holds consecutive ascending
+// integers.
+func contiguous(idx []int) bool {
+	for i := 1; i < len(idx); i++ {
+		if idx[i] != idx[i-1]+1 {
+			return false
+		}
+	}
+	return true
+}
+
+// view is a sub-matrix of m formed from the rows and
+// columns indexed by rows and cols.
+type view struct {
+	m          mat.Matrix
+	rows, cols []int
+}
+
+func (v view) Dims() (r, c int)    { return len(v.rows), len(v.cols) }
+func (v view) At(i, j int) float64 { return v.m.At(v.rows[i], v.cols[j]) }
+func (v view) T() mat.Matrix       { return mat.Transpose{Matrix: v} }
+
+// sparseView is a view of a mat.NonZeroDoer.
+type sparseView struct {
+	view
+	nz mat.NonZeroDoer
+}
+
+func (v sparseView) T() mat.Matrix { return mat.Transpose{Matrix: v} }
+
+// DoNonZero calls fn for each non-zero entry of the view by iterating
+// over the non-zero entries of the underlying matrix.
+func (v sparseView) DoNonZero(fn func(i, j int, v float64)) {
+	r, c := v.m.Dims()
+	rowAt := positions(v.rows, r)
+	colAt := positions(v.cols, c)
+	v.nz.DoNonZero(func(i, j int, x float64) {
+		for _, vi := range rowAt[i] {
+			for _, vj := range colAt[j] {
+				fn(vi, vj, x)
+			}
+		}
+	})
+}
+
+// positions returns the positions in idx of each of the
+// integers in [0, n).
+func positions(idx []int, n int) [][]int {
+	pos := make([][]int, n)
+	for p, i := range idx {
+		pos[i] = append(pos[i], p)
+	}
+	return pos
+}
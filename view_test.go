@@ -0,0 +1,86 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestFactorsView(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	V, _, _ := lowRank(30, 20, 4, rand.NewSource(1))
+	V = sparsify(V, 0.5, rnd)
+	S := newCOO(V)
+
+	c := testConfig
+	c.MaxIter = 50
+	c.Limit = 0
+	for _, test := range []struct {
+		name       string
+		rows, cols []int
+	}{
+		{name: "scattered", rows: []int{0, 3, 4, 8, 9, 10, 15, 21, 22, 29}, cols: []int{1, 2, 5, 7, 11, 12, 19}},
+		{name: "contiguous", rows: []int{5, 6, 7, 8, 9, 10, 11, 12}, cols: []int{3, 4, 5, 6, 7, 8}},
+		{name: "all rows", cols: []int{0, 2, 4, 6, 8, 10}},
+	} {
+		rows, cols := indices(test.rows, 30, "row"), indices(test.cols, 20, "column")
+		sub := mat.NewDense(len(rows), len(cols), nil)
+		for i, r := range rows {
+			for j, c := range cols {
+				sub.Set(i, j, V.At(r, c))
+			}
+		}
+		for _, m := range []mat.Matrix{V, S} {
+			view := subMatrix(m, test.rows, test.cols)
+			if !mat.Equal(view, sub) {
+				t.Errorf("unexpected view for %s", test.name)
+			}
+			if !mat.Equal(view.T(), sub.T()) {
+				t.Errorf("unexpected transposed view for %s", test.name)
+			}
+			if nz, ok := view.(mat.NonZeroDoer); ok {
+				got := mat.NewDense(len(rows), len(cols), nil)
+				nz.DoNonZero(func(i, j int, v float64) { got.Set(i, j, v) })
+				if !mat.Equal(got, sub) {
+					t.Errorf("unexpected non-zero entries for %s", test.name)
+				}
+			}
+		}
+
+		Wo, Ho := InitRandom(len(rows), len(cols), 3, Uniform, rand.NewSource(2))
+		wantW, wantH, _ := FactorsSparse(sub, Wo, Ho, c)
+		for _, m := range []mat.Matrix{V, S} {
+			W, H, _ := FactorsView(m, test.rows, test.cols, Wo, Ho, c)
+			if !mat.EqualApprox(W, wantW, 1e-8) || !mat.EqualApprox(H, wantH, 1e-8) {
+				t.Errorf("view factors do not match copied factors for %s with %T", test.name, m)
+			}
+		}
+	}
+
+	if _, ok := subMatrix(V, []int{1, 2}, []int{3, 4}).(*mat.Dense); !ok {
+		t.Error("contiguous view of a dense matrix is not a slice")
+	}
+	if _, ok := subMatrix(S, []int{1, 2}, []int{3, 4}).(mat.NonZeroDoer); !ok {
+		t.Error("view of a sparse matrix is not sparse")
+	}
+
+	for _, idx := range [][2][]int{
+		{{-1}, nil},
+		{{30}, nil},
+		{nil, {20}},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for rows=%v cols=%v", idx[0], idx[1])
+				}
+			}()
+			subMatrix(V, idx[0], idx[1])
+		}()
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// warmStartProblem returns a matrix V, a perturbed copy Vp and
+// the factors and result of a factorisation of V from Wo and Ho.
+func warmStartProblem(c Config) (Vp, Wo, Ho, W, H *mat.Dense, res Result) {
+	rnd := rand.New(rand.NewSource(1))
+	V, Wo, Ho := lowRank(40, 30, 4, rnd)
+	W, H, res = FactorsResult(V, Wo, Ho, c)
+
+	Vp = mat.DenseCopyOf(V)
+	Vp.Apply(func(_, _ int, v float64) float64 {
+		return v * (1 + 0.01*rnd.NormFloat64())
+	}, Vp)
+	return Vp, Wo, Ho, W, H, res
+}
+
+var warmStartConfig = Config{
+	Tolerance:   1e-4,
+	MaxIter:     5000,
+	MaxOuterSub: 1000,
+	MaxInnerSub: 20,
+	Limit:       time.Minute,
+}
+
+func TestWarmStart(t *testing.T) {
+	c := warmStartConfig
+	Vp, Wo, Ho, W, H, res := warmStartProblem(c)
+	_, _, cold := FactorsResult(Vp, Wo, Ho, c)
+
+	c.WarmStart = true
+	c.State = res.State
+	_, _, warm := FactorsResult(Vp, W, H, c)
+	if !warm.Converged {
+		t.Errorf("warm start failed to converge in %d iterations", warm.Iterations)
+	}
+	if warm.Iterations*2 > cold.Iterations {
+		t.Errorf("warm start did not reduce iterations: warm:%d cold:%d", warm.Iterations, cold.Iterations)
+	}
+	if warm.InitialGradNorm != res.InitialGradNorm {
+		t.Errorf("reference gradient norm not retained: got:%v want:%v", warm.InitialGradNorm, res.InitialGradNorm)
+	}
+}
+
+func BenchmarkWarmStart(b *testing.B) {
+	c := warmStartConfig
+	Vp, Wo, Ho, W, H, res := warmStartProblem(c)
+	for _, test := range []struct {
+		name   string
+		Wo, Ho *mat.Dense
+		warm   bool
+	}{
+		{name: "cold", Wo: Wo, Ho: Ho},
+		{name: "warm", Wo: W, Ho: H, warm: true},
+	} {
+		c := c
+		c.WarmStart = test.warm
+		c.State = res.State
+		b.Run(test.name, func(b *testing.B) {
+			var iter int
+			for i := 0; i < b.N; i++ {
+				_, _, res := FactorsResult(Vp, test.Wo, test.Ho, c)
+				iter += res.Iterations
+			}
+			b.ReportMetric(float64(iter)/float64(b.N), "iterations/op")
+		})
+	}
+}
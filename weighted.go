@@ -0,0 +1,251 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// FactorsWeighted returns matrices W and H that are non-negative factors of V
+// within the specified tolerance and computation limits given initial non-negative
+// solutions Wo and Ho, minimising the weighted Frobenius norm objective
+//
+//	1/2 * sum_ij Weights_ij * (V - WH)_ij^2
+//
+// Weights must have the same dimensions as V and have non-negative entries.
+// Entries of V with zero weight do not contribute to the objective and so are
+// ignored by the factorisation.
+//
+// FactorsWeighted uses multiplicative updates; the Method and Objective fields
+// of c are ignored. The remaining fields of c, including Preprocess and the
+// post-processing of the factors, are used as for Factors. The residual
+// checked against StopWhenRelResidBelow is not weighted.
+func FactorsWeighted(V, Weights, Wo, Ho *mat.Dense, c Config) (W, H *mat.Dense, ok bool) {
+	if c.Validate {
+		err := checkInputs(V, Wo, Ho, false)
+		if err == nil {
+			err = checkWeights(V, Weights)
+		}
+		if err != nil {
+			panic(err)
+		}
+	}
+	vr, vc := V.Dims()
+	lr, lc := Weights.Dims()
+	if lr != vr || lc != vc {
+		panic("nmf: dimension mismatch between V and Weights")
+	}
+
+	c.weights = Weights
+	W, H, res, _ := factors(context.Background(), V, Wo, Ho, c)
+	return W, H, res.OK
+}
+
+// FactorsRowWeighted returns matrices W and H that are non-negative factors of V
+// within the specified tolerance and computation limits given initial
+// non-negative solutions Wo and Ho, minimising the row weighted Frobenius norm
+// objective
+//
+//	1/2 * ||D^(1/2) * (V - WH)||^2
+//
+// where D is the diagonal matrix of rowWeights. Row weights less than one reduce
+// the influence of the corresponding features of V, such as frequent terms in
+// a document-term matrix. The factorisation is performed as described for
+// Factors on the rows of V and Wo scaled by the square roots of the weights,
+// so any penalties on W in c apply to the scaled W. The scaling is equivalent
+// to weighting the objective only for the Frobenius norm, so the Objective
+// field of c is ignored and the Frobenius objective is always used.
+// ZeroThreshold is applied to the returned, unscaled, factors. Bounds on the
+// entries of W would apply to the scaled W, so MaxW and ProjectionFloor are
+// not supported.
+//
+// FactorsRowWeighted panics if the length of rowWeights does not equal the
+// number of rows of V, if any weight is not positive and finite, or if c has
+// a bounded MaxW or a non-zero ProjectionFloor.
+func FactorsRowWeighted(V *mat.Dense, rowWeights []float64, Wo, Ho *mat.Dense, c Config) (W, H *mat.Dense, ok bool) {
+	vr, _ := V.Dims()
+	if len(rowWeights) != vr {
+		panic("nmf: dimension mismatch between V and rowWeights")
+	}
+	scale := make([]float64, vr)
+	for i, w := range rowWeights {
+		if w <= 0 || math.IsInf(w, 1) || math.IsNaN(w) {
+			panic(fmt.Sprintf("nmf: invalid row weight %v at %d", w, i))
+		}
+		scale[i] = math.Sqrt(w)
+	}
+	if !unbounded(c.MaxW) || c.ProjectionFloor != 0 {
+		panic("nmf: MaxW and ProjectionFloor are not supported by FactorsRowWeighted")
+	}
+
+	Vs := mat.DenseCopyOf(V)
+	Ws := mat.DenseCopyOf(Wo)
+	mul := func(i, _ int, v float64) float64 { return v * scale[i] }
+	c.Objective = Frobenius
+	threshold := c.ZeroThreshold
+	c.ZeroThreshold = 0
+	applyInPlace(mul, Vs)
+	applyInPlace(mul, Ws)
+
+	W, H, ok = Factors(Vs, Ws, Ho, c)
+
+	// W is either Ws or owned by the updater,
+	// so it may be rescaled in place.
+	applyInPlace(func(i, _ int, v float64) float64 { return v / scale[i] }, W)
+	if threshold > 0 {
+		if H == Ho {
+			H = mat.DenseCopyOf(H)
+		}
+		applyInPlace(zeroFilt(threshold), W)
+		applyInPlace(zeroFilt(threshold), H)
+	}
+	return W, H, ok
+}
+
+// nanMask returns a weight matrix with zero weights for NaN
+// entries of V and unit weights elsewhere.
+func nanMask(V *mat.Dense) *mat.Dense {
+	var mask mat.Dense
+	mask.Apply(func(_, _ int, v float64) float64 {
+		if math.IsNaN(v) {
+			return 0
+		}
+		return 1
+	}, V)
+	return &mask
+}
+
+// checkWeights returns an error if Weights is not a valid weight
+// matrix for V.
+func checkWeights(V, Weights *mat.Dense) error {
+	vr, vc := V.Dims()
+	lr, lc := Weights.Dims()
+	if lr != vr || lc != vc {
+		return fmt.Errorf("nmf: Weights dimensions (%d×%d) must equal V dimensions (%d×%d)", lr, lc, vr, vc)
+	}
+	i, j, v, ok := firstInvalid(Weights, false)
+	if !ok {
+		return fmt.Errorf("nmf: Weights has %s at (%d, %d)", invalid(v), i, j)
+	}
+	return nil
+}
+
+// weightedUpdate is the multiplicative update rule for the
+// weighted Frobenius norm objective.
+type weightedUpdate struct {
+	V, Weights, W, H *mat.Dense
+	pW, pH           penalty
+
+	// lv holds the element-wise product of
+	// Weights and V.
+	lv mat.Dense
+
+	lwh, num, den mat.Dense
+}
+
+func newWeightedUpdate(V, Weights, Wo, Ho *mat.Dense, c Config) *weightedUpdate {
+	pW, pH := c.penalties()
+	u := &weightedUpdate{
+		V: V, Weights: Weights,
+		W: new(mat.Dense), H: new(mat.Dense),
+		pW: pW, pH: pH,
+	}
+	u.W.CloneFrom(Wo)
+	u.H.CloneFrom(Ho)
+	u.lv.Apply(func(r, c int, v float64) float64 {
+		w := Weights.At(r, c)
+		if w == 0 {
+			// Avoid propagating values from
+			// ignored entries.
+			return 0
+		}
+		return w * v
+	}, V)
+	return u
+}
+
+// weightedProduct sets u.lwh to the element-wise product of
+// Weights and WH for the current factors.
+func (u *weightedUpdate) weightedProduct() {
+	u.lwh.Reset()
+	u.lwh.Mul(u.W, u.H)
+	u.lwh.MulElem(u.Weights, &u.lwh)
+}
+
+// gradients returns the gradients of the weighted objective with
+// respect to W and H.
+func (u *weightedUpdate) gradients() (gW, gH *mat.Dense) {
+	u.weightedProduct()
+	var d mat.Dense
+	d.Sub(&u.lwh, &u.lv)
+
+	gW = new(mat.Dense)
+	gW.Mul(&d, u.H.T())
+	u.pW.addGradient(gW, u.W)
+
+	gH = new(mat.Dense)
+	gH.Mul(u.W.T(), &d)
+	u.pH.addGradient(gH, u.H)
+
+	return gW, gH
+}
+
+func (u *weightedUpdate) projNorm() float64 {
+	gW, gH := u.gradients()
+	return projNorm(gW, u.W, gH, u.H)
+}
+
+func (u *weightedUpdate) projGradients() (gW, gH *mat.Dense) {
+	gW, gH = u.gradients()
+	projectGradient(gW, u.W, 0)
+	projectGradient(gH, u.H, 0)
+	return gW, gH
+}
+
+func (u *weightedUpdate) update(_ context.Context) (ok bool, err error) {
+	// H *= (Wᵀ(Λ⊙V)) / (Wᵀ(Λ⊙WH) + ∇penalty)
+	u.weightedProduct()
+	u.num.Reset()
+	u.num.Mul(u.W.T(), &u.lv)
+	u.den.Reset()
+	u.den.Mul(u.W.T(), &u.lwh)
+	u.pH.addGradient(&u.den, u.H)
+	applyInPlace(ratio(&u.num, &u.den), u.H)
+
+	// W *= ((Λ⊙V)Hᵀ) / ((Λ⊙WH)Hᵀ + ∇penalty)
+	u.weightedProduct()
+	u.num.Reset()
+	u.num.Mul(&u.lv, u.H.T())
+	u.den.Reset()
+	u.den.Mul(&u.lwh, u.H.T())
+	u.pW.addGradient(&u.den, u.W)
+	applyInPlace(ratio(&u.num, &u.den), u.W)
+
+	return true, nil
+}
+
+func (u *weightedUpdate) objective() float64 {
+	var wh mat.Dense
+	wh.Mul(u.W, u.H)
+	r, c := u.V.Dims()
+	var f float64
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			w := u.Weights.At(i, j)
+			if w == 0 {
+				continue
+			}
+			d := u.V.At(i, j) - wh.At(i, j)
+			f += w * d * d
+		}
+	}
+	return 0.5*f + u.pW.value(u.W) + u.pH.value(u.H)
+}
+
+func (u *weightedUpdate) factors() (W, H *mat.Dense) { return u.W, u.H }
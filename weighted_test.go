@@ -0,0 +1,218 @@
+// Copyright ©2015 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nmf
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestFactorsWeightedUniform(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	r, c := V.Dims()
+	ones := mat.NewDense(r, c, nil)
+	ones.Apply(func(_, _ int, _ float64) float64 { return 1 }, ones)
+
+	conf := testConfig
+	conf.Method = MultiplicativeUpdate
+	Wu, Hu, _ := Factors(V, Wo, Ho, conf)
+	W, H, ok := FactorsWeighted(V, ones, Wo, Ho, conf)
+	if !ok {
+		t.Error("unexpected failure")
+	}
+	if !mat.EqualApprox(W, Wu, 1e-8) || !mat.EqualApprox(H, Hu, 1e-8) {
+		t.Errorf("uniform weights do not reproduce unweighted factors:\nW =\n%.6v\nwant:\n%.6v\nH =\n%.6v\nwant:\n%.6v",
+			mat.Formatted(W), mat.Formatted(Wu), mat.Formatted(H), mat.Formatted(Hu))
+	}
+}
+
+func TestFactorsWeightedZero(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	r, c := V.Dims()
+	weights := mat.NewDense(r, c, nil)
+	weights.Apply(func(_, _ int, _ float64) float64 { return 1 }, weights)
+	weights.Set(1, 2, 0)
+
+	conf := testConfig
+	conf.Limit = 0
+	W, H, _ := FactorsWeighted(V, weights, Wo, Ho, conf)
+
+	// Changing an entry with zero weight does not
+	// change the factorisation.
+	Vc := mat.DenseCopyOf(V)
+	Vc.Set(1, 2, 1e6)
+	Wc, Hc, _ := FactorsWeighted(Vc, weights, Wo, Ho, conf)
+	if !mat.Equal(W, Wc) || !mat.Equal(H, Hc) {
+		t.Error("zero weighted entry affected factorisation")
+	}
+}
+
+func TestFactorsWeightedConfig(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	r, c := V.Dims()
+	_, k := Wo.Dims()
+	weights := mat.NewDense(r, c, nil)
+	weights.Apply(func(i, _ int, _ float64) float64 { return float64(i%3 + 1) }, weights)
+
+	conf := testConfig
+	conf.Limit = 0
+	W, H, _ := FactorsWeighted(V, weights, Wo, Ho, conf)
+
+	// The factors are post-processed as for Factors.
+	conf.Canonicalize = true
+	Wc, Hc, _ := FactorsWeighted(V, weights, Wo, Ho, conf)
+	canonicalize(W, H)
+	if !mat.Equal(Wc, W) || !mat.Equal(Hc, H) {
+		t.Error("weighted factors not canonicalised")
+	}
+
+	conf.Canonicalize = false
+	conf.PruneZeroComponents = true
+	Wz := mat.DenseCopyOf(Wo)
+	for i := 0; i < r; i++ {
+		Wz.Set(i, 0, 0)
+	}
+	Wp, Hp, _ := FactorsWeighted(V, weights, Wz, Ho, conf)
+	if _, wc := Wp.Dims(); wc != k-1 {
+		t.Errorf("unexpected W columns after pruning: got:%d want:%d", wc, k-1)
+	}
+	if hr, _ := Hp.Dims(); hr != k-1 {
+		t.Errorf("unexpected H rows after pruning: got:%d want:%d", hr, k-1)
+	}
+}
+
+func TestMaskNaN(t *testing.T) {
+	var V mat.Dense
+	V.Mul(
+		mat.NewDense(6, 2, []float64{1, 0, 2, 1, 0, 3, 4, 1, 1, 1, 2, 5}),
+		mat.NewDense(2, 5, []float64{1, 2, 0, 3, 1, 2, 0, 1, 1, 4}),
+	)
+	truth := mat.DenseCopyOf(&V)
+	missing := [][2]int{{0, 1}, {2, 4}, {5, 2}}
+	for _, m := range missing {
+		V.Set(m[0], m[1], math.NaN())
+	}
+
+	Wo, Ho := InitRandom(6, 5, 2, Uniform, rand.NewSource(1))
+	conf := testConfig
+	conf.MaskNaN = true
+	conf.Tolerance = 1e-10
+	conf.MaxIter = 10000
+	W, H, _, err := FactorsE(&V, Wo, Ho, conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := CheckNonNegative(W); err != nil {
+		t.Errorf("invalid W: %v", err)
+	}
+	if err := CheckNonNegative(H); err != nil {
+		t.Errorf("invalid H: %v", err)
+	}
+
+	var P mat.Dense
+	P.Mul(W, H)
+	for _, m := range missing {
+		got := P.At(m[0], m[1])
+		want := truth.At(m[0], m[1])
+		if math.Abs(got-want) > 1e-2*math.Max(1, want) {
+			t.Errorf("unexpected imputed value at %v: got:%v want:%v", m, got, want)
+		}
+	}
+}
+
+func TestFactorsRowWeighted(t *testing.T) {
+	V, Wo, Ho := testFactors()
+	r, _ := V.Dims()
+	ones := make([]float64, r)
+	for i := range ones {
+		ones[i] = 1
+	}
+	conf := testConfig
+	conf.Limit = 0
+	Wu, Hu, okU := Factors(V, Wo, Ho, conf)
+	W, H, ok := FactorsRowWeighted(V, ones, Wo, Ho, conf)
+	if ok != okU || !mat.Equal(W, Wu) || !mat.Equal(H, Hu) {
+		t.Errorf("uniform row weights do not reproduce unweighted factors:\nW =\n%.6v\nwant:\n%.6v\nH =\n%.6v\nwant:\n%.6v",
+			mat.Formatted(W), mat.Formatted(Wu), mat.Formatted(H), mat.Formatted(Hu))
+	}
+
+	// A heavily weighted row is fitted more closely
+	// by a factorisation that cannot fit all rows.
+	src := rand.NewSource(1)
+	V, _, _ = lowRank(20, 10, 5, src)
+	Wo, Ho = InitRandom(20, 10, 2, Uniform, src)
+	weights := make([]float64, 20)
+	for i := range weights {
+		weights[i] = 1
+	}
+	weights[0] = 100
+	Wu, Hu, _ = Factors(V, Wo, Ho, conf)
+	W, H, _ = FactorsRowWeighted(V, weights, Wo, Ho, conf)
+	if err := CheckNonNegative(W); err != nil {
+		t.Errorf("invalid W: %v", err)
+	}
+	got, want := RowErrors(V, W, H)[0], RowErrors(V, Wu, Hu)[0]
+	if got >= want {
+		t.Errorf("weighted row not fitted more closely: got:%v unweighted:%v", got, want)
+	}
+
+	// Row scaling only weights the Frobenius objective,
+	// so other objectives are ignored.
+	for _, obj := range []Objective{KullbackLeibler, BetaDivergence, L21} {
+		c := conf
+		c.Objective = obj
+		c.Beta = 1.5
+		Wk, Hk, _ := FactorsRowWeighted(V, weights, Wo, Ho, c)
+		if !mat.Equal(Wk, W) || !mat.Equal(Hk, H) {
+			t.Errorf("objective %d not replaced by the Frobenius objective", obj)
+		}
+	}
+
+	// The zero threshold applies to the unscaled factors.
+	c := conf
+	c.ZeroThreshold = mat.Max(W) / 4
+	Wz, Hz, _ := FactorsRowWeighted(V, weights, Wo, Ho, c)
+	W.Apply(zeroFilt(c.ZeroThreshold), W)
+	H.Apply(zeroFilt(c.ZeroThreshold), H)
+	if !mat.Equal(Wz, W) || !mat.Equal(Hz, H) {
+		t.Error("zero threshold not applied to the unscaled factors")
+	}
+
+	for _, test := range []struct {
+		maxW, floor float64
+	}{{maxW: 1}, {floor: 1e-10}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for MaxW=%v ProjectionFloor=%v", test.maxW, test.floor)
+				}
+			}()
+			c := conf
+			c.MaxW = test.maxW
+			c.ProjectionFloor = test.floor
+			FactorsRowWeighted(V, weights, Wo, Ho, c)
+		}()
+	}
+
+	for _, w := range [][]float64{
+		ones[1:],
+		append([]float64{0}, ones[1:]...),
+		append([]float64{-1}, ones[1:]...),
+		append([]float64{math.NaN()}, ones[1:]...),
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for weights %v", w)
+				}
+			}()
+			V, Wo, Ho := testFactors()
+			FactorsRowWeighted(V, w, Wo, Ho, conf)
+		}()
+	}
+}